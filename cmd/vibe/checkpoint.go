@@ -0,0 +1,271 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	checkpointRefPrefix       = "refs/vibe/checkpoints/"
+	defaultCheckpointInterval = 5 * time.Minute
+)
+
+var errNoChanges = errors.New("no changes since last checkpoint")
+
+// snapshotIdentity authors the hidden snapshot commits so they work without a
+// configured git identity and are never mistaken for user commits.
+var snapshotIdentity = []string{
+	"GIT_AUTHOR_NAME=vibe",
+	"GIT_AUTHOR_EMAIL=vibe@localhost",
+	"GIT_COMMITTER_NAME=vibe",
+	"GIT_COMMITTER_EMAIL=vibe@localhost",
+}
+
+type checkpoint struct {
+	Number    int
+	Ref       string
+	Commit    string
+	CreatedAt string
+	Reason    string
+}
+
+// checkpointPrefix is where the checkpoints of a sandbox live. It includes
+// the creation time, so a sandbox recreated under the same name does not
+// inherit the checkpoints of an earlier one.
+func checkpointPrefix(meta *sandboxMeta) string {
+	prefix := checkpointRefPrefix + meta.Name + "/"
+	if created, err := time.Parse(time.RFC3339, meta.CreatedAt); err == nil {
+		prefix += created.UTC().Format("20060102T150405Z") + "/"
+	}
+	return prefix
+}
+
+func checkpointRef(meta *sandboxMeta, n int) string {
+	return checkpointPrefix(meta) + strconv.Itoa(n)
+}
+
+// deleteCheckpoints removes the checkpoint refs of every sandbox that was
+// ever called name.
+func (m *manager) deleteCheckpoints(name string) error {
+	out, err := gitOutputFn(m.repoRoot, "for-each-ref", "--format=%(refname)", checkpointRefPrefix+name+"/")
+	if err != nil {
+		return fmt.Errorf("list checkpoints: %w", err)
+	}
+	for _, ref := range splitLines(out) {
+		if _, err := gitOutputFn(m.repoRoot, "update-ref", "-d", ref); err != nil {
+			return fmt.Errorf("delete checkpoint %s: %w", ref, err)
+		}
+	}
+	return nil
+}
+
+func tempIndexEnv() ([]string, func(), error) {
+	tmpDir, err := os.MkdirTemp("", "vibe-index-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create temp index: %w", err)
+	}
+	return []string{"GIT_INDEX_FILE=" + filepath.Join(tmpDir, "index")}, func() { os.RemoveAll(tmpDir) }, nil
+}
+
+func snapshotWorktree(worktree, message string, includeUntracked bool) (commit, tree, head string, err error) {
+	head, err = gitOutputFn(worktree, "rev-parse", "HEAD")
+	if err != nil {
+		return "", "", "", fmt.Errorf("resolve HEAD: %w", err)
+	}
+
+	indexEnv, cleanup, err := tempIndexEnv()
+	if err != nil {
+		return "", "", "", err
+	}
+	defer cleanup()
+	env := append(indexEnv, snapshotIdentity...)
+
+	if _, err := gitOutputEnvFn(worktree, env, "read-tree", "HEAD"); err != nil {
+		return "", "", "", fmt.Errorf("read HEAD tree: %w", err)
+	}
+	addFlag := "-u"
+	if includeUntracked {
+		addFlag = "-A"
+	}
+	if _, err := gitOutputEnvFn(worktree, env, "add", addFlag); err != nil {
+		return "", "", "", fmt.Errorf("stage worktree: %w", err)
+	}
+	tree, err = gitOutputEnvFn(worktree, env, "write-tree")
+	if err != nil {
+		return "", "", "", fmt.Errorf("write tree: %w", err)
+	}
+	commit, err = gitOutputEnvFn(worktree, env, "commit-tree", tree, "-p", head, "-m", message)
+	if err != nil {
+		return "", "", "", fmt.Errorf("commit tree: %w", err)
+	}
+	return commit, tree, head, nil
+}
+
+func (m *manager) createCheckpoint(meta *sandboxMeta, reason string) (*checkpoint, error) {
+	existing, err := m.listCheckpoints(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, tree, head, err := snapshotWorktree(meta.Worktree, "checkpoint: "+reason, true)
+	if err != nil {
+		return nil, err
+	}
+
+	next := 1
+	if len(existing) > 0 {
+		last := existing[len(existing)-1]
+		next = last.Number + 1
+		lastTree, _ := gitOutputFn(m.repoRoot, "rev-parse", last.Commit+"^{tree}")
+		lastParent, _ := gitOutputFn(m.repoRoot, "rev-parse", last.Commit+"^")
+		if lastTree == tree && lastParent == head {
+			return nil, errNoChanges
+		}
+	}
+
+	ref := checkpointRef(meta, next)
+	if _, err := gitOutputFn(m.repoRoot, "update-ref", ref, commit); err != nil {
+		return nil, fmt.Errorf("update checkpoint ref: %w", err)
+	}
+	return &checkpoint{
+		Number:    next,
+		Ref:       ref,
+		Commit:    commit,
+		CreatedAt: time.Now().Format(time.RFC3339),
+		Reason:    reason,
+	}, nil
+}
+
+func (m *manager) listCheckpoints(meta *sandboxMeta) ([]checkpoint, error) {
+	prefix := checkpointPrefix(meta)
+	out, err := gitOutputFn(m.repoRoot, "for-each-ref", "--format=%(refname)%09%(objectname)%09%(creatordate:iso-strict)%09%(contents:subject)", prefix)
+	if err != nil {
+		return nil, fmt.Errorf("list checkpoints: %w", err)
+	}
+
+	var checkpoints []checkpoint
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(fields[0], prefix))
+		if err != nil {
+			continue
+		}
+		checkpoints = append(checkpoints, checkpoint{
+			Number:    n,
+			Ref:       fields[0],
+			Commit:    fields[1],
+			CreatedAt: fields[2],
+			Reason:    strings.TrimPrefix(fields[3], "checkpoint: "),
+		})
+	}
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].Number < checkpoints[j].Number })
+	return checkpoints, nil
+}
+
+func (m *manager) restoreCheckpoint(meta *sandboxMeta, n int) (*checkpoint, error) {
+	checkpoints, err := m.listCheckpoints(meta)
+	if err != nil {
+		return nil, err
+	}
+	var target *checkpoint
+	for i := range checkpoints {
+		if checkpoints[i].Number == n {
+			target = &checkpoints[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("checkpoint %d not found for sandbox %q", n, meta.Name)
+	}
+	if _, err := os.Stat(meta.Worktree); err != nil {
+		return nil, fmt.Errorf("worktree missing: %s", meta.Worktree)
+	}
+	parent, err := gitOutputFn(m.repoRoot, "rev-parse", target.Commit+"^")
+	if err != nil {
+		return nil, fmt.Errorf("resolve checkpoint %d: %w", n, err)
+	}
+	if !m.inBranchHistory(meta.Branch, parent) {
+		return nil, fmt.Errorf("checkpoint %d was not taken on branch %s; refusing to restore it", n, meta.Branch)
+	}
+
+	saved, err := m.createCheckpoint(meta, fmt.Sprintf("before restore to #%d", n))
+	if err != nil && !errors.Is(err, errNoChanges) {
+		return nil, fmt.Errorf("checkpoint current state: %w", err)
+	}
+
+	steps := [][]string{
+		{"reset", "-q", "--hard", target.Commit + "^"},
+		{"clean", "-fdq"},
+		{"read-tree", "-u", "--reset", target.Commit},
+		{"reset", "-q"},
+	}
+	for _, args := range steps {
+		if _, err := gitOutputFn(meta.Worktree, args...); err != nil {
+			return saved, fmt.Errorf("restore checkpoint %d: %w", n, err)
+		}
+	}
+	return saved, nil
+}
+
+// inBranchHistory reports whether commit is on branch or was its tip at some
+// point, as it is after a tidy or a restore rewound the branch.
+func (m *manager) inBranchHistory(branch, commit string) bool {
+	ref := "refs/heads/" + branch
+	if _, err := gitOutputFn(m.repoRoot, "merge-base", "--is-ancestor", commit, ref); err == nil {
+		return true
+	}
+	out, err := gitOutputFn(m.repoRoot, "reflog", "show", "--format=%H", ref, "--")
+	if err != nil {
+		return false
+	}
+	for _, tip := range splitLines(out) {
+		if tip == commit {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *manager) runWithCheckpoints(meta *sandboxMeta, interval time.Duration, fn func() error) error {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if interval <= 0 {
+			<-stop
+			return
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				_, _ = m.createCheckpoint(meta, "periodic")
+			}
+		}
+	}()
+
+	runErr := fn()
+	close(stop)
+	<-done
+
+	cp, err := m.createCheckpoint(meta, "on exit")
+	switch {
+	case err == nil:
+		fmt.Printf("checkpoint #%d saved (%s)\n", cp.Number, cp.Ref)
+	case !errors.Is(err, errNoChanges):
+		fmt.Fprintf(os.Stderr, "warning: checkpoint failed: %v\n", err)
+	}
+	return runErr
+}
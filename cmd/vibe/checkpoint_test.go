@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateCheckpointCapturesDirtyAndUntracked(t *testing.T) {
+	m, meta := newGitSandbox(t, "cp")
+	if err := os.WriteFile(filepath.Join(meta.Worktree, "README.md"), []byte("changed\n"), 0o644); err != nil {
+		t.Fatalf("modify README: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(meta.Worktree, "new.txt"), []byte("new\n"), 0o644); err != nil {
+		t.Fatalf("write new.txt: %v", err)
+	}
+
+	cp, err := m.createCheckpoint(meta, "manual")
+	if err != nil {
+		t.Fatalf("createCheckpoint: %v", err)
+	}
+	if cp.Number != 1 || cp.Ref != checkpointRef(meta, 1) {
+		t.Fatalf("checkpoint = %+v, want #1", cp)
+	}
+	files, err := gitOutput(m.repoRoot, "ls-tree", "--name-only", cp.Ref)
+	if err != nil || !strings.Contains(files, "new.txt") {
+		t.Fatalf("checkpoint tree missing untracked file: %q (err=%v)", files, err)
+	}
+	status, err := gitOutput(meta.Worktree, "status", "--porcelain")
+	if err != nil || !strings.Contains(status, "?? new.txt") {
+		t.Fatalf("worktree index should be untouched, status=%q err=%v", status, err)
+	}
+
+	if _, err := m.createCheckpoint(meta, "again"); !errors.Is(err, errNoChanges) {
+		t.Fatalf("expected errNoChanges, got %v", err)
+	}
+}
+
+func TestRestoreCheckpoint(t *testing.T) {
+	m, meta := newGitSandbox(t, "rs")
+	if err := os.WriteFile(filepath.Join(meta.Worktree, "wip.txt"), []byte("wip\n"), 0o644); err != nil {
+		t.Fatalf("write wip: %v", err)
+	}
+	if _, err := m.createCheckpoint(meta, "first"); err != nil {
+		t.Fatalf("createCheckpoint: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(meta.Worktree, "wip.txt")); err != nil {
+		t.Fatalf("remove wip: %v", err)
+	}
+	commitFile(t, meta.Worktree, "later.txt", "later\n", "later work")
+
+	saved, err := m.restoreCheckpoint(meta, 1)
+	if err != nil {
+		t.Fatalf("restoreCheckpoint: %v", err)
+	}
+	if saved == nil || saved.Number != 2 {
+		t.Fatalf("expected pre-restore checkpoint #2, got %+v", saved)
+	}
+	if _, err := os.Stat(filepath.Join(meta.Worktree, "wip.txt")); err != nil {
+		t.Fatalf("wip.txt should be restored: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(meta.Worktree, "later.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("later.txt should be gone, stat err=%v", err)
+	}
+	status, _ := gitOutput(meta.Worktree, "status", "--porcelain")
+	if !strings.Contains(status, "?? wip.txt") {
+		t.Fatalf("wip.txt should be untracked again, status=%q", status)
+	}
+
+	checkpoints, err := m.listCheckpoints(meta)
+	if err != nil || len(checkpoints) != 2 || checkpoints[1].Reason != "before restore to #1" {
+		t.Fatalf("listCheckpoints = %+v (err=%v)", checkpoints, err)
+	}
+	if _, err := m.restoreCheckpoint(meta, 9); err == nil || !strings.Contains(err.Error(), "checkpoint 9 not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestRestoreCheckpointKeepsDeletedFilesDeleted(t *testing.T) {
+	m, meta := newGitSandbox(t, "del")
+	if err := os.Remove(filepath.Join(meta.Worktree, "README.md")); err != nil {
+		t.Fatalf("remove README: %v", err)
+	}
+	if _, err := m.createCheckpoint(meta, "deleted"); err != nil {
+		t.Fatalf("createCheckpoint: %v", err)
+	}
+	if _, err := gitOutput(meta.Worktree, "checkout", "--", "README.md"); err != nil {
+		t.Fatalf("bring README back: %v", err)
+	}
+
+	if _, err := m.restoreCheckpoint(meta, 1); err != nil {
+		t.Fatalf("restoreCheckpoint: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(meta.Worktree, "README.md")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("README.md was deleted in the checkpoint, stat err=%v", err)
+	}
+	if status, _ := gitOutput(meta.Worktree, "status", "--porcelain"); status != "D README.md" {
+		t.Fatalf("status = %q, want an unstaged deletion", status)
+	}
+}
+
+func TestRecreatedSandboxDoesNotInheritCheckpoints(t *testing.T) {
+	m, meta := newGitSandbox(t, "again")
+	commitFile(t, meta.Worktree, "old.txt", "old\n", "old work")
+	if err := os.WriteFile(filepath.Join(meta.Worktree, "wip.txt"), []byte("wip\n"), 0o644); err != nil {
+		t.Fatalf("write wip: %v", err)
+	}
+	if _, err := m.createCheckpoint(meta, "old"); err != nil {
+		t.Fatalf("createCheckpoint: %v", err)
+	}
+	if err := m.destroySandbox(meta, true, true); err != nil {
+		t.Fatalf("destroySandbox: %v", err)
+	}
+	if out, _ := gitOutput(m.repoRoot, "for-each-ref", checkpointRefPrefix); out != "" {
+		t.Fatalf("checkpoints should be deleted with the sandbox, got %q", out)
+	}
+
+	fresh, err := m.createSandbox("again", "main", "", sandboxOptions{})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	if checkpoints, err := m.listCheckpoints(fresh); err != nil || len(checkpoints) != 0 {
+		t.Fatalf("recreated sandbox inherited checkpoints: %+v (err=%v)", checkpoints, err)
+	}
+
+	// A checkpoint whose parent never was on the branch must not be
+	// restored.
+	oldWork, err := gitOutput(m.repoRoot, "commit-tree", "-p", "main", "-m", "checkpoint: old", "main^{tree}")
+	if err != nil {
+		t.Fatalf("commit-tree: %v", err)
+	}
+	unrelated, err := gitOutput(m.repoRoot, "commit-tree", "-p", oldWork, "-m", "checkpoint: old", "main^{tree}")
+	if err != nil {
+		t.Fatalf("commit-tree: %v", err)
+	}
+	if _, err := gitOutput(m.repoRoot, "update-ref", checkpointRef(fresh, 1), unrelated); err != nil {
+		t.Fatalf("update-ref: %v", err)
+	}
+	if _, err := m.restoreCheckpoint(fresh, 1); err == nil || !strings.Contains(err.Error(), "not taken on branch") {
+		t.Fatalf("expected unrelated checkpoint to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	sessionChecksReport = "checks.json"
+	maxCheckOutputSize  = 4000
+)
+
+type checkResult struct {
+	Name     string `json:"name"`
+	Command  string `json:"command"`
+	Passed   bool   `json:"passed"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+	Output   string `json:"output,omitempty"`
+}
+
+type checkReport struct {
+	Sandbox   string        `json:"sandbox"`
+	Commit    string        `json:"commit"`
+	CreatedAt string        `json:"created_at"`
+	Passed    bool          `json:"passed"`
+	Results   []checkResult `json:"results"`
+}
+
+func (r *checkReport) failed() []string {
+	var names []string
+	for _, res := range r.Results {
+		if !res.Passed {
+			names = append(names, res.Name)
+		}
+	}
+	return names
+}
+
+func (r *checkReport) summary() string {
+	var b strings.Builder
+	for _, res := range r.Results {
+		status := "passed"
+		if !res.Passed {
+			status = "failed"
+		}
+		fmt.Fprintf(&b, "- %s: %s (%s)\n", res.Name, status, res.Duration)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func (m *manager) checksReportPath(name string) string {
+	return filepath.Join(m.sessionDir(name), sessionChecksReport)
+}
+
+func (m *manager) runChecks(meta *sandboxMeta) (*checkReport, error) {
+	if m.config == nil || len(m.config.Checks.Commands) == 0 {
+		return nil, nil
+	}
+	// Checks run against the worktree, so uncommitted changes would make the
+	// report vouch for code that is not in the commit being pushed.
+	status, err := gitOutputFn(meta.Worktree, "status", "--porcelain")
+	if err != nil {
+		return nil, fmt.Errorf("check worktree status: %w", err)
+	}
+	if status != "" {
+		return nil, errors.New("worktree has uncommitted changes; commit or discard them before running checks")
+	}
+	runtime, err := resolveRuntimeSpec(meta.Worktree, m.config.Checks.Image, "", false, m.sandboxLabels(meta))
+	if err != nil {
+		return nil, fmt.Errorf("resolve checks runtime: %w", err)
+	}
+	if err := m.attachSession(meta, runtime); err != nil {
+		return nil, err
+	}
+
+	report := &checkReport{Sandbox: meta.Name, CreatedAt: time.Now().Format(time.RFC3339), Passed: true}
+	report.Commit, _ = gitOutputFn(meta.Worktree, "rev-parse", "HEAD")
+	for i, check := range m.config.Checks.Commands {
+		name := check.Name
+		if name == "" {
+			name = fmt.Sprintf("check-%d", i+1)
+		}
+		fmt.Printf("==> check %s: %s\n", name, check.Run)
+
+		var out strings.Builder
+		w := io.MultiWriter(os.Stdout, &out)
+		args := dockerRunArgs(meta, runtime, meta.Container+"-check", false, check.Run)
+		mounts, env := runAudit(args)
+		m.recordEvent(sandboxEvent{Type: eventRun, Sandbox: meta.Name, Image: runtime.Image, Command: check.Run, Mounts: mounts, Env: env})
+		start := time.Now()
+		runErr := runCommandFn("", w, w, "docker", args...)
+		code := exitCode(runErr)
+		m.recordEvent(sandboxEvent{Type: eventExit, Sandbox: meta.Name, Command: check.Run, ExitCode: &code})
+
+		res := checkResult{
+			Name:     name,
+			Command:  check.Run,
+			Passed:   runErr == nil,
+			Duration: time.Since(start).Round(100 * time.Millisecond).String(),
+			Output:   out.String(),
+		}
+		if len(res.Output) > maxCheckOutputSize {
+			res.Output = res.Output[len(res.Output)-maxCheckOutputSize:]
+		}
+		if runErr != nil {
+			res.Error = runErr.Error()
+			report.Passed = false
+		}
+		report.Results = append(report.Results, res)
+	}
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(m.checksReportPath(meta.Name), b, 0o644); err != nil {
+		return nil, fmt.Errorf("write checks report: %w", err)
+	}
+	return report, nil
+}
+
+func (m *manager) loadCheckReport(name string) (*checkReport, error) {
+	b, err := os.ReadFile(m.checksReportPath(name))
+	if err != nil {
+		return nil, err
+	}
+	var report checkReport
+	if err := json.Unmarshal(b, &report); err != nil {
+		return nil, fmt.Errorf("decode checks report: %w", err)
+	}
+	return &report, nil
+}
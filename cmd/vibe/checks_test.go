@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunChecksWritesReport(t *testing.T) {
+	origRun := runCommandFn
+	origGit := gitOutputFn
+	t.Cleanup(func() {
+		runCommandFn = origRun
+		gitOutputFn = origGit
+	})
+
+	m := newTestManager(t)
+	m.config = &vibeConfig{Checks: checksConfig{
+		Image: "golang:1.22",
+		Commands: []checkCommand{
+			{Name: "lint", Run: "go vet ./..."},
+			{Name: "test", Run: "go test ./..."},
+		},
+	}}
+	meta := &sandboxMeta{Name: "feat", Branch: "codex/feat", Worktree: t.TempDir(), Container: "codex-sb-feat"}
+
+	gitOutputFn = func(dir string, args ...string) (string, error) {
+		if args[0] == "status" {
+			return "", nil
+		}
+		return "abc123", nil
+	}
+	var calls [][]string
+	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error {
+		calls = append(calls, args)
+		if name != "docker" {
+			return fmt.Errorf("unexpected command %q", name)
+		}
+		fmt.Fprintln(stdout, "output of", args[len(args)-1])
+		if args[len(args)-1] == "go test ./..." {
+			return errors.New("exit status 1")
+		}
+		return nil
+	}
+
+	report, err := m.runChecks(meta)
+	if err != nil {
+		t.Fatalf("runChecks returned error: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("docker calls = %d, want 2", len(calls))
+	}
+	if containsArg(calls[0], "-it") || !containsPairArg(calls[0], "--name", "codex-sb-feat-check") || !runtimeHasSuffix(calls[0], []string{"golang:1.22", "bash", "-lc", "go vet ./..."}) {
+		t.Fatalf("unexpected docker args: %v", calls[0])
+	}
+	if report.Passed || report.Commit != "abc123" || !equalStrings(report.failed(), []string{"test"}) {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if !strings.Contains(report.Results[1].Output, "output of go test") || report.Results[1].Error == "" {
+		t.Fatalf("failed check result = %+v", report.Results[1])
+	}
+
+	saved, err := m.loadCheckReport("feat")
+	if err != nil {
+		t.Fatalf("loadCheckReport: %v", err)
+	}
+	if len(saved.Results) != 2 || saved.Results[0].Name != "lint" || !saved.Results[0].Passed {
+		t.Fatalf("saved report = %+v", saved)
+	}
+	if !strings.HasPrefix(report.summary(), "- lint: passed (") || !strings.Contains(report.summary(), "- test: failed (") {
+		t.Fatalf("summary = %q", report.summary())
+	}
+	if got := journalTypes(t, m, "feat"); got != "run exit run exit" {
+		t.Fatalf("journal after checks = %q", got)
+	}
+}
+
+func TestRunChecksWithoutConfig(t *testing.T) {
+	m := newTestManager(t)
+	report, err := m.runChecks(&sandboxMeta{Name: "feat"})
+	if err != nil || report != nil {
+		t.Fatalf("runChecks without config = %+v, %v", report, err)
+	}
+}
+
+func TestRunChecksRefusesDirtyWorktree(t *testing.T) {
+	origRun := runCommandFn
+	t.Cleanup(func() { runCommandFn = origRun })
+
+	m := newGitManager(t)
+	m.config = &vibeConfig{Checks: checksConfig{Commands: []checkCommand{{Name: "build", Run: "make"}}}}
+	meta, err := m.createSandbox("feat", "main", "", sandboxOptions{})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(meta.Worktree, "wip.txt"), []byte("wip\n"), 0o644); err != nil {
+		t.Fatalf("write wip: %v", err)
+	}
+	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error {
+		t.Fatalf("checks must not run on a dirty worktree: %s %v", name, args)
+		return nil
+	}
+
+	if _, err := m.runChecks(meta); err == nil || !strings.Contains(err.Error(), "uncommitted changes") {
+		t.Fatalf("expected dirty worktree error, got %v", err)
+	}
+}
+
+func TestCreatePRBlockedByFailingChecks(t *testing.T) {
+	origRun := runCommandFn
+	origGit := gitOutputFn
+	t.Cleanup(func() {
+		runCommandFn = origRun
+		gitOutputFn = origGit
+	})
+
+	t.Setenv("GH_TOKEN", "tok")
+	m := newTestManager(t)
+	m.config = &vibeConfig{Checks: checksConfig{Commands: []checkCommand{{Name: "build", Run: "make"}}}}
+	meta := &sandboxMeta{Name: "feat", Worktree: t.TempDir(), Branch: "codex/feat", BaseRef: "main"}
+	gitOutputFn = fakeGitRemote("https://github.com/acme/app.git", "")
+	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error {
+		if name == "git" {
+			t.Fatalf("branch must not be pushed when checks fail: %v", args)
+		}
+		return errors.New("exit status 2")
+	}
+
+	err := m.createPR(meta, prOptions{title: "t", body: "b"})
+	if err == nil || !strings.Contains(err.Error(), "checks failed: build") || !strings.Contains(err.Error(), "--skip-checks") {
+		t.Fatalf("expected checks failure, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.sessionDir("feat"), sessionChecksReport)); err != nil {
+		t.Fatalf("checks report not written: %v", err)
+	}
+}
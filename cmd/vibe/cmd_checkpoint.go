@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func newCheckpointsCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := checkpointsOptions{}
+	cmd := &cobra.Command{
+		Use:   "checkpoints",
+		Short: "List automatic checkpoints of a sandbox",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if opts.name == "" {
+				return errors.New("--name is required")
+			}
+			mgr, meta, err := openSandbox(rootOpts, opts.name)
+			if err != nil {
+				return err
+			}
+			checkpoints, err := mgr.listCheckpoints(meta)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 4, 2, 2, ' ', 0)
+			fmt.Fprintln(w, "N\tCREATED\tCOMMIT\tREASON")
+			for _, cp := range checkpoints {
+				fmt.Fprintf(w, "%d\t%s\t%.12s\t%s\n", cp.Number, cp.CreatedAt, cp.Commit, cp.Reason)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.name, "name", "", "sandbox name, or repo:name from anywhere")
+	return cmd
+}
+
+func newRestoreCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := restoreOptions{}
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Roll a sandbox worktree back to a checkpoint",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if opts.name == "" {
+				return errors.New("--name is required")
+			}
+			if opts.to <= 0 {
+				return errors.New("--to is required")
+			}
+			mgr, meta, err := openSandbox(rootOpts, opts.name)
+			if err != nil {
+				return err
+			}
+			saved, err := mgr.restoreCheckpoint(meta, opts.to)
+			if saved != nil {
+				fmt.Printf("previous state saved as checkpoint #%d\n", saved.Number)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Printf("restored sandbox %s to checkpoint #%d\n", meta.Name, opts.to)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.name, "name", "", "sandbox name, or repo:name from anywhere")
+	cmd.Flags().IntVar(&opts.to, "to", 0, "checkpoint number to restore")
+	return cmd
+}
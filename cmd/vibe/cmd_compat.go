@@ -22,12 +22,22 @@ func newCreateCmd(rootOpts *rootOptions) *cobra.Command {
 			if name == "" {
 				name = generateName()
 			}
-			baseRef, err := resolveBaseRef(mgr.repoRoot, opts.base)
+			var baseRef string
+			if opts.on != "" {
+				baseRef, err = mgr.resolveStackBase(opts.on, opts.base)
+			} else {
+				baseRef, err = resolveBaseRef(mgr.repoRoot, opts.base)
+			}
+			if err != nil {
+				return err
+			}
+
+			sparse, err := resolveSparsePaths(mgr.config, opts.sparse, opts.sparsePreset)
 			if err != nil {
 				return err
 			}
 
-			meta, err := mgr.createSandbox(name, baseRef, opts.branchPrefix)
+			meta, err := mgr.createSandbox(name, baseRef, opts.branchPrefix, sandboxOptions{Sparse: sparse, Parent: normalizeName(opts.on), TTL: opts.ttl})
 			if err != nil {
 				return err
 			}
@@ -39,7 +49,11 @@ func newCreateCmd(rootOpts *rootOptions) *cobra.Command {
 	}
 	cmd.Flags().StringVar(&opts.name, "name", "", "sandbox name (auto-generated if omitted)")
 	cmd.Flags().StringVar(&opts.base, "base", "", "base branch/ref (defaults to current branch)")
+	cmd.Flags().StringVar(&opts.on, "on", "", "stack the sandbox on another sandbox's branch")
 	cmd.Flags().StringVar(&opts.branchPrefix, "branch-prefix", defaultBranchPrefix, "sandbox branch prefix")
+	cmd.Flags().StringSliceVar(&opts.sparse, "sparse", nil, "limit the worktree to these directories (cone-mode sparse checkout)")
+	cmd.Flags().StringVar(&opts.sparsePreset, "sparse-preset", "", "sparse checkout preset from .vibe/config.json")
+	cmd.Flags().StringVar(&opts.ttl, "ttl", "", "expire the sandbox after this long without activity (default: sandbox.ttl)")
 	return cmd
 }
 
@@ -52,25 +66,25 @@ func newRunCmd(rootOpts *rootOptions) *cobra.Command {
 			if opts.name == "" {
 				return errors.New("--name is required")
 			}
-			mgr, err := newManager(rootOpts.sandboxRoot)
+			mgr, meta, err := openSandbox(rootOpts, opts.name)
 			if err != nil {
-				return fmt.Errorf("init failed: %w", err)
+				return err
 			}
-			meta, err := mgr.loadSandbox(opts.name)
+			runtime, err := resolveRuntimeSpec(meta.Worktree, opts.image, opts.devcontainer, cmd.Flags().Changed("devcontainer"), mgr.sandboxLabels(meta))
 			if err != nil {
 				return err
 			}
-			runtime, err := resolveRuntimeSpec(meta.Worktree, opts.image, opts.devcontainer, cmd.Flags().Changed("devcontainer"))
-			if err != nil {
+			if err := mgr.attachSession(meta, runtime); err != nil {
 				return err
 			}
-			return runOpenCodeContainer(meta, runtime, opts.command)
+			return mgr.launchSandbox(meta, runtime, opts.command, opts.checkpoint)
 		},
 	}
 	cmd.Flags().StringVar(&opts.name, "name", "", "sandbox name")
 	cmd.Flags().StringVar(&opts.image, "image", "", "docker image to run (overrides devcontainer image/build)")
 	cmd.Flags().StringVar(&opts.command, "cmd", defaultRunCommand, "command executed in container")
 	cmd.Flags().StringVar(&opts.devcontainer, "devcontainer", ".devcontainer/devcontainer.json", "devcontainer.json path relative to worktree")
+	cmd.Flags().DurationVar(&opts.checkpoint, "checkpoint-interval", defaultCheckpointInterval, "interval between automatic checkpoints (0 disables periodic checkpoints)")
 	return cmd
 }
 
@@ -89,7 +103,7 @@ func newDestroyCmd(rootOpts *rootOptions) *cobra.Command {
 				if opts.name != "" {
 					return errors.New("--name cannot be used with --all")
 				}
-				count, err := mgr.destroyAllSandboxes(opts.force, opts.deleteBranch)
+				count, err := mgr.destroyAllSandboxes(opts.force, opts.deleteBranch, nil)
 				if err != nil {
 					return err
 				}
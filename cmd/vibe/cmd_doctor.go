@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func newDoctorCmd(rootOpts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Report drift between sandbox metadata, worktrees, branches and containers",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			mgr, err := newManager(rootOpts.sandboxRoot)
+			if err != nil {
+				return fmt.Errorf("init failed: %w", err)
+			}
+			lock, err := mgr.lockStore(true)
+			if err != nil {
+				return err
+			}
+			defer lock.unlock()
+			issues, err := mgr.diagnose()
+			if err != nil {
+				return err
+			}
+			if len(issues) == 0 {
+				fmt.Println("doctor: no issues found")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 4, 2, 2, ' ', 0)
+			fmt.Fprintln(w, "KIND\tSUBJECT\tDETAIL\tFIX")
+			for _, issue := range issues {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", issue.Kind, issue.Subject, issue.Detail, issue.Fix)
+			}
+			w.Flush()
+			return fmt.Errorf("doctor: found %d issue(s); run `vibe prune --apply` to fix them", len(issues))
+		},
+	}
+}
+
+func newPruneCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := pruneOptions{}
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Fix the drift reported by doctor (dry run unless --apply)",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			mgr, err := newManager(rootOpts.sandboxRoot)
+			if err != nil {
+				return fmt.Errorf("init failed: %w", err)
+			}
+			lock, err := mgr.lockStore(true)
+			if err != nil {
+				return err
+			}
+			defer lock.unlock()
+			issues, err := mgr.diagnose()
+			if err != nil {
+				return err
+			}
+			fixed, err := mgr.pruneDrift(issues, opts.apply)
+			if !opts.apply {
+				fmt.Printf("prune: dry run, %d issue(s) found; rerun with --apply to fix them\n", len(issues))
+				return nil
+			}
+			fmt.Printf("prune: fixed %d of %d issue(s)\n", fixed, len(issues))
+			return err
+		},
+	}
+	cmd.Flags().BoolVar(&opts.apply, "apply", false, "apply the fixes instead of printing them")
+	return cmd
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -13,11 +14,23 @@ func newDoneCmd(rootOpts *rootOptions) *cobra.Command {
 		Use:   "done",
 		Short: "Cleanup sandbox resources (optionally create PR first)",
 		RunE: func(_ *cobra.Command, _ []string) error {
-			mgr, err := newManager(rootOpts.sandboxRoot)
-			if err != nil {
-				return fmt.Errorf("init failed: %w", err)
+			if opts.merged {
+				if opts.name != "" || opts.all {
+					return errors.New("--merged cannot be used with --name or --all")
+				}
+				if opts.createPR {
+					return errors.New("--pr cannot be used with --merged")
+				}
+				count, err := forEachManager(rootOpts, opts.global, func(mgr *manager) (int, error) {
+					return doneMerged(mgr, opts)
+				})
+				fmt.Printf("done: cleaned %d merged sandbox(es)\n", count)
+				return err
 			}
 
+			if opts.filter.active() && !opts.all {
+				return errors.New("--running, --dirty, --older-than and --base-ref require --all")
+			}
 			if opts.all {
 				if opts.name != "" {
 					return errors.New("--name cannot be used with --all")
@@ -25,23 +38,26 @@ func newDoneCmd(rootOpts *rootOptions) *cobra.Command {
 				if opts.createPR {
 					return errors.New("--pr cannot be used with --all")
 				}
-				count, err := mgr.destroyAllSandboxes(opts.force, opts.deleteBranch)
-				if err != nil {
-					return err
-				}
+				count, err := forEachManager(rootOpts, opts.global, func(mgr *manager) (int, error) {
+					return doneAll(mgr, opts)
+				})
 				fmt.Printf("done: cleaned %d sandbox(es)\n", count)
-				return nil
+				return err
 			}
 
+			if opts.global {
+				return errors.New("--global requires --all or --merged")
+			}
 			if opts.name == "" {
-				return errors.New("either --name or --all is required")
+				return errors.New("one of --name, --all or --merged is required")
 			}
-			meta, err := mgr.loadSandbox(opts.name)
+			mgr, meta, err := openSandbox(rootOpts, opts.name)
 			if err != nil {
 				return err
 			}
 			if opts.createPR {
-				if err := createPR(meta, opts.base, opts.title, opts.body, opts.draft); err != nil {
+				prOpts := prOptions{name: opts.name, base: opts.base, title: opts.title, body: opts.body, draft: opts.draft, remote: opts.remote, pushRemote: opts.pushRemote, edit: opts.edit, skipChecks: opts.skipChecks}
+				if err := mgr.createPR(meta, prOpts); err != nil {
 					return err
 				}
 			}
@@ -53,8 +69,11 @@ func newDoneCmd(rootOpts *rootOptions) *cobra.Command {
 			return nil
 		},
 	}
-	cmd.Flags().StringVar(&opts.name, "name", "", "sandbox name")
+	cmd.Flags().StringVar(&opts.name, "name", "", "sandbox name, or repo:name from anywhere")
 	cmd.Flags().BoolVar(&opts.all, "all", false, "cleanup all sandboxes")
+	addListFilterFlags(cmd, &opts.filter, "base-ref")
+	cmd.Flags().BoolVar(&opts.merged, "merged", false, "cleanup only sandboxes whose recorded PR was merged")
+	cmd.Flags().BoolVar(&opts.global, "global", false, "with --all or --merged, cleanup in every registered repository")
 	cmd.Flags().BoolVar(&opts.force, "force", false, "force remove dirty worktree")
 	cmd.Flags().BoolVar(&opts.deleteBranch, "delete-branch", true, "delete local branch after worktree removal")
 	cmd.Flags().BoolVar(&opts.createPR, "pr", false, "create PR before cleanup")
@@ -62,5 +81,75 @@ func newDoneCmd(rootOpts *rootOptions) *cobra.Command {
 	cmd.Flags().StringVar(&opts.title, "title", "", "PR title (used with --pr)")
 	cmd.Flags().StringVar(&opts.body, "body", "", "PR body (used with --pr)")
 	cmd.Flags().BoolVar(&opts.draft, "draft", false, "create draft PR (used with --pr)")
+	cmd.Flags().BoolVar(&opts.edit, "edit", false, "edit the PR body in $EDITOR before submitting (used with --pr)")
+	cmd.Flags().StringVar(&opts.remote, "remote", "", "git remote of the upstream repository (used with --pr or --merged, default: forge.remote or origin)")
+	cmd.Flags().BoolVar(&opts.skipChecks, "skip-checks", false, "do not run configured checks before pushing (used with --pr)")
+	cmd.Flags().StringVar(&opts.pushRemote, "push-remote", "", "git remote of a fork to push the branch to (used with --pr)")
 	return cmd
 }
+
+// forEachManager runs fn in the current repository, or with global in every
+// registered repository, and sums the cleaned sandboxes.
+func forEachManager(rootOpts *rootOptions, global bool, fn func(*manager) (int, error)) (int, error) {
+	var managers []*manager
+	if global {
+		all, err := globalManagers()
+		if err != nil {
+			return 0, err
+		}
+		managers = all
+	} else {
+		mgr, err := newManager(rootOpts.sandboxRoot)
+		if err != nil {
+			return 0, fmt.Errorf("init failed: %w", err)
+		}
+		managers = []*manager{mgr}
+	}
+
+	var (
+		total    int
+		failures []string
+	)
+	for _, mgr := range managers {
+		count, err := fn(mgr)
+		total += count
+		if err != nil {
+			if !global {
+				return total, err
+			}
+			failures = append(failures, fmt.Sprintf("%s: %v", mgr.repoRoot, err))
+		}
+	}
+	if len(failures) > 0 {
+		return total, errors.New(strings.Join(failures, "\n"))
+	}
+	return total, nil
+}
+
+func doneMerged(mgr *manager, opts doneOptions) (int, error) {
+	merged, err := mgr.mergedSandboxes(opts.remote)
+	if err != nil {
+		return 0, err
+	}
+	// Squash and rebase merges leave the local branch unmerged, so
+	// force deletion; unsaved work is still archived to trash first.
+	return mgr.destroyAllSandboxes(true, opts.deleteBranch, func(meta *sandboxMeta) bool {
+		return merged[meta.Name]
+	})
+}
+
+func doneAll(mgr *manager, opts doneOptions) (int, error) {
+	var filter func(*sandboxMeta) bool
+	if opts.filter.active() {
+		statuses, err := mgr.listStatuses(opts.filter, false)
+		if err != nil {
+			return 0, err
+		}
+		selected := map[string]bool{}
+		for _, s := range statuses {
+			selected[s.Name] = true
+		}
+		filter = func(meta *sandboxMeta) bool { return selected[meta.Name] }
+	}
+	return mgr.destroyAllSandboxes(opts.force, opts.deleteBranch, filter)
+}
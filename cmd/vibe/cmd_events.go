@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+)
+
+func newEventsCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := eventsOptions{}
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Show the audit journal of sandbox operations",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			mgr, err := newManager(rootOpts.sandboxRoot)
+			if err != nil {
+				return fmt.Errorf("init failed: %w", err)
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			return tailEvents(os.Stdout, mgr.eventsPath(), normalizeName(opts.name), opts.output, opts.follow, ctx.Done())
+		},
+	}
+	cmd.Flags().StringVar(&opts.name, "name", "", "only show events of this sandbox")
+	cmd.Flags().BoolVarP(&opts.follow, "follow", "f", false, "keep printing new events as they are recorded")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", outputTable, "output format: json")
+	return cmd
+}
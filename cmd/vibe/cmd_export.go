@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newExportCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := exportOptions{}
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export sandbox commits as patches, mbox or git bundle",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if opts.name == "" {
+				return errors.New("--name is required")
+			}
+			if opts.output == "" {
+				return errors.New("--output is required")
+			}
+			mgr, meta, err := openSandbox(rootOpts, opts.name)
+			if err != nil {
+				return err
+			}
+			out, err := mgr.exportSandbox(meta, opts.format, opts.output)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("exported sandbox %s (%s)\n", meta.Name, opts.format)
+			fmt.Printf("output:   %s\n", out)
+			fmt.Printf("metadata: %s\n", sidecarPath(out))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.name, "name", "", "sandbox name, or repo:name from anywhere")
+	cmd.Flags().StringVar(&opts.format, "format", exportFormatBundle, "export format: patch, mbox or bundle")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "", "output path (directory for patch, file for mbox/bundle)")
+	return cmd
+}
+
+func newImportCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := importOptions{}
+	cmd := &cobra.Command{
+		Use:   "import <bundle>",
+		Short: "Recreate a sandbox from an exported git bundle",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			mgr, err := newManager(rootOpts.sandboxRoot)
+			if err != nil {
+				return fmt.Errorf("init failed: %w", err)
+			}
+			bundle := args[0]
+			metaPath := opts.meta
+			if metaPath == "" {
+				metaPath = sidecarPath(bundle)
+			}
+			src, err := readSidecar(metaPath)
+			if err != nil {
+				return err
+			}
+			meta, err := mgr.importSandbox(bundle, src, normalizeName(opts.name), opts.branchPrefix)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("imported sandbox %s\n", meta.Name)
+			fmt.Printf("worktree: %s\n", meta.Worktree)
+			fmt.Printf("branch:   %s\n", meta.Branch)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.name, "name", "", "sandbox name (defaults to the exported name)")
+	cmd.Flags().StringVar(&opts.meta, "meta", "", "metadata sidecar path (default: <bundle>.json)")
+	cmd.Flags().StringVar(&opts.branchPrefix, "branch-prefix", defaultBranchPrefix, "sandbox branch prefix")
+	return cmd
+}
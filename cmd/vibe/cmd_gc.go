@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newGCCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := gcOptions{}
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Reap sandboxes whose TTL expired, archiving unsaved work to trash",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			count, err := forEachManager(rootOpts, opts.global, func(mgr *manager) (int, error) {
+				return mgr.collectGarbage(opts.dryRun)
+			})
+			if !opts.dryRun {
+				fmt.Printf("gc: reaped %d sandbox(es)\n", count)
+			}
+			return err
+		},
+	}
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "only print the sandboxes that would be reaped")
+	cmd.Flags().BoolVar(&opts.global, "global", false, "reap in every registered repository")
+	return cmd
+}
@@ -22,12 +22,22 @@ func newGoCmd(rootOpts *rootOptions) *cobra.Command {
 				name = generateName()
 			}
 
-			baseRef, err := resolveBaseRef(mgr.repoRoot, opts.base)
+			var baseRef string
+			if opts.on != "" {
+				baseRef, err = mgr.resolveStackBase(opts.on, opts.base)
+			} else {
+				baseRef, err = resolveBaseRef(mgr.repoRoot, opts.base)
+			}
 			if err != nil {
 				return err
 			}
 
-			meta, err := mgr.createSandbox(name, baseRef, opts.branchPrefix)
+			sparse, err := resolveSparsePaths(mgr.config, opts.sparse, opts.sparsePreset)
+			if err != nil {
+				return err
+			}
+
+			meta, err := mgr.createSandbox(name, baseRef, opts.branchPrefix, sandboxOptions{Sparse: sparse, Prompt: opts.prompt, Parent: normalizeName(opts.on), TTL: opts.ttl})
 			if err != nil {
 				return err
 			}
@@ -36,12 +46,15 @@ func newGoCmd(rootOpts *rootOptions) *cobra.Command {
 			fmt.Printf("worktree: %s\n", meta.Worktree)
 			fmt.Printf("branch:   %s\n", meta.Branch)
 
-			runtime, err := resolveRuntimeSpec(meta.Worktree, opts.image, opts.devcontainer, cmd.Flags().Changed("devcontainer"))
+			runtime, err := resolveRuntimeSpec(meta.Worktree, opts.image, opts.devcontainer, cmd.Flags().Changed("devcontainer"), mgr.sandboxLabels(meta))
 			if err != nil {
 				return fmt.Errorf("resolve runtime failed; sandbox is preserved, use `vibe done --name %s` to cleanup: %w", meta.Name, err)
 			}
 
-			if err := runOpenCodeContainer(meta, runtime, opts.command); err != nil {
+			if err := mgr.attachSession(meta, runtime); err != nil {
+				return err
+			}
+			if err := mgr.launchSandbox(meta, runtime, opts.command, opts.checkpoint); err != nil {
 				return fmt.Errorf("run opencode failed; sandbox is preserved, use `vibe done --name %s` to cleanup: %w", meta.Name, err)
 			}
 			return nil
@@ -49,9 +62,15 @@ func newGoCmd(rootOpts *rootOptions) *cobra.Command {
 	}
 	cmd.Flags().StringVar(&opts.name, "name", "", "sandbox name (auto-generated if omitted)")
 	cmd.Flags().StringVar(&opts.base, "base", "", "base branch/ref (defaults to current branch)")
+	cmd.Flags().StringVar(&opts.on, "on", "", "stack the sandbox on another sandbox's branch")
 	cmd.Flags().StringVar(&opts.branchPrefix, "branch-prefix", defaultBranchPrefix, "sandbox branch prefix")
+	cmd.Flags().StringSliceVar(&opts.sparse, "sparse", nil, "limit the worktree to these directories (cone-mode sparse checkout)")
+	cmd.Flags().StringVar(&opts.sparsePreset, "sparse-preset", "", "sparse checkout preset from .vibe/config.json")
+	cmd.Flags().StringVar(&opts.ttl, "ttl", "", "expire the sandbox after this long without activity, e.g. 72h or 7d (default: sandbox.ttl)")
+	cmd.Flags().StringVar(&opts.prompt, "prompt", "", "task prompt for the agent (recorded for the PR description)")
 	cmd.Flags().StringVar(&opts.image, "image", "", "docker image to run (overrides devcontainer image/build)")
 	cmd.Flags().StringVar(&opts.command, "cmd", defaultRunCommand, "command executed in container")
 	cmd.Flags().StringVar(&opts.devcontainer, "devcontainer", ".devcontainer/devcontainer.json", "devcontainer.json path relative to worktree")
+	cmd.Flags().DurationVar(&opts.checkpoint, "checkpoint-interval", defaultCheckpointInterval, "interval between automatic checkpoints (0 disables periodic checkpoints)")
 	return cmd
 }
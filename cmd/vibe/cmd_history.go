@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func newHistoryCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := historyOptions{}
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show destroyed sandboxes (requires the sqlite store)",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			mgr, err := newManager(rootOpts.sandboxRoot)
+			if err != nil {
+				return fmt.Errorf("init failed: %w", err)
+			}
+			entries, err := mgr.history(normalizeName(opts.name))
+			if err != nil {
+				return err
+			}
+			return writeHistory(os.Stdout, entries, opts.output)
+		},
+	}
+	cmd.Flags().StringVar(&opts.name, "name", "", "only show this sandbox name")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", outputTable, "output format: json")
+	return cmd
+}
@@ -1,47 +1,69 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"sort"
-	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 func newListCmd(rootOpts *rootOptions) *cobra.Command {
-	return &cobra.Command{
+	opts := listOptions{}
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List all sandboxes",
 		RunE: func(_ *cobra.Command, _ []string) error {
-			mgr, err := newManager(rootOpts.sandboxRoot)
-			if err != nil {
-				return fmt.Errorf("init failed: %w", err)
+			if opts.global {
+				if opts.tree {
+					return errors.New("--tree cannot be combined with --global")
+				}
+				statuses, err := globalStatuses(opts.filter, opts.output != outputTable)
+				if err != nil {
+					return err
+				}
+				writeExpiryWarnings(os.Stderr, statuses, time.Now())
+				return writeStatuses(os.Stdout, statuses, opts.output)
 			}
 
-			metas, err := mgr.listSandboxes()
+			mgr, err := newManager(rootOpts.sandboxRoot)
 			if err != nil {
-				return err
+				return fmt.Errorf("init failed: %w", err)
 			}
-			sort.Slice(metas, func(i, j int) bool { return metas[i].Name < metas[j].Name })
 
-			running := runningContainers()
-			w := tabwriter.NewWriter(os.Stdout, 4, 2, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tBRANCH\tBASE\tWORKTREE\tRUNNING")
-			for _, meta := range metas {
-				_, err := os.Stat(meta.Worktree)
-				exists := err == nil
-				status := "no"
-				if running[meta.Container] {
-					status = "yes"
+			if opts.tree {
+				if opts.output != outputTable || opts.filter.active() {
+					return errors.New("--tree cannot be combined with --output or filters")
 				}
-				if !exists {
-					status = "missing-worktree"
+				metas, err := mgr.listSandboxes()
+				if err != nil {
+					return err
 				}
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", meta.Name, meta.Branch, meta.BaseRef, meta.Worktree, status)
+				sort.Slice(metas, func(i, j int) bool { return metas[i].Name < metas[j].Name })
+				printSandboxTree(os.Stdout, metas)
+				return nil
 			}
-			w.Flush()
-			return nil
+
+			statuses, err := mgr.listStatuses(opts.filter, opts.output != outputTable)
+			if err != nil {
+				return err
+			}
+			writeExpiryWarnings(os.Stderr, statuses, time.Now())
+			return writeStatuses(os.Stdout, statuses, opts.output)
 		},
 	}
+	cmd.Flags().BoolVar(&opts.tree, "tree", false, "show stacked sandboxes as a tree")
+	cmd.Flags().BoolVar(&opts.global, "global", false, "list sandboxes of every registered repository")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", outputTable, "output format: json, yaml, wide or go-template=<template>")
+	addListFilterFlags(cmd, &opts.filter, "base")
+	return cmd
+}
+
+func addListFilterFlags(cmd *cobra.Command, filter *listFilter, baseFlag string) {
+	cmd.Flags().BoolVar(&filter.running, "running", false, "only sandboxes with a running container")
+	cmd.Flags().BoolVar(&filter.dirty, "dirty", false, "only sandboxes with uncommitted changes")
+	cmd.Flags().StringVar(&filter.olderThan, "older-than", "", "only sandboxes created before this age (e.g. 7d, 12h)")
+	cmd.Flags().StringVar(&filter.base, baseFlag, "", "only sandboxes based on this ref")
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newMigrateCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := migrateOptions{}
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Move legacy .codex-sandboxes into the current layout and upgrade sandbox metadata",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if rootOpts.sandboxRoot != "" {
+				return fmt.Errorf("migrate works on the default sandbox root; drop --sandbox-root")
+			}
+			repoRoot, err := detectRepoRoot()
+			if err != nil {
+				return err
+			}
+			moved, err := migrateLegacyLayout(repoRoot, opts.dryRun)
+			if err != nil {
+				return err
+			}
+			mgr, err := newManager("")
+			if err != nil {
+				return fmt.Errorf("init failed: %w", err)
+			}
+			upgraded, err := mgr.upgradeSandboxes(opts.dryRun)
+			if err != nil {
+				return err
+			}
+			if opts.dryRun {
+				fmt.Printf("migrate: dry run, %d legacy sandbox(es) to move, %d sandbox(es) to upgrade\n", moved, upgraded)
+				return nil
+			}
+			fmt.Printf("migrate: moved %d legacy sandbox(es); upgraded %d sandbox(es) to schema version %d\n", moved, upgraded, currentSchemaVersion)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "only print what would be migrated")
+	return cmd
+}
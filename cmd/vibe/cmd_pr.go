@@ -3,6 +3,8 @@ package main
 import (
 	"errors"
 	"fmt"
+	"os"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 )
@@ -16,21 +18,64 @@ func newPRCmd(rootOpts *rootOptions) *cobra.Command {
 			if opts.name == "" {
 				return errors.New("--name is required")
 			}
-			mgr, err := newManager(rootOpts.sandboxRoot)
-			if err != nil {
-				return fmt.Errorf("init failed: %w", err)
-			}
-			meta, err := mgr.loadSandbox(opts.name)
+			mgr, meta, err := openSandbox(rootOpts, opts.name)
 			if err != nil {
 				return err
 			}
-			return createPR(meta, opts.base, opts.title, opts.body, opts.draft)
+			if opts.stack {
+				return mgr.createStackPRs(meta, opts)
+			}
+			return mgr.createPR(meta, opts)
 		},
 	}
-	cmd.Flags().StringVar(&opts.name, "name", "", "sandbox name")
+	cmd.Flags().StringVar(&opts.name, "name", "", "sandbox name, or repo:name from anywhere")
 	cmd.Flags().StringVar(&opts.base, "base", "", "target base branch")
 	cmd.Flags().StringVar(&opts.title, "title", "", "PR title")
 	cmd.Flags().StringVar(&opts.body, "body", "", "PR body")
 	cmd.Flags().BoolVar(&opts.draft, "draft", false, "create draft PR")
+	cmd.Flags().BoolVar(&opts.skipChecks, "skip-checks", false, "do not run configured checks before pushing")
+	cmd.Flags().BoolVar(&opts.stack, "stack", false, "also open PRs for parent sandboxes that have none, bottom-up")
+	cmd.Flags().BoolVar(&opts.edit, "edit", false, "edit the PR body in $EDITOR before submitting")
+	cmd.Flags().StringVar(&opts.remote, "remote", "", "git remote of the upstream repository (default: forge.remote or origin)")
+	cmd.Flags().StringVar(&opts.pushRemote, "push-remote", "", "git remote of a fork to push the branch to (default: forge.push_remote, or auto-fork without push access)")
+	cmd.AddCommand(newPRStatusCmd(rootOpts))
+	return cmd
+}
+
+func newPRStatusCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := prStatusOptions{}
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show state, review and checks of recorded sandbox PRs",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			mgr, err := newManager(rootOpts.sandboxRoot)
+			if err != nil {
+				return fmt.Errorf("init failed: %w", err)
+			}
+			statuses, err := mgr.prStatuses(opts.remote)
+			if err != nil {
+				return err
+			}
+			if len(statuses) == 0 {
+				fmt.Println("no sandboxes with a recorded PR")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 4, 2, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tPR\tSTATE\tREVIEW\tCHECKS\tURL")
+			for _, s := range statuses {
+				state, review, checks := "error", "-", "-"
+				if s.Err != nil {
+					fmt.Fprintf(os.Stderr, "warning: %s: %v\n", s.Meta.Name, s.Err)
+				} else {
+					state, review, checks = s.Status.State, s.Status.Review, s.Status.Checks
+				}
+				fmt.Fprintf(w, "%s\t#%d\t%s\t%s\t%s\t%s\n", s.Meta.Name, s.Meta.PRNumber, state, review, checks, s.Meta.PRURL)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.remote, "remote", "", "git remote of the forge (default: forge.remote or origin)")
 	return cmd
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func newStatusCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := statusOptions{}
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show a detailed health report for one sandbox",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if opts.name == "" {
+				return errors.New("--name is required")
+			}
+			mgr, meta, err := openSandbox(rootOpts, opts.name)
+			if err != nil {
+				return err
+			}
+			return writeHealth(os.Stdout, mgr.sandboxHealth(*meta), opts.output)
+		},
+	}
+	cmd.Flags().StringVar(&opts.name, "name", "", "sandbox name, or repo:name from anywhere")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", outputTable, "output format: json")
+	return cmd
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newSyncCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := syncOptions{}
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Rebase stacked sandboxes onto their updated parents",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			mgr, err := newManager(rootOpts.sandboxRoot)
+			if err != nil {
+				return fmt.Errorf("init failed: %w", err)
+			}
+			count, err := mgr.syncStacks(normalizeName(opts.name))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("sync: rebased %d sandbox(es)\n", count)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.name, "name", "", "sync only this sandbox and its descendants")
+	return cmd
+}
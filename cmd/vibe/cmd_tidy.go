@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newTidyCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := tidyOptions{}
+	cmd := &cobra.Command{
+		Use:   "tidy",
+		Short: "Squash or group the sandbox branch commits before a PR",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if opts.name == "" {
+				return errors.New("--name is required")
+			}
+			mgr, meta, err := openSandbox(rootOpts, opts.name)
+			if err != nil {
+				return err
+			}
+
+			result, err := mgr.tidySandbox(meta, opts.strategy, opts.message, opts.dryRun)
+			if err != nil {
+				return err
+			}
+			if len(result.Groups) == 0 {
+				fmt.Printf("nothing to tidy: %d commit(s) on %s\n", result.Before, meta.Branch)
+				return nil
+			}
+			for i, g := range result.Groups {
+				subject, _, _ := strings.Cut(g.Message, "\n")
+				fmt.Printf("%d. %s (%d commit(s), %d file(s))\n", i+1, subject, len(g.Commits), len(g.Files))
+			}
+			if opts.dryRun {
+				fmt.Printf("dry run: would rewrite %d commit(s) into %d\n", result.Before, len(result.Groups))
+				return nil
+			}
+			fmt.Printf("tidied %d commit(s) into %d on %s\n", result.Before, len(result.Groups), meta.Branch)
+			if result.Checkpoint != nil {
+				fmt.Printf("restore the original history with: vibe restore --name %s --to %d\n", meta.Name, result.Checkpoint.Number)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.name, "name", "", "sandbox name, or repo:name from anywhere")
+	cmd.Flags().StringVar(&opts.strategy, "strategy", tidySquash, "squash into one commit, or group commits by the files they touch (squash|group)")
+	cmd.Flags().StringVarP(&opts.message, "message", "m", "", "commit message for --strategy squash (generated if omitted)")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "show the planned commits without rewriting")
+	return cmd
+}
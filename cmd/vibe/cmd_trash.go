@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func newTrashCmd(rootOpts *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trash",
+		Short: "Inspect, restore or purge work archived by done",
+	}
+	cmd.AddCommand(newTrashListCmd(rootOpts))
+	cmd.AddCommand(newTrashRestoreCmd(rootOpts))
+	cmd.AddCommand(newTrashPurgeCmd(rootOpts))
+	return cmd
+}
+
+func newTrashListCmd(rootOpts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List archived sandboxes",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			mgr, err := newManager(rootOpts.sandboxRoot)
+			if err != nil {
+				return fmt.Errorf("init failed: %w", err)
+			}
+			entries, err := mgr.listTrash()
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 4, 2, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tBRANCH\tUNPUSHED\tCHANGED\tUNTRACKED\tCREATED")
+			for _, entry := range entries {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n", entry.ID, entry.Sandbox.Branch, entry.Unpushed, len(entry.Changed), len(entry.Untracked), entry.CreatedAt)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+func newTrashRestoreCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := trashRestoreOptions{}
+	cmd := &cobra.Command{
+		Use:   "restore <id>",
+		Short: "Recreate a sandbox from an archive",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			mgr, err := newManager(rootOpts.sandboxRoot)
+			if err != nil {
+				return fmt.Errorf("init failed: %w", err)
+			}
+			entry, err := mgr.loadTrashEntry(args[0])
+			if err != nil {
+				return err
+			}
+			meta, err := mgr.restoreTrash(entry, normalizeName(opts.name))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("restored sandbox %s\n", meta.Name)
+			fmt.Printf("worktree: %s\n", meta.Worktree)
+			fmt.Printf("branch:   %s\n", meta.Branch)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.name, "name", "", "sandbox name (defaults to the archived name)")
+	return cmd
+}
+
+func newTrashPurgeCmd(rootOpts *rootOptions) *cobra.Command {
+	opts := trashPurgeOptions{}
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Delete archives older than the configured retention",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			mgr, err := newManager(rootOpts.sandboxRoot)
+			if err != nil {
+				return fmt.Errorf("init failed: %w", err)
+			}
+			count, err := mgr.purgeTrash(opts.all)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("purged %d archive(s)\n", count)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&opts.all, "all", false, "purge every archive regardless of age")
+	return cmd
+}
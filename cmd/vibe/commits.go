@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+)
+
+const (
+	sessionHooksDir      = "hooks"
+	containerSSHAgent    = "/run/vibe/ssh-agent.sock"
+	dockerDesktopSSHSock = "/run/host-services/ssh-auth.sock"
+)
+
+var shimmedHooks = []string{
+	"applypatch-msg", "pre-applypatch", "post-applypatch",
+	"pre-commit", "pre-merge-commit", "prepare-commit-msg", "commit-msg", "post-commit",
+	"pre-rebase", "post-checkout", "post-merge", "pre-push", "post-rewrite",
+}
+
+// The shims keep the repository's own hooks working while core.hooksPath
+// points at the session directory.
+const hookShim = `#!/bin/sh
+dir="${VIBE_REPO_HOOKS_PATH:-}"
+if [ -z "$dir" ]; then
+	dir="$(git rev-parse --git-common-dir)/hooks"
+fi
+case "$dir" in
+/*) ;;
+*) dir="$(git rev-parse --show-toplevel)/$dir" ;;
+esac
+hook="$dir/$(basename "$0")"
+if [ -x "$hook" ]; then
+	"$hook" "$@" || exit $?
+fi
+`
+
+func commitMsgHook(trailers []string) string {
+	var b strings.Builder
+	b.WriteString(hookShim)
+	b.WriteString(`git interpret-trailers --in-place --if-exists addIfDifferent`)
+	for _, trailer := range trailers {
+		b.WriteString(" --trailer " + shellQuote(trailer))
+	}
+	b.WriteString(` "$1"` + "\n")
+	return b.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (c commitsConfig) trailers(sandbox string) []string {
+	trailers := []string{"Sandbox: " + sandbox}
+	if c.CoAuthor != "" {
+		trailers = append(trailers, "Co-authored-by: "+c.CoAuthor)
+	}
+	return trailers
+}
+
+func (m *manager) commitsConfig() commitsConfig {
+	if m.config == nil {
+		return commitsConfig{}
+	}
+	return m.config.Commits
+}
+
+func (m *manager) writeSessionHooks(meta *sandboxMeta) (string, error) {
+	dir := filepath.Join(m.sessionDir(meta.Name), sessionHooksDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create hooks dir: %w", err)
+	}
+	for _, name := range shimmedHooks {
+		content := hookShim
+		if name == "commit-msg" {
+			content = commitMsgHook(m.commitsConfig().trailers(meta.Name))
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o755); err != nil {
+			return "", fmt.Errorf("write %s hook: %w", name, err)
+		}
+	}
+	return dir, nil
+}
+
+var publicKeyPrefixes = []string{"ssh-", "ecdsa-sha2-", "sk-ssh-", "sk-ecdsa-sha2-"}
+
+func isPublicKey(key string) bool {
+	for _, prefix := range publicKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSigningKey returns the key:: literal for user.signingkey. The value
+// ends up in the container environment, so only public keys are accepted.
+func resolveSigningKey(key string) (string, error) {
+	if strings.Contains(key, "PRIVATE KEY") {
+		return "", errors.New("signing key looks like a private key; configure the public key or its .pub file")
+	}
+	if literal := strings.TrimPrefix(key, "key::"); isPublicKey(literal) {
+		return "key::" + literal, nil
+	}
+	if strings.HasPrefix(key, "key::") {
+		return "", fmt.Errorf("signing key %q is not an SSH public key", key)
+	}
+	if rest, ok := strings.CutPrefix(key, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		key = filepath.Join(home, rest)
+	}
+	if filepath.Ext(key) != ".pub" {
+		return "", fmt.Errorf("signing key %s is not a .pub file", key)
+	}
+	b, err := os.ReadFile(key)
+	if err != nil {
+		return "", fmt.Errorf("read signing key: %w", err)
+	}
+	content := strings.TrimSpace(string(b))
+	if strings.Contains(content, "PRIVATE KEY") || !isPublicKey(content) {
+		return "", fmt.Errorf("signing key %s is not an SSH public key", key)
+	}
+	return "key::" + content, nil
+}
+
+func sshAgentSocket() string {
+	if goruntime.GOOS == "darwin" {
+		return dockerDesktopSSHSock
+	}
+	return os.Getenv("SSH_AUTH_SOCK")
+}
+
+func (m *manager) applyCommitPolicy(meta *sandboxMeta, runtime *runtimeSpec) error {
+	cfg := m.commitsConfig()
+	if _, err := m.writeSessionHooks(meta); err != nil {
+		return err
+	}
+
+	gitConfig := [][2]string{{"core.hooksPath", sessionMountPath + "/" + sessionHooksDir}}
+	if hooksPath, err := gitOutputFn(meta.Worktree, "config", "core.hooksPath"); err == nil && hooksPath != "" {
+		runtime.ContainerEnv["VIBE_REPO_HOOKS_PATH"] = hooksPath
+	}
+	if cfg.SigningKey != "" {
+		key, err := resolveSigningKey(cfg.SigningKey)
+		if err != nil {
+			return err
+		}
+		gitConfig = append(gitConfig,
+			[2]string{"gpg.format", "ssh"},
+			[2]string{"user.signingkey", key},
+			[2]string{"commit.gpgsign", "true"},
+		)
+	}
+	runtime.ContainerEnv["GIT_CONFIG_COUNT"] = fmt.Sprint(len(gitConfig))
+	for i, kv := range gitConfig {
+		runtime.ContainerEnv[fmt.Sprintf("GIT_CONFIG_KEY_%d", i)] = kv[0]
+		runtime.ContainerEnv[fmt.Sprintf("GIT_CONFIG_VALUE_%d", i)] = kv[1]
+	}
+
+	identity := map[string]string{
+		"GIT_AUTHOR_NAME":     cfg.AuthorName,
+		"GIT_AUTHOR_EMAIL":    cfg.AuthorEmail,
+		"GIT_COMMITTER_NAME":  cfg.CommitterName,
+		"GIT_COMMITTER_EMAIL": cfg.CommitterEmail,
+	}
+	for k, v := range identity {
+		if v != "" {
+			runtime.ContainerEnv[k] = v
+		}
+	}
+
+	if cfg.SigningKey != "" || cfg.ForwardSSHAgent {
+		sock := sshAgentSocket()
+		if sock == "" {
+			return errors.New("commit signing needs an SSH agent; start one and set SSH_AUTH_SOCK")
+		}
+		runtime.Mounts = append(runtime.Mounts, fmt.Sprintf("type=bind,source=%s,target=%s", sock, containerSSHAgent))
+		runtime.ContainerEnv["SSH_AUTH_SOCK"] = containerSSHAgent
+	}
+	return nil
+}
+
+type policyViolation struct {
+	Commit string
+	Reason string
+}
+
+func (m *manager) checkCommitPolicy(meta *sandboxMeta, base string) ([]policyViolation, error) {
+	cfg := m.commitsConfig()
+	if !cfg.enabled() {
+		return nil, nil
+	}
+	out, err := gitOutputFn(meta.Worktree, "log", "--format=%H%x1f%h%x1f%ae%x1f%ce%x1f%(trailers:only,unfold)%x1e", base+".."+meta.Branch)
+	if err != nil {
+		return nil, fmt.Errorf("list commits: %w", err)
+	}
+
+	var violations []policyViolation
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.SplitN(strings.TrimSpace(record), "\x1f", 5)
+		if len(fields) < 4 {
+			continue
+		}
+		full, short, authorEmail, committerEmail := fields[0], fields[1], fields[2], fields[3]
+		trailers := ""
+		if len(fields) == 5 {
+			trailers = fields[4]
+		}
+		violate := func(format string, args ...any) {
+			violations = append(violations, policyViolation{Commit: short, Reason: fmt.Sprintf(format, args...)})
+		}
+		if cfg.AuthorEmail != "" && !strings.EqualFold(authorEmail, cfg.AuthorEmail) {
+			violate("author %s is not %s", authorEmail, cfg.AuthorEmail)
+		}
+		if cfg.CommitterEmail != "" && !strings.EqualFold(committerEmail, cfg.CommitterEmail) {
+			violate("committer %s is not %s", committerEmail, cfg.CommitterEmail)
+		}
+		for _, trailer := range cfg.trailers(meta.Name) {
+			if !hasTrailer(trailers, trailer) {
+				violate("missing trailer %q", trailer)
+			}
+		}
+		if cfg.SigningKey != "" {
+			raw, err := gitOutputFn(meta.Worktree, "cat-file", "commit", full)
+			if err != nil {
+				return nil, fmt.Errorf("read commit %s: %w", short, err)
+			}
+			header, _, _ := strings.Cut(raw, "\n\n")
+			if !strings.Contains(header, "\ngpgsig ") {
+				violate("commit is not signed")
+			}
+		}
+	}
+	return violations, nil
+}
+
+func hasTrailer(trailers, want string) bool {
+	for _, line := range strings.Split(trailers, "\n") {
+		if strings.EqualFold(strings.TrimSpace(line), want) {
+			return true
+		}
+	}
+	return false
+}
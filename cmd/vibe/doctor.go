@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	driftStaleTmp         = "stale-tmp"
+	driftMissingWorktree  = "missing-worktree"
+	driftUnregistered     = "unregistered-worktree"
+	driftMissingBranch    = "missing-branch"
+	driftOrphanWorktree   = "orphan-worktree"
+	driftPrunableWorktree = "prunable-worktree"
+	driftOrphanBranch     = "orphan-branch"
+	driftOrphanContainer  = "orphan-container"
+	driftOrphanSession    = "orphan-session"
+
+	staleTmpAge = time.Minute
+)
+
+type driftIssue struct {
+	Kind    string
+	Subject string
+	Detail  string
+	Fix     string
+	apply   func() error
+}
+
+type worktreeEntry struct {
+	Path     string
+	Branch   string
+	Prunable bool
+}
+
+func listWorktrees(repoRoot string) ([]worktreeEntry, error) {
+	out, err := gitOutputFn(repoRoot, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, fmt.Errorf("list worktrees: %w", err)
+	}
+	var (
+		entries []worktreeEntry
+		current *worktreeEntry
+	)
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "worktree "):
+			entries = append(entries, worktreeEntry{Path: strings.TrimPrefix(line, "worktree ")})
+			current = &entries[len(entries)-1]
+		case current == nil:
+		case strings.HasPrefix(line, "branch "):
+			current.Branch = strings.TrimPrefix(strings.TrimPrefix(line, "branch "), "refs/heads/")
+		case line == "prunable" || strings.HasPrefix(line, "prunable "):
+			current.Prunable = true
+		}
+	}
+	return entries, nil
+}
+
+func (m *manager) diagnose() ([]driftIssue, error) {
+	metas, err := m.listSandboxes()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(metas, func(i, j int) bool { return metas[i].Name < metas[j].Name })
+	worktrees, err := listWorktrees(m.repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []driftIssue
+	issues = append(issues, m.staleTmpIssues()...)
+
+	registered := map[string]worktreeEntry{}
+	for _, wt := range worktrees {
+		registered[canonicalPath(wt.Path)] = wt
+	}
+	known := map[string]bool{}
+	branches := map[string]bool{}
+	for i := range metas {
+		meta := metas[i]
+		known[canonicalPath(meta.Worktree)] = true
+		branches[meta.Branch] = true
+
+		if _, err := os.Stat(meta.Worktree); err != nil {
+			issues = append(issues, driftIssue{
+				Kind:    driftMissingWorktree,
+				Subject: meta.Name,
+				Detail:  fmt.Sprintf("metadata points at missing worktree %s", meta.Worktree),
+				Fix:     "archive unpushed branch commits to trash, delete the branch and drop the metadata",
+				apply:   func() error { return m.dropDeadSandbox(&meta) },
+			})
+			continue
+		}
+		if _, ok := registered[canonicalPath(meta.Worktree)]; !ok {
+			issues = append(issues, driftIssue{
+				Kind:    driftUnregistered,
+				Subject: meta.Name,
+				Detail:  fmt.Sprintf("%s is not listed by `git worktree list`", meta.Worktree),
+				Fix:     "git worktree repair " + meta.Worktree,
+				apply: func() error {
+					_, err := gitOutputFn(m.repoRoot, "worktree", "repair", meta.Worktree)
+					return err
+				},
+			})
+		}
+		if !m.branchExists(meta.Branch) {
+			issues = append(issues, driftIssue{
+				Kind:    driftMissingBranch,
+				Subject: meta.Name,
+				Detail:  fmt.Sprintf("branch %s does not exist", meta.Branch),
+				Fix:     fmt.Sprintf("manual: run `vibe status --name %s`", meta.Name),
+			})
+		}
+	}
+
+	// Orphans have no recorded base; measure unpushed work against the
+	// current branch so its history is not counted as sandbox commits.
+	base, _ := resolveBaseRef(m.repoRoot, "")
+	sandboxRoot := canonicalPath(m.sandboxRoot) + string(filepath.Separator)
+	checkedOut := map[string]bool{}
+	for _, wt := range worktrees {
+		checkedOut[wt.Branch] = true
+		if p := canonicalPath(wt.Path); !strings.HasPrefix(p, sandboxRoot) || known[p] {
+			continue
+		}
+		if wt.Prunable {
+			issues = append(issues, driftIssue{
+				Kind:    driftPrunableWorktree,
+				Subject: wt.Path,
+				Detail:  "git tracks a worktree whose directory is gone",
+				Fix:     "git worktree prune",
+				apply: func() error {
+					_, err := gitOutputFn(m.repoRoot, "worktree", "prune")
+					return err
+				},
+			})
+			continue
+		}
+		issues = append(issues, driftIssue{
+			Kind:    driftOrphanWorktree,
+			Subject: wt.Path,
+			Detail:  fmt.Sprintf("worktree on branch %s has no sandbox metadata", wt.Branch),
+			Fix:     "archive unsaved work to trash and remove the worktree and branch",
+			apply: func() error {
+				return m.removeLeftovers(&sandboxMeta{Name: filepath.Base(wt.Path), Branch: wt.Branch, BaseRef: base, Worktree: wt.Path})
+			},
+		})
+	}
+
+	recorded, err := m.recordedBranches()
+	if err != nil {
+		return nil, err
+	}
+	for _, branch := range recorded {
+		if branches[branch] || checkedOut[branch] || !m.branchExists(branch) {
+			continue
+		}
+		issues = append(issues, driftIssue{
+			Kind:    driftOrphanBranch,
+			Subject: branch,
+			Detail:  "branch of a removed sandbox was left behind",
+			Fix:     "archive unpushed commits to trash and delete the branch",
+			apply:   func() error { return m.dropOrphanBranch(branch, base) },
+		})
+	}
+
+	for _, container := range m.repoContainers("") {
+		if m.sandboxExists(container.Sandbox) {
+			continue
+		}
+		issues = append(issues, driftIssue{
+			Kind:    driftOrphanContainer,
+			Subject: container.Name,
+			Detail:  fmt.Sprintf("container of sandbox %q has no sandbox metadata", container.Sandbox),
+			Fix:     "docker rm -f " + container.Name,
+			apply: func() error {
+				_, err := commandOutputFn("", "docker", "rm", "-f", container.Name)
+				return err
+			},
+		})
+	}
+
+	sessions, _ := os.ReadDir(filepath.Join(m.sandboxRoot, "sessions"))
+	for _, entry := range sessions {
+		name := entry.Name()
+		if m.sandboxExists(name) {
+			continue
+		}
+		issues = append(issues, driftIssue{
+			Kind:    driftOrphanSession,
+			Subject: name,
+			Detail:  "session directory has no sandbox metadata",
+			Fix:     "rm -r " + m.sessionDir(name),
+			apply:   func() error { return os.RemoveAll(m.sessionDir(name)) },
+		})
+	}
+	return issues, nil
+}
+
+func (m *manager) staleTmpIssues() []driftIssue {
+	var issues []driftIssue
+	for _, dir := range []string{m.metaDir, m.trashDir()} {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".tmp" {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || time.Since(info.ModTime()) < staleTmpAge {
+				continue
+			}
+			file := filepath.Join(dir, entry.Name())
+			issues = append(issues, driftIssue{
+				Kind:    driftStaleTmp,
+				Subject: file,
+				Detail:  "leftover from an interrupted metadata write",
+				Fix:     "rm " + file,
+				apply:   func() error { return os.Remove(file) },
+			})
+		}
+	}
+	return issues
+}
+
+// recordedBranches lists branches vibe created for sandboxes that may no
+// longer have metadata: branches in the registry, and branches of trashed
+// sandboxes whose destroy meant to delete them. Branches the user kept with
+// --delete-branch=false are left out.
+func (m *manager) recordedBranches() ([]string, error) {
+	seen := map[string]bool{}
+	reg, err := loadRegistry()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range reg.Sandboxes {
+		if e.Repo == m.repoRoot && e.SandboxRoot == m.sandboxRoot && e.Branch != "" {
+			seen[e.Branch] = true
+		}
+	}
+	entries, err := m.listTrash()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.BranchKept && e.Sandbox.Branch != "" {
+			seen[e.Sandbox.Branch] = true
+		}
+	}
+	branches := make([]string, 0, len(seen))
+	for branch := range seen {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+	return branches, nil
+}
+
+// dropOrphanBranch archives and deletes a branch whose sandbox is gone. It
+// never touches sandbox metadata, since a live sandbox may share the name.
+func (m *manager) dropOrphanBranch(branch, base string) error {
+	leftover := &sandboxMeta{Name: strings.ReplaceAll(branch, "/", "-"), Branch: branch, BaseRef: base}
+	if err := m.removeLeftovers(leftover); err != nil {
+		return err
+	}
+	return updateRegistry(func(reg *registry) bool {
+		kept := reg.Sandboxes[:0]
+		for _, e := range reg.Sandboxes {
+			if e.Repo != m.repoRoot || e.Branch != branch || m.sandboxExists(e.Name) {
+				kept = append(kept, e)
+			}
+		}
+		changed := len(kept) != len(reg.Sandboxes)
+		reg.Sandboxes = kept
+		return changed
+	})
+}
+
+// dropDeadSandbox removes a sandbox whose worktree went missing, archiving
+// unsaved work to trash first.
+func (m *manager) dropDeadSandbox(meta *sandboxMeta) error {
+	if err := m.removeLeftovers(meta); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(m.sessionDir(meta.Name)); err != nil {
+		return fmt.Errorf("remove session dir: %w", err)
+	}
+	if err := m.forgetSandbox(meta, true); err != nil {
+		return fmt.Errorf("remove metadata: %w", err)
+	}
+	if err := m.deleteCheckpoints(meta.Name); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	if err := m.unregisterSandbox(meta.Name); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: update sandbox registry: %v\n", err)
+	}
+	forced := true
+	m.recordEvent(sandboxEvent{Type: eventDestroy, Sandbox: meta.Name, Branch: meta.Branch, Force: &forced})
+	return nil
+}
+
+// removeLeftovers archives unsaved work of a worktree or branch to trash and
+// removes both.
+func (m *manager) removeLeftovers(meta *sandboxMeta) error {
+	work, err := m.detectUnsavedWork(meta)
+	if err != nil {
+		return fmt.Errorf("detect unsaved work: %w", err)
+	}
+	if !work.empty() {
+		entry, err := m.archiveSandbox(meta, work)
+		if err != nil {
+			return fmt.Errorf("archive unsaved work: %w", err)
+		}
+		fmt.Printf("archived unsaved work of %s; recover with: vibe trash restore %s\n", meta.Name, entry.ID)
+	}
+	if meta.Worktree != "" {
+		if _, err := os.Stat(meta.Worktree); err == nil {
+			if err := m.gitWorktree(io.Discard, io.Discard, "remove", "--force", meta.Worktree); err != nil {
+				return fmt.Errorf("remove worktree: %w", err)
+			}
+		}
+		if _, err := gitOutputFn(m.repoRoot, "worktree", "prune"); err != nil {
+			return fmt.Errorf("prune worktrees: %w", err)
+		}
+	}
+	if meta.Branch != "" && m.branchExists(meta.Branch) {
+		if _, err := gitOutputFn(m.repoRoot, "branch", "-D", meta.Branch); err != nil {
+			return fmt.Errorf("delete branch: %w", err)
+		}
+	}
+	return nil
+}
+
+func (m *manager) pruneDrift(issues []driftIssue, apply bool) (int, error) {
+	var (
+		fixed    int
+		failures []string
+	)
+	for _, issue := range issues {
+		if issue.apply == nil {
+			fmt.Printf("skip %s %s: %s\n", issue.Kind, issue.Subject, issue.Fix)
+			continue
+		}
+		if !apply {
+			fmt.Printf("would fix %s %s: %s\n", issue.Kind, issue.Subject, issue.Fix)
+			continue
+		}
+		if err := issue.apply(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s %s: %v", issue.Kind, issue.Subject, err))
+			continue
+		}
+		fixed++
+		fmt.Printf("fixed %s %s\n", issue.Kind, issue.Subject)
+	}
+	if len(failures) > 0 {
+		return fixed, fmt.Errorf("failed to fix some issues:\n%s", strings.Join(failures, "\n"))
+	}
+	return fixed, nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiagnoseAndPrune(t *testing.T) {
+	origOutput := commandOutputFn
+	t.Cleanup(func() { commandOutputFn = origOutput })
+	var (
+		m       *manager
+		removed []string
+	)
+	commandOutputFn = func(dir, name string, args ...string) (string, error) {
+		if name != "docker" {
+			return "", errors.New("unexpected command")
+		}
+		switch args[0] {
+		case "ps":
+			out := containerName(m.repoRoot, "live") + "\tlive\trunning\n"
+			if len(removed) == 0 {
+				out += "opencode-sb-ghost-1234\tghost\texited\n"
+			}
+			return out, nil
+		case "rm":
+			removed = append(removed, args[len(args)-1])
+			return "", nil
+		}
+		return "", errors.New("unexpected docker command")
+	}
+
+	m, _ = newGitSandbox(t, "live")
+	dead, err := m.createSandbox("dead", "main", "", sandboxOptions{})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	commitFile(t, dead.Worktree, "dead.txt", "x\n", "dead work")
+	if err := os.RemoveAll(dead.Worktree); err != nil {
+		t.Fatalf("remove worktree dir: %v", err)
+	}
+	stray, err := m.createSandbox("stray", "main", "", sandboxOptions{})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	if _, err := commandOutput(m.repoRoot, "git", "worktree", "remove", stray.Worktree); err != nil {
+		t.Fatalf("remove stray worktree: %v", err)
+	}
+	if err := os.Remove(m.metaPath("stray")); err != nil {
+		t.Fatalf("remove stray metadata: %v", err)
+	}
+	if _, err := commandOutput(m.repoRoot, "git", "branch", "opencode/mine", "main"); err != nil {
+		t.Fatalf("create unrecorded branch: %v", err)
+	}
+	lost := filepath.Join(m.sandboxRoot, "lost")
+	if _, err := commandOutput(m.repoRoot, "git", "worktree", "add", "-q", "-b", "opencode/lost", lost, "main"); err != nil {
+		t.Fatalf("add lost worktree: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(lost, "notes.txt"), []byte("x\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	tmp := filepath.Join(m.metaDir, "live.json.tmp")
+	if err := os.WriteFile(tmp, []byte("{"), 0o644); err != nil {
+		t.Fatalf("write tmp: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(tmp, old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if err := os.MkdirAll(m.sessionDir("gone"), 0o755); err != nil {
+		t.Fatalf("mkdir session: %v", err)
+	}
+
+	issues, err := m.diagnose()
+	if err != nil {
+		t.Fatalf("diagnose: %v", err)
+	}
+	var kinds []string
+	for _, issue := range issues {
+		kinds = append(kinds, issue.Kind+" "+issue.Subject)
+	}
+	sort.Strings(kinds)
+	want := []string{
+		driftMissingWorktree + " dead",
+		driftOrphanBranch + " opencode/stray",
+		driftOrphanContainer + " opencode-sb-ghost-1234",
+		driftOrphanSession + " gone",
+		driftOrphanWorktree + " " + lost,
+		driftStaleTmp + " " + tmp,
+	}
+	if strings.Join(kinds, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("issues =\n%s\nwant\n%s", strings.Join(kinds, "\n"), strings.Join(want, "\n"))
+	}
+
+	if _, err := m.pruneDrift(issues, false); err != nil {
+		t.Fatalf("dry-run prune: %v", err)
+	}
+	if _, err := os.Stat(tmp); err != nil || len(removed) != 0 {
+		t.Fatal("dry run must not change anything")
+	}
+
+	fixed, err := m.pruneDrift(issues, true)
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if fixed != len(want) {
+		t.Fatalf("fixed = %d, want %d", fixed, len(want))
+	}
+	if len(removed) != 1 || removed[0] != "opencode-sb-ghost-1234" {
+		t.Fatalf("removed containers = %v", removed)
+	}
+	if m.branchExists(dead.Branch) || m.branchExists("opencode/stray") {
+		t.Fatal("orphan branches should be deleted")
+	}
+	if !m.branchExists("opencode/mine") {
+		t.Fatal("branches vibe never recorded must be left alone")
+	}
+	reg, err := loadRegistry()
+	if err != nil {
+		t.Fatalf("loadRegistry: %v", err)
+	}
+	for _, e := range reg.Sandboxes {
+		if e.Repo == m.repoRoot && e.Name == "stray" {
+			t.Fatal("registry entry of the orphan branch should be removed")
+		}
+	}
+	entries, err := m.listTrash()
+	if err != nil {
+		t.Fatalf("listTrash: %v", err)
+	}
+	var archived []string
+	for _, entry := range entries {
+		archived = append(archived, entry.Sandbox.Branch)
+	}
+	sort.Strings(archived)
+	if strings.Join(archived, " ") != "opencode/dead opencode/lost" {
+		t.Fatalf("archived = %v, want the dead commits and the lost untracked file", archived)
+	}
+	if _, err := os.Stat(lost); !os.IsNotExist(err) {
+		t.Fatalf("orphan worktree should be removed, stat err = %v", err)
+	}
+
+	issues, err = m.diagnose()
+	if err != nil {
+		t.Fatalf("diagnose after prune: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("issues remain after prune: %+v", issues)
+	}
+}
+
+func TestPruneOrphanBranchKeepsLiveSandbox(t *testing.T) {
+	m := newGitManager(t)
+	old, err := m.createSandbox("foo", "main", "feature", sandboxOptions{})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	commitFile(t, old.Worktree, "old.txt", "x\n", "old work")
+	if err := m.destroySandbox(old, true, true); err != nil {
+		t.Fatalf("destroySandbox: %v", err)
+	}
+	if _, err := commandOutput(m.repoRoot, "git", "branch", "feature/foo", "main"); err != nil {
+		t.Fatalf("recreate branch: %v", err)
+	}
+	live, err := m.createSandbox("foo", "main", "", sandboxOptions{})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	if err := os.MkdirAll(m.sessionDir("foo"), 0o755); err != nil {
+		t.Fatalf("mkdir session: %v", err)
+	}
+	kept, err := m.createSandbox("kept", "main", "", sandboxOptions{})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	commitFile(t, kept.Worktree, "kept.txt", "x\n", "kept work")
+	if err := m.destroySandbox(kept, true, false); err != nil {
+		t.Fatalf("destroySandbox: %v", err)
+	}
+	if _, err := commandOutput(m.repoRoot, "git", "branch", "feature/mine", "main"); err != nil {
+		t.Fatalf("create unrecorded branch: %v", err)
+	}
+
+	issues, err := m.diagnose()
+	if err != nil {
+		t.Fatalf("diagnose: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Kind != driftOrphanBranch || issues[0].Subject != "feature/foo" {
+		t.Fatalf("issues = %+v, want only the orphan feature/foo", issues)
+	}
+	if _, err := m.pruneDrift(issues, true); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if m.branchExists("feature/foo") {
+		t.Fatal("orphan branch should be deleted")
+	}
+	if !m.branchExists("feature/mine") || !m.branchExists(kept.Branch) {
+		t.Fatal("unrecorded and kept branches must survive")
+	}
+	got, err := m.loadSandbox("foo")
+	if err != nil || got.Branch != live.Branch {
+		t.Fatalf("live sandbox metadata lost: %+v, %v", got, err)
+	}
+	if _, err := os.Stat(m.sessionDir("foo")); err != nil {
+		t.Fatalf("live sandbox session dir lost: %v", err)
+	}
+}
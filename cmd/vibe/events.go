@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	eventsFile     = "events.jsonl"
+	eventsLockName = "events"
+
+	eventCreate  = "create"
+	eventRun     = "run"
+	eventExit    = "exit"
+	eventPR      = "pr"
+	eventDestroy = "destroy"
+)
+
+var eventPollInterval = 500 * time.Millisecond
+
+// sandboxEvent is one line of the audit journal. Env holds the names of the
+// variables passed into the container, never their values.
+type sandboxEvent struct {
+	Time     string   `json:"time"`
+	Type     string   `json:"type"`
+	Sandbox  string   `json:"sandbox"`
+	Repo     string   `json:"repo"`
+	User     string   `json:"user"`
+	Host     string   `json:"host,omitempty"`
+	Branch   string   `json:"branch,omitempty"`
+	BaseRef  string   `json:"base_ref,omitempty"`
+	Image    string   `json:"image,omitempty"`
+	Command  string   `json:"command,omitempty"`
+	Mounts   []string `json:"mounts,omitempty"`
+	Env      []string `json:"env,omitempty"`
+	ExitCode *int     `json:"exit_code,omitempty"`
+	PRNumber int      `json:"pr_number,omitempty"`
+	PRURL    string   `json:"pr_url,omitempty"`
+	Force    *bool    `json:"force,omitempty"`
+}
+
+func (m *manager) eventsPath() string {
+	return filepath.Join(m.sandboxRoot, eventsFile)
+}
+
+// recordEvent appends an event to the journal. Journal failures are reported
+// but never fail the operation being recorded.
+func (m *manager) recordEvent(e sandboxEvent) {
+	if err := m.appendEvent(e); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: record %s event: %v\n", e.Type, err)
+	}
+}
+
+func (m *manager) appendEvent(e sandboxEvent) error {
+	e.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	e.Repo = m.repoRoot
+	e.User = currentUser()
+	e.Host, _ = os.Hostname()
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	lock, err := openLock(m.lockPath(eventsLockName), true)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+	f, err := os.OpenFile(m.eventsPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// runAudit extracts the host mounts and the environment variable names from
+// docker run arguments.
+func runAudit(args []string) (mounts, env []string) {
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "-v", "--mount":
+			mounts = append(mounts, args[i+1])
+			i++
+		case "-e":
+			name, _, _ := strings.Cut(args[i+1], "=")
+			env = append(env, name)
+			i++
+		}
+	}
+	sort.Strings(env)
+	return mounts, env
+}
+
+func formatEvent(e sandboxEvent) string {
+	var details []string
+	add := func(key, value string) {
+		if value != "" {
+			details = append(details, key+"="+value)
+		}
+	}
+	add("branch", e.Branch)
+	add("base", e.BaseRef)
+	add("image", e.Image)
+	if len(e.Mounts) > 0 {
+		add("mounts", strings.Join(e.Mounts, ","))
+	}
+	if len(e.Env) > 0 {
+		add("env", strings.Join(e.Env, ","))
+	}
+	if e.ExitCode != nil {
+		add("exit", fmt.Sprint(*e.ExitCode))
+	}
+	add("pr", e.PRURL)
+	if e.Force != nil {
+		add("force", fmt.Sprint(*e.Force))
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s %s %s", e.Time, e.User, e.Type, e.Sandbox, strings.Join(details, " ")))
+}
+
+// tailEvents prints the journal entries matching name. With follow it keeps
+// polling for new entries until stop is closed.
+func tailEvents(w io.Writer, path, name, output string, follow bool, stop <-chan struct{}) error {
+	if output != outputTable && output != outputJSON {
+		return fmt.Errorf("unknown output format %q (want json)", output)
+	}
+	var (
+		f       *os.File
+		reader  *bufio.Reader
+		partial string
+	)
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+	for {
+		if f == nil {
+			var err error
+			f, err = os.Open(path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			if f != nil {
+				reader = bufio.NewReader(f)
+			}
+		}
+		for reader != nil {
+			chunk, err := reader.ReadString('\n')
+			partial += chunk
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			line := strings.TrimSpace(partial)
+			partial = ""
+			if line == "" {
+				continue
+			}
+			var e sandboxEvent
+			if err := json.Unmarshal([]byte(line), &e); err != nil {
+				return fmt.Errorf("decode event: %w", err)
+			}
+			if name != "" && e.Sandbox != name {
+				continue
+			}
+			if output == outputJSON {
+				fmt.Fprintln(w, line)
+			} else {
+				fmt.Fprintln(w, formatEvent(e))
+			}
+		}
+		if !follow {
+			return nil
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(eventPollInterval):
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventJournal(t *testing.T) {
+	origInteractive := interactiveCommandFn
+	t.Cleanup(func() { interactiveCommandFn = origInteractive })
+	interactiveCommandFn = func(name string, args ...string) error { return nil }
+	t.Setenv("GH_TOKEN", "very-secret-token")
+
+	m, meta := newGitSandbox(t, "audited")
+	runtime := &runtimeSpec{Image: "custom:1", Mounts: []string{"type=bind,source=/data,target=/data"}, ContainerEnv: map[string]string{"MODE": "ci"}}
+	if err := m.launchSandbox(meta, runtime, "opencode", 0); err != nil {
+		t.Fatalf("launchSandbox: %v", err)
+	}
+	if _, err := m.createSandbox("other", "main", "", sandboxOptions{}); err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	if err := m.destroySandbox(meta, true, true); err != nil {
+		t.Fatalf("destroySandbox: %v", err)
+	}
+
+	raw, err := os.ReadFile(m.eventsPath())
+	if err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+	if strings.Contains(string(raw), "very-secret-token") {
+		t.Fatalf("journal leaked a credential value:\n%s", raw)
+	}
+
+	var buf bytes.Buffer
+	if err := tailEvents(&buf, m.eventsPath(), "audited", outputTable, false, nil); err != nil {
+		t.Fatalf("tailEvents: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var types []string
+	for _, line := range lines {
+		types = append(types, strings.Fields(line)[2])
+	}
+	if strings.Join(types, " ") != "create run exit destroy" {
+		t.Fatalf("event types = %v\n%s", types, buf.String())
+	}
+	run := lines[1]
+	for _, want := range []string{"image=custom:1", "/data", "GH_TOKEN", "MODE", "audited"} {
+		if !strings.Contains(run, want) {
+			t.Fatalf("run event missing %q: %s", want, run)
+		}
+	}
+	if !strings.Contains(lines[3], "force=true") {
+		t.Fatalf("destroy event should record force: %s", lines[3])
+	}
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestTailEventsFollow(t *testing.T) {
+	origInterval := eventPollInterval
+	t.Cleanup(func() { eventPollInterval = origInterval })
+	eventPollInterval = 10 * time.Millisecond
+
+	m := newTestManager(t)
+	out := &syncBuffer{}
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- tailEvents(out, m.eventsPath(), "", outputJSON, true, stop) }()
+
+	m.recordEvent(sandboxEvent{Type: eventCreate, Sandbox: "late"})
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), `"sandbox":"late"`) {
+		if time.Now().After(deadline) {
+			t.Fatalf("followed output never showed the event: %q", out.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatalf("tailEvents: %v", err)
+	}
+}
+
+func journalTypes(t *testing.T, m *manager, name string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := tailEvents(&buf, m.eventsPath(), name, outputTable, false, nil); err != nil {
+		t.Fatalf("tailEvents: %v", err)
+	}
+	var types []string
+	for _, line := range splitLines(buf.String()) {
+		types = append(types, strings.Fields(line)[2])
+	}
+	return strings.Join(types, " ")
+}
@@ -13,6 +13,7 @@ var (
 	commandOutputFn      = commandOutput
 	commandOutputNoErrFn = commandOutputNoErr
 	gitOutputFn          = gitOutput
+	gitOutputEnvFn       = gitOutputEnv
 	interactiveCommandFn = runInteractiveCommand
 )
 
@@ -50,6 +51,17 @@ func gitOutput(dir string, args ...string) (string, error) {
 	return strings.TrimSpace(out), nil
 }
 
+func gitOutputEnv(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w\n%s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
 func runInteractiveCommand(name string, args ...string) error {
 	cmd := exec.Command(name, args...)
 	cmd.Stdin = os.Stdin
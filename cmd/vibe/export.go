@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	exportFormatPatch  = "patch"
+	exportFormatMbox   = "mbox"
+	exportFormatBundle = "bundle"
+)
+
+func sidecarPath(out string) string {
+	return strings.TrimRight(out, string(filepath.Separator)) + ".json"
+}
+
+func (m *manager) exportSandbox(meta *sandboxMeta, format, out string) (string, error) {
+	if out == "" {
+		return "", errors.New("output path is required")
+	}
+	if meta.BaseRef == "" {
+		return "", fmt.Errorf("sandbox %q has no base ref recorded", meta.Name)
+	}
+	if !filepath.IsAbs(out) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("resolve output path: %w", err)
+		}
+		out = filepath.Join(wd, out)
+	}
+
+	revRange := meta.BaseRef + ".." + meta.Branch
+	count, err := gitOutputFn(m.repoRoot, "rev-list", "--count", revRange)
+	if err != nil {
+		return "", fmt.Errorf("count commits: %w", err)
+	}
+	if count == "0" {
+		return "", fmt.Errorf("sandbox %q has no commits ahead of %s", meta.Name, meta.BaseRef)
+	}
+
+	switch format {
+	case exportFormatPatch:
+		if err := runCommandFn(m.repoRoot, io.Discard, os.Stderr, "git", "format-patch", "-o", out, revRange); err != nil {
+			return "", fmt.Errorf("format patches: %w", err)
+		}
+	case exportFormatMbox:
+		if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+			return "", fmt.Errorf("create output dir: %w", err)
+		}
+		f, err := os.Create(out)
+		if err != nil {
+			return "", fmt.Errorf("create mbox: %w", err)
+		}
+		runErr := runCommandFn(m.repoRoot, f, os.Stderr, "git", "format-patch", "--stdout", revRange)
+		if err := f.Close(); err != nil && runErr == nil {
+			runErr = err
+		}
+		if runErr != nil {
+			_ = os.Remove(out)
+			return "", fmt.Errorf("format mbox: %w", runErr)
+		}
+	case exportFormatBundle:
+		if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+			return "", fmt.Errorf("create output dir: %w", err)
+		}
+		if err := runCommandFn(m.repoRoot, io.Discard, os.Stderr, "git", "bundle", "create", out, revRange); err != nil {
+			return "", fmt.Errorf("create bundle: %w", err)
+		}
+	default:
+		return "", fmt.Errorf("unknown export format %q (want patch, mbox or bundle)", format)
+	}
+
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	sidecar := sidecarPath(out)
+	if err := os.WriteFile(sidecar, b, 0o644); err != nil {
+		return "", fmt.Errorf("write sidecar: %w", err)
+	}
+	return out, nil
+}
+
+func readSidecar(path string) (*sandboxMeta, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read sidecar: %w", err)
+	}
+	var meta sandboxMeta
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return nil, fmt.Errorf("decode sidecar: %w", err)
+	}
+	if meta.Branch == "" {
+		return nil, fmt.Errorf("sidecar %s has no branch", path)
+	}
+	return &meta, nil
+}
+
+func (m *manager) importSandbox(bundle string, src *sandboxMeta, name, branchPrefix string) (*sandboxMeta, error) {
+	if name == "" {
+		name = src.Name
+	}
+	if !validName(name) {
+		return nil, fmt.Errorf("invalid sandbox name %q", name)
+	}
+	unlock, err := m.lockSandbox(name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if branchPrefix == "" {
+		branchPrefix = defaultBranchPrefix
+	}
+	if !filepath.IsAbs(bundle) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("resolve bundle path: %w", err)
+		}
+		bundle = filepath.Join(wd, bundle)
+	}
+
+	if m.sandboxExists(name) {
+		return nil, fmt.Errorf("sandbox %q already exists", name)
+	}
+	worktree := filepath.Join(m.sandboxRoot, name)
+	if _, err := os.Stat(worktree); err == nil {
+		return nil, fmt.Errorf("worktree path already exists: %s", worktree)
+	}
+
+	if err := runCommandFn(m.repoRoot, io.Discard, os.Stderr, "git", "bundle", "verify", "-q", bundle); err != nil {
+		return nil, fmt.Errorf("verify bundle: %w", err)
+	}
+
+	branch := fmt.Sprintf("%s/%s", branchPrefix, name)
+	// The fetch would move an existing branch, and the rollback below would
+	// delete it, so only import into a branch this call creates.
+	if m.branchExists(branch) {
+		return nil, fmt.Errorf("branch %q already exists; pass --name or --branch-prefix to import under another branch", branch)
+	}
+	refspec := fmt.Sprintf("refs/heads/%s:refs/heads/%s", src.Branch, branch)
+	if err := runCommandFn(m.repoRoot, os.Stdout, os.Stderr, "git", "fetch", bundle, refspec); err != nil {
+		return nil, fmt.Errorf("fetch bundle: %w", err)
+	}
+	if err := m.gitWorktree(os.Stdout, os.Stderr, "add", worktree, branch); err != nil {
+		_ = runCommandFn(m.repoRoot, io.Discard, io.Discard, "git", "branch", "-D", branch)
+		return nil, fmt.Errorf("create worktree: %w", err)
+	}
+
+	meta := &sandboxMeta{
+		Name:      name,
+		Branch:    branch,
+		BaseRef:   src.BaseRef,
+		Worktree:  worktree,
+		Container: containerName(m.repoRoot, name),
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	if err := m.saveSandbox(meta); err != nil {
+		m.removeWorktreeAndBranch(worktree, branch)
+		return nil, err
+	}
+	m.recordEvent(sandboxEvent{Type: eventCreate, Sandbox: name, Branch: branch, BaseRef: meta.BaseRef})
+	return meta, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportSandboxFormats(t *testing.T) {
+	m, meta := newGitSandbox(t, "feat-x")
+	commitFile(t, meta.Worktree, "a.txt", "hello\n", "add a")
+	commitFile(t, meta.Worktree, "b.txt", "world\n", "add b")
+	out := t.TempDir()
+
+	patchDir, err := m.exportSandbox(meta, exportFormatPatch, filepath.Join(out, "patches"))
+	if err != nil {
+		t.Fatalf("export patch: %v", err)
+	}
+	entries, err := os.ReadDir(patchDir)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("patch dir entries = %d (err=%v), want 2", len(entries), err)
+	}
+
+	mbox, err := m.exportSandbox(meta, exportFormatMbox, filepath.Join(out, "feat.mbox"))
+	if err != nil {
+		t.Fatalf("export mbox: %v", err)
+	}
+	b, err := os.ReadFile(mbox)
+	if err != nil || strings.Count(string(b), "Subject: [PATCH") != 2 {
+		t.Fatalf("mbox should contain two patches, err=%v", err)
+	}
+
+	got, err := readSidecar(sidecarPath(mbox))
+	if err != nil {
+		t.Fatalf("readSidecar: %v", err)
+	}
+	if got.Name != meta.Name || got.Branch != meta.Branch || got.BaseRef != meta.BaseRef {
+		t.Fatalf("sidecar = %+v, want %+v", got, meta)
+	}
+
+	if _, err := m.exportSandbox(meta, "zip", filepath.Join(out, "x")); err == nil || !strings.Contains(err.Error(), "unknown export format") {
+		t.Fatalf("expected unknown format error, got %v", err)
+	}
+}
+
+func TestExportSandboxRejectsEmptyBranch(t *testing.T) {
+	m, meta := newGitSandbox(t, "empty")
+	_, err := m.exportSandbox(meta, exportFormatBundle, filepath.Join(t.TempDir(), "x.bundle"))
+	if err == nil || !strings.Contains(err.Error(), "no commits ahead") {
+		t.Fatalf("expected no commits error, got %v", err)
+	}
+}
+
+func TestExportImportBundleRoundTrip(t *testing.T) {
+	m, meta := newGitSandbox(t, "feat-y")
+	commitFile(t, meta.Worktree, "y.txt", "y\n", "add y")
+	bundle, err := m.exportSandbox(meta, exportFormatBundle, filepath.Join(t.TempDir(), "feat-y.bundle"))
+	if err != nil {
+		t.Fatalf("export bundle: %v", err)
+	}
+
+	clone := filepath.Join(t.TempDir(), "clone")
+	if _, err := commandOutput("", "git", "clone", "-q", m.repoRoot, clone); err != nil {
+		t.Fatalf("git clone: %v", err)
+	}
+	sandboxRoot := filepath.Join(clone, defaultSandboxDir)
+	other := &manager{repoRoot: clone, sandboxRoot: sandboxRoot, metaDir: filepath.Join(sandboxRoot, "meta")}
+	if err := os.MkdirAll(other.metaDir, 0o755); err != nil {
+		t.Fatalf("mkdir meta: %v", err)
+	}
+
+	src, err := readSidecar(sidecarPath(bundle))
+	if err != nil {
+		t.Fatalf("readSidecar: %v", err)
+	}
+	imported, err := other.importSandbox(bundle, src, "", "")
+	if err != nil {
+		t.Fatalf("importSandbox: %v", err)
+	}
+	if imported.Name != "feat-y" || imported.Branch != defaultBranchPrefix+"/feat-y" || imported.BaseRef != meta.BaseRef {
+		t.Fatalf("imported meta = %+v", imported)
+	}
+	if _, err := os.Stat(filepath.Join(imported.Worktree, "y.txt")); err != nil {
+		t.Fatalf("imported worktree missing committed file: %v", err)
+	}
+	if _, err := other.loadSandbox("feat-y"); err != nil {
+		t.Fatalf("imported metadata missing: %v", err)
+	}
+	if got := journalTypes(t, other, "feat-y"); got != "create" {
+		t.Fatalf("journal after import = %q", got)
+	}
+	if _, err := other.importSandbox(bundle, src, "", ""); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected duplicate import error, got %v", err)
+	}
+
+	mainHead, _ := gitOutput(clone, "rev-parse", "main")
+	if _, err := gitOutput(clone, "branch", defaultBranchPrefix+"/taken", "main"); err != nil {
+		t.Fatalf("create branch: %v", err)
+	}
+	if _, err := other.importSandbox(bundle, src, "taken", ""); err == nil || !strings.Contains(err.Error(), "branch") {
+		t.Fatalf("expected existing branch error, got %v", err)
+	}
+	if head, err := gitOutput(clone, "rev-parse", defaultBranchPrefix+"/taken"); err != nil || head != mainHead {
+		t.Fatalf("existing branch was moved or deleted: %q (err=%v)", head, err)
+	}
+}
+
+func newGitSandbox(t *testing.T, name string) (*manager, *sandboxMeta) {
+	t.Helper()
+	m := newGitManager(t)
+	meta, err := m.createSandbox(name, "main", "", sandboxOptions{})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	return m, meta
+}
+
+func newGitManager(t *testing.T) *manager {
+	t.Helper()
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	repo := t.TempDir()
+	if _, err := commandOutput("", "git", "init", "-q", "-b", "main", repo); err != nil {
+		t.Fatalf("git init: %v", err)
+	}
+	commitFile(t, repo, "README.md", "x\n", "init")
+
+	sandboxRoot := filepath.Join(repo, defaultSandboxDir)
+	m := &manager{repoRoot: repo, sandboxRoot: sandboxRoot, metaDir: filepath.Join(sandboxRoot, "meta")}
+	if err := os.MkdirAll(m.metaDir, 0o755); err != nil {
+		t.Fatalf("mkdir meta: %v", err)
+	}
+	return m
+}
+
+func commitFile(t *testing.T, dir, file, content, msg string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", file, err)
+	}
+	if _, err := commandOutput(dir, "git", "add", file); err != nil {
+		t.Fatalf("git add: %v", err)
+	}
+	if _, err := commandOutput(dir, "git", "commit", "-q", "-m", msg); err != nil {
+		t.Fatalf("git commit: %v", err)
+	}
+}
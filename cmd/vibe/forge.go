@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	forgeGitHub    = "github"
+	forgeGitLab    = "gitlab"
+	forgeGitea     = "gitea"
+	forgeBitbucket = "bitbucket"
+
+	defaultRemote = "origin"
+	forkRemote    = "fork"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+type prRequest struct {
+	Head  string
+	Base  string
+	Title string
+	Body  string
+	Draft bool
+}
+
+type pullRequest struct {
+	Number int
+	URL    string
+}
+
+const (
+	prStateOpen   = "open"
+	prStateMerged = "merged"
+	prStateClosed = "closed"
+)
+
+type prStatus struct {
+	State  string
+	Review string
+	Checks string
+}
+
+type forge interface {
+	createPR(req prRequest) (*pullRequest, error)
+	prStatus(number int) (*prStatus, error)
+}
+
+type forkRepo struct {
+	FullName string
+	CloneURL string
+	SSHURL   string
+}
+
+type forker interface {
+	canPush() (bool, error)
+	fork() (*forkRepo, error)
+	// forkExists reports whether the fork named owner/repo can be fetched yet.
+	forkExists(fullName string) (bool, error)
+}
+
+var (
+	forkPollInterval = 2 * time.Second
+	forkReadyTimeout = 5 * time.Minute
+)
+
+// waitForFork polls until the fork exists. GitHub and Gitea create forks
+// asynchronously, and pushes to a fork that is not ready yet fail.
+func waitForFork(f forker, repo *forkRepo) error {
+	if repo.FullName == "" {
+		return nil
+	}
+	deadline := time.Now().Add(forkReadyTimeout)
+	for {
+		ok, err := f.forkExists(repo.FullName)
+		if err != nil {
+			return fmt.Errorf("check fork %s: %w", repo.FullName, err)
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("fork %s was not ready after %s", repo.FullName, forkReadyTimeout)
+		}
+		time.Sleep(forkPollInterval)
+	}
+}
+
+// repoExists fetches endpoint and reports false when the forge answers 404.
+func repoExists(endpoint string, header http.Header) (bool, error) {
+	err := doJSON(http.MethodGet, endpoint, header, nil, nil)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func escapeFullName(fullName string) string {
+	owner, repo, _ := strings.Cut(fullName, "/")
+	return url.PathEscape(owner) + "/" + url.PathEscape(repo)
+}
+
+type checkCounts struct {
+	passed  int
+	failed  int
+	pending int
+}
+
+func (c checkCounts) String() string {
+	if c.passed+c.failed+c.pending == 0 {
+		return "none"
+	}
+	var parts []string
+	if c.passed > 0 {
+		parts = append(parts, fmt.Sprintf("%d passed", c.passed))
+	}
+	if c.failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", c.failed))
+	}
+	if c.pending > 0 {
+		parts = append(parts, fmt.Sprintf("%d pending", c.pending))
+	}
+	return strings.Join(parts, ", ")
+}
+
+type remoteInfo struct {
+	Scheme string
+	Host   string
+	Path   string
+}
+
+func (r remoteInfo) owner() string {
+	owner, _, _ := cutLast(r.Path, "/")
+	return owner
+}
+
+func (r remoteInfo) repo() string {
+	_, repo, _ := cutLast(r.Path, "/")
+	return repo
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return "", s, false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+func parseRemoteURL(raw string) (remoteInfo, error) {
+	raw = strings.TrimSpace(raw)
+	var info remoteInfo
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return info, fmt.Errorf("parse remote url %q: %w", raw, err)
+		}
+		info.Scheme = u.Scheme
+		info.Host = u.Hostname()
+		info.Path = u.Path
+	} else {
+		hostPart, path, ok := strings.Cut(raw, ":")
+		if !ok {
+			return info, fmt.Errorf("unsupported remote url %q", raw)
+		}
+		if _, host, ok := strings.Cut(hostPart, "@"); ok {
+			hostPart = host
+		}
+		info.Scheme = "ssh"
+		info.Host = hostPart
+		info.Path = path
+	}
+	info.Path = strings.TrimSuffix(strings.Trim(info.Path, "/"), ".git")
+	if info.Host == "" || !strings.Contains(info.Path, "/") {
+		return info, fmt.Errorf("cannot determine repository from remote url %q", raw)
+	}
+	return info, nil
+}
+
+func detectForgeKind(cfg *vibeConfig, host string) (string, error) {
+	if cfg != nil {
+		if cfg.Forge.Type != "" {
+			return cfg.Forge.Type, nil
+		}
+		if kind, ok := cfg.Forge.Hosts[host]; ok {
+			return kind, nil
+		}
+	}
+	switch {
+	case hostIs(host, githubHost):
+		return forgeGitHub, nil
+	case hostIs(host, "gitlab.com"):
+		return forgeGitLab, nil
+	case hostIs(host, "bitbucket.org"):
+		return forgeBitbucket, nil
+	case hostIs(host, "codeberg.org"):
+		return forgeGitea, nil
+	case isGitHubEnterprise(host):
+		return forgeGitHub, nil
+	}
+	return "", fmt.Errorf("cannot detect forge for host %q; set forge.type or forge.hosts in %s/%s", host, configDir, configFile)
+}
+
+// hostIs reports whether host is domain or one of its subdomains.
+func hostIs(host, domain string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// githubMetaURL is the GitHub Enterprise endpoint probed for hosts whose name
+// does not give the forge away.
+var githubMetaURL = "https://%s/api/v3/meta"
+
+// isGitHubEnterprise asks gh whether it knows the host, then probes the
+// GitHub Enterprise meta endpoint.
+func isGitHubEnterprise(host string) bool {
+	if _, err := commandOutputFn("", "gh", "auth", "status", "--hostname", host); err == nil {
+		return true
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(fmt.Sprintf(githubMetaURL, host))
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	var meta map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return false
+	}
+	_, ok := meta["verifiable_password_authentication"]
+	return ok
+}
+
+func newForge(cfg *vibeConfig, remote remoteInfo) (forge, error) {
+	kind, err := detectForgeKind(cfg, remote.Host)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case forgeGitHub:
+		return newGitHubForge(cfg, remote)
+	case forgeGitLab:
+		token, err := forgeToken(cfg, "GITLAB_TOKEN")
+		if err != nil {
+			return nil, err
+		}
+		return &gitlabForge{apiURL: forgeAPIURL(cfg, remote, "/api/v4"), token: token, project: remote.Path}, nil
+	case forgeGitea:
+		token, err := forgeToken(cfg, "GITEA_TOKEN", "FORGEJO_TOKEN")
+		if err != nil {
+			return nil, err
+		}
+		return &giteaForge{apiURL: forgeAPIURL(cfg, remote, "/api/v1"), token: token, owner: remote.owner(), repo: remote.repo()}, nil
+	case forgeBitbucket:
+		token, _ := forgeToken(cfg, "BITBUCKET_TOKEN")
+		f := &bitbucketForge{apiURL: "https://api.bitbucket.org/2.0", token: token, workspace: remote.owner(), repo: remote.repo()}
+		if cfg != nil && cfg.Forge.APIURL != "" {
+			f.apiURL = strings.TrimRight(cfg.Forge.APIURL, "/")
+		}
+		if token == "" {
+			f.username = os.Getenv("BITBUCKET_USERNAME")
+			f.appPassword = os.Getenv("BITBUCKET_APP_PASSWORD")
+			if f.username == "" || f.appPassword == "" {
+				return nil, errors.New("no Bitbucket credentials: set BITBUCKET_TOKEN or BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD")
+			}
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("unsupported forge type %q", kind)
+}
+
+func forgeAPIURL(cfg *vibeConfig, remote remoteInfo, suffix string) string {
+	if cfg != nil && cfg.Forge.APIURL != "" {
+		return strings.TrimRight(cfg.Forge.APIURL, "/")
+	}
+	scheme := "https"
+	if remote.Scheme == "http" {
+		scheme = "http"
+	}
+	return scheme + "://" + remote.Host + suffix
+}
+
+func forgeToken(cfg *vibeConfig, envKeys ...string) (string, error) {
+	if cfg != nil && cfg.Forge.TokenEnv != "" {
+		envKeys = append([]string{cfg.Forge.TokenEnv}, envKeys...)
+	}
+	for _, key := range envKeys {
+		if value := os.Getenv(key); value != "" {
+			return value, nil
+		}
+	}
+	if cfg != nil && cfg.Forge.TokenCommand != "" {
+		out, err := commandOutputFn("", "sh", "-c", cfg.Forge.TokenCommand)
+		if err != nil {
+			return "", fmt.Errorf("run forge.token_command: %w", err)
+		}
+		if token := strings.TrimSpace(out); token != "" {
+			return token, nil
+		}
+	}
+	if len(envKeys) == 0 {
+		return "", errors.New("no forge token found")
+	}
+	return "", fmt.Errorf("no forge token found: set %s or forge.token_command", strings.Join(envKeys, " or "))
+}
+
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("api returned %d: %s", e.Status, e.Message)
+}
+
+func doJSON(method, endpoint string, header http.Header, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, endpoint, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &apiError{Status: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+	}
+	if out == nil || len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type bitbucketForge struct {
+	apiURL      string
+	token       string
+	username    string
+	appPassword string
+	workspace   string
+	repo        string
+}
+
+func (f *bitbucketForge) header() http.Header {
+	if f.token != "" {
+		return http.Header{"Authorization": {"Bearer " + f.token}}
+	}
+	req := &http.Request{Header: http.Header{}}
+	req.SetBasicAuth(f.username, f.appPassword)
+	return req.Header
+}
+
+func (f *bitbucketForge) repoURL() string {
+	return f.apiURL + "/repositories/" + url.PathEscape(f.workspace) + "/" + url.PathEscape(f.repo)
+}
+
+func (f *bitbucketForge) createPR(req prRequest) (*pullRequest, error) {
+	payload := map[string]any{
+		"title":       req.Title,
+		"description": req.Body,
+		"draft":       req.Draft,
+		"source":      map[string]any{"branch": map[string]string{"name": req.Head}},
+		"destination": map[string]any{"branch": map[string]string{"name": req.Base}},
+	}
+	var resp struct {
+		ID    int `json:"id"`
+		Links struct {
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+		} `json:"links"`
+	}
+	if err := doJSON(http.MethodPost, f.repoURL()+"/pullrequests", f.header(), payload, &resp); err != nil {
+		return nil, err
+	}
+	return &pullRequest{Number: resp.ID, URL: resp.Links.HTML.Href}, nil
+}
+
+func (f *bitbucketForge) prStatus(number int) (*prStatus, error) {
+	var pull struct {
+		State        string `json:"state"`
+		Participants []struct {
+			Approved bool   `json:"approved"`
+			State    string `json:"state"`
+		} `json:"participants"`
+	}
+	base := fmt.Sprintf("%s/pullrequests/%d", f.repoURL(), number)
+	if err := doJSON(http.MethodGet, base, f.header(), nil, &pull); err != nil {
+		return nil, err
+	}
+	status := &prStatus{Review: "review required"}
+	switch pull.State {
+	case "OPEN":
+		status.State = prStateOpen
+	case "MERGED":
+		status.State = prStateMerged
+	default:
+		status.State = prStateClosed
+	}
+	for _, p := range pull.Participants {
+		if p.State == "changes_requested" {
+			status.Review = "changes requested"
+			break
+		}
+		if p.Approved {
+			status.Review = "approved"
+		}
+	}
+
+	var statuses struct {
+		Values []struct {
+			State string `json:"state"`
+		} `json:"values"`
+	}
+	if err := doJSON(http.MethodGet, base+"/statuses", f.header(), nil, &statuses); err != nil {
+		return nil, err
+	}
+	var counts checkCounts
+	for _, st := range statuses.Values {
+		switch st.State {
+		case "SUCCESSFUL":
+			counts.passed++
+		case "FAILED", "STOPPED":
+			counts.failed++
+		default:
+			counts.pending++
+		}
+	}
+	status.Checks = counts.String()
+	return status, nil
+}
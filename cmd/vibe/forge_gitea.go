@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type giteaForge struct {
+	apiURL string
+	token  string
+	owner  string
+	repo   string
+}
+
+func (f *giteaForge) header() http.Header {
+	return http.Header{"Authorization": {"token " + f.token}}
+}
+
+func (f *giteaForge) repoURL() string {
+	return f.apiURL + "/repos/" + url.PathEscape(f.owner) + "/" + url.PathEscape(f.repo)
+}
+
+func (f *giteaForge) createPR(req prRequest) (*pullRequest, error) {
+	title := req.Title
+	if req.Draft {
+		title = "WIP: " + title
+	}
+	payload := map[string]any{
+		"head":  req.Head,
+		"base":  req.Base,
+		"title": title,
+		"body":  req.Body,
+	}
+	var resp struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := doJSON(http.MethodPost, f.repoURL()+"/pulls", f.header(), payload, &resp); err != nil {
+		return nil, err
+	}
+	return &pullRequest{Number: resp.Number, URL: resp.HTMLURL}, nil
+}
+
+func (f *giteaForge) prStatus(number int) (*prStatus, error) {
+	var pull struct {
+		State  string `json:"state"`
+		Merged bool   `json:"merged"`
+		Head   struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	}
+	base := fmt.Sprintf("%s/pulls/%d", f.repoURL(), number)
+	if err := doJSON(http.MethodGet, base, f.header(), nil, &pull); err != nil {
+		return nil, err
+	}
+	status := &prStatus{State: pull.State}
+	if pull.Merged {
+		status.State = prStateMerged
+	}
+
+	var reviews []struct {
+		State string `json:"state"`
+		User  struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	}
+	if err := doJSON(http.MethodGet, base+"/reviews", f.header(), nil, &reviews); err != nil {
+		return nil, err
+	}
+	latest := map[string]string{}
+	for _, review := range reviews {
+		if review.State == "APPROVED" || review.State == "REQUEST_CHANGES" {
+			latest[review.User.Login] = review.State
+		}
+	}
+	status.Review = reviewDecision(latest, "APPROVED", "REQUEST_CHANGES")
+
+	var combined struct {
+		Statuses []struct {
+			Status string `json:"status"`
+		} `json:"statuses"`
+	}
+	if err := doJSON(http.MethodGet, f.repoURL()+"/commits/"+pull.Head.SHA+"/status", f.header(), nil, &combined); err != nil {
+		return nil, err
+	}
+	var counts checkCounts
+	for _, st := range combined.Statuses {
+		switch st.Status {
+		case "success":
+			counts.passed++
+		case "failure", "error":
+			counts.failed++
+		default:
+			counts.pending++
+		}
+	}
+	status.Checks = counts.String()
+	return status, nil
+}
+
+func (f *giteaForge) canPush() (bool, error) {
+	var repo struct {
+		Permissions *struct {
+			Push bool `json:"push"`
+		} `json:"permissions"`
+	}
+	if err := doJSON(http.MethodGet, f.repoURL(), f.header(), nil, &repo); err != nil {
+		return false, err
+	}
+	return repo.Permissions == nil || repo.Permissions.Push, nil
+}
+
+func (f *giteaForge) fork() (*forkRepo, error) {
+	var repo struct {
+		FullName string `json:"full_name"`
+		CloneURL string `json:"clone_url"`
+		SSHURL   string `json:"ssh_url"`
+	}
+	if err := doJSON(http.MethodPost, f.repoURL()+"/forks", f.header(), map[string]any{}, &repo); err != nil {
+		return nil, err
+	}
+	return &forkRepo{FullName: repo.FullName, CloneURL: repo.CloneURL, SSHURL: repo.SSHURL}, nil
+}
+
+func (f *giteaForge) forkExists(fullName string) (bool, error) {
+	return repoExists(f.apiURL+"/repos/"+escapeFullName(fullName), f.header())
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const githubHost = "github.com"
+
+type githubForge struct {
+	apiURL string
+	token  string
+	owner  string
+	repo   string
+}
+
+func newGitHubForge(cfg *vibeConfig, remote remoteInfo) (*githubForge, error) {
+	token, err := githubToken(cfg, remote.Host)
+	if err != nil {
+		return nil, err
+	}
+	apiURL := "https://api.github.com"
+	if remote.Host != githubHost {
+		apiURL = forgeAPIURL(nil, remote, "/api/v3")
+	}
+	if cfg != nil && cfg.Forge.APIURL != "" {
+		apiURL = strings.TrimRight(cfg.Forge.APIURL, "/")
+	}
+	return &githubForge{apiURL: apiURL, token: token, owner: remote.owner(), repo: remote.repo()}, nil
+}
+
+func githubToken(cfg *vibeConfig, host string) (string, error) {
+	envKeys := []string{"GH_TOKEN", "GITHUB_TOKEN"}
+	if host != githubHost {
+		// GH_TOKEN and GITHUB_TOKEN hold github.com credentials and must not
+		// be sent to another host.
+		envKeys = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+	}
+	token, err := forgeToken(cfg, envKeys...)
+	if err == nil {
+		return token, nil
+	}
+	if token := ghHostsToken(host); token != "" {
+		return token, nil
+	}
+	// gh keeps tokens in the system keyring by default and leaves hosts.yml
+	// without one, so ask gh itself.
+	if out, ghErr := commandOutputFn("", "gh", "auth", "token", "--hostname", host); ghErr == nil && strings.TrimSpace(out) != "" {
+		return strings.TrimSpace(out), nil
+	}
+	return "", fmt.Errorf("%w; or log in with `gh auth login`", err)
+}
+
+func ghHostsToken(host string) string {
+	dir := os.Getenv("GH_CONFIG_DIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config", "gh")
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "hosts.yml"))
+	if err != nil {
+		return ""
+	}
+	var hosts map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	}
+	if err := yaml.Unmarshal(raw, &hosts); err != nil {
+		return ""
+	}
+	return hosts[host].OAuthToken
+}
+
+func (f *githubForge) header() http.Header {
+	return http.Header{
+		"Authorization":        {"Bearer " + f.token},
+		"Accept":               {"application/vnd.github+json"},
+		"X-Github-Api-Version": {"2022-11-28"},
+	}
+}
+
+func (f *githubForge) repoURL() string {
+	return f.apiURL + "/repos/" + url.PathEscape(f.owner) + "/" + url.PathEscape(f.repo)
+}
+
+type githubPull struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+}
+
+func (f *githubForge) createPR(req prRequest) (*pullRequest, error) {
+	payload := map[string]any{
+		"head":  req.Head,
+		"base":  req.Base,
+		"title": req.Title,
+		"body":  req.Body,
+		"draft": req.Draft,
+	}
+	var created githubPull
+	err := doJSON(http.MethodPost, f.repoURL()+"/pulls", f.header(), payload, &created)
+	if err == nil {
+		return &pullRequest{Number: created.Number, URL: created.HTMLURL}, nil
+	}
+
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnprocessableEntity || !strings.Contains(apiErr.Message, "already exists") {
+		return nil, err
+	}
+	existing, findErr := f.findOpenPR(req.Head)
+	if findErr != nil {
+		return nil, fmt.Errorf("%w (lookup of existing pr failed: %v)", err, findErr)
+	}
+	return existing, nil
+}
+
+func (f *githubForge) findOpenPR(head string) (*pullRequest, error) {
+	if !strings.Contains(head, ":") {
+		head = f.owner + ":" + head
+	}
+	query := url.Values{"head": {head}, "state": {"open"}}
+	var pulls []githubPull
+	if err := doJSON(http.MethodGet, f.repoURL()+"/pulls?"+query.Encode(), f.header(), nil, &pulls); err != nil {
+		return nil, err
+	}
+	if len(pulls) == 0 {
+		return nil, fmt.Errorf("no open pull request found for %s", head)
+	}
+	return &pullRequest{Number: pulls[0].Number, URL: pulls[0].HTMLURL}, nil
+}
+
+func (f *githubForge) prStatus(number int) (*prStatus, error) {
+	var pull struct {
+		State  string `json:"state"`
+		Merged bool   `json:"merged"`
+		Head   struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	}
+	base := fmt.Sprintf("%s/pulls/%d", f.repoURL(), number)
+	if err := doJSON(http.MethodGet, base, f.header(), nil, &pull); err != nil {
+		return nil, err
+	}
+	status := &prStatus{State: pull.State}
+	if pull.Merged {
+		status.State = prStateMerged
+	}
+
+	var reviews []struct {
+		State string `json:"state"`
+		User  struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	}
+	if err := doJSON(http.MethodGet, base+"/reviews?per_page=100", f.header(), nil, &reviews); err != nil {
+		return nil, err
+	}
+	latest := map[string]string{}
+	for _, review := range reviews {
+		if review.State == "APPROVED" || review.State == "CHANGES_REQUESTED" || review.State == "DISMISSED" {
+			latest[review.User.Login] = review.State
+		}
+	}
+	status.Review = reviewDecision(latest, "APPROVED", "CHANGES_REQUESTED")
+
+	var runs struct {
+		CheckRuns []struct {
+			Status     string `json:"status"`
+			Conclusion string `json:"conclusion"`
+		} `json:"check_runs"`
+	}
+	if err := doJSON(http.MethodGet, f.repoURL()+"/commits/"+pull.Head.SHA+"/check-runs?per_page=100", f.header(), nil, &runs); err != nil {
+		return nil, err
+	}
+	var counts checkCounts
+	for _, run := range runs.CheckRuns {
+		switch {
+		case run.Status != "completed":
+			counts.pending++
+		case run.Conclusion == "success" || run.Conclusion == "neutral" || run.Conclusion == "skipped":
+			counts.passed++
+		default:
+			counts.failed++
+		}
+	}
+	status.Checks = counts.String()
+	return status, nil
+}
+
+func reviewDecision(latest map[string]string, approved, changesRequested string) string {
+	decision := "review required"
+	for _, state := range latest {
+		switch state {
+		case changesRequested:
+			return "changes requested"
+		case approved:
+			decision = "approved"
+		}
+	}
+	return decision
+}
+
+func (f *githubForge) canPush() (bool, error) {
+	var repo struct {
+		Permissions *struct {
+			Push bool `json:"push"`
+		} `json:"permissions"`
+	}
+	if err := doJSON(http.MethodGet, f.repoURL(), f.header(), nil, &repo); err != nil {
+		return false, err
+	}
+	return repo.Permissions == nil || repo.Permissions.Push, nil
+}
+
+func (f *githubForge) fork() (*forkRepo, error) {
+	var repo struct {
+		FullName string `json:"full_name"`
+		CloneURL string `json:"clone_url"`
+		SSHURL   string `json:"ssh_url"`
+	}
+	if err := doJSON(http.MethodPost, f.repoURL()+"/forks", f.header(), map[string]any{}, &repo); err != nil {
+		return nil, err
+	}
+	return &forkRepo{FullName: repo.FullName, CloneURL: repo.CloneURL, SSHURL: repo.SSHURL}, nil
+}
+
+func (f *githubForge) forkExists(fullName string) (bool, error) {
+	return repoExists(f.apiURL+"/repos/"+escapeFullName(fullName), f.header())
+}
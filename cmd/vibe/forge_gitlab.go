@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type gitlabForge struct {
+	apiURL  string
+	token   string
+	project string
+}
+
+func (f *gitlabForge) header() http.Header {
+	return http.Header{"Private-Token": {f.token}}
+}
+
+func (f *gitlabForge) createPR(req prRequest) (*pullRequest, error) {
+	title := req.Title
+	if req.Draft {
+		title = "Draft: " + title
+	}
+	payload := map[string]any{
+		"source_branch":        req.Head,
+		"target_branch":        req.Base,
+		"title":                title,
+		"description":          req.Body,
+		"remove_source_branch": false,
+	}
+	var resp struct {
+		IID    int    `json:"iid"`
+		WebURL string `json:"web_url"`
+	}
+	endpoint := f.apiURL + "/projects/" + url.PathEscape(f.project) + "/merge_requests"
+	if err := doJSON(http.MethodPost, endpoint, f.header(), payload, &resp); err != nil {
+		return nil, err
+	}
+	return &pullRequest{Number: resp.IID, URL: resp.WebURL}, nil
+}
+
+func (f *gitlabForge) prStatus(number int) (*prStatus, error) {
+	var mr struct {
+		State        string `json:"state"`
+		HeadPipeline *struct {
+			Status string `json:"status"`
+		} `json:"head_pipeline"`
+	}
+	base := fmt.Sprintf("%s/projects/%s/merge_requests/%d", f.apiURL, url.PathEscape(f.project), number)
+	if err := doJSON(http.MethodGet, base, f.header(), nil, &mr); err != nil {
+		return nil, err
+	}
+	status := &prStatus{State: mr.State}
+	if mr.State == "opened" {
+		status.State = prStateOpen
+	}
+
+	var approvals struct {
+		Approved bool `json:"approved"`
+	}
+	if err := doJSON(http.MethodGet, base+"/approvals", f.header(), nil, &approvals); err != nil {
+		return nil, err
+	}
+	status.Review = "review required"
+	if approvals.Approved {
+		status.Review = "approved"
+	}
+
+	var counts checkCounts
+	if mr.HeadPipeline != nil {
+		switch mr.HeadPipeline.Status {
+		case "success", "skipped":
+			counts.passed++
+		case "failed", "canceled":
+			counts.failed++
+		default:
+			counts.pending++
+		}
+	}
+	status.Checks = counts.String()
+	return status, nil
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRemoteURL(t *testing.T) {
+	cases := []struct {
+		in    string
+		host  string
+		path  string
+		owner string
+		repo  string
+	}{
+		{in: "git@github.com:acme/app.git", host: "github.com", path: "acme/app", owner: "acme", repo: "app"},
+		{in: "https://gitlab.example.com/group/sub/app.git", host: "gitlab.example.com", path: "group/sub/app", owner: "group/sub", repo: "app"},
+		{in: "ssh://git@git.example.com:2222/team/app", host: "git.example.com", path: "team/app", owner: "team", repo: "app"},
+		{in: "http://localhost:3000/team/app/", host: "localhost", path: "team/app", owner: "team", repo: "app"},
+	}
+	for _, tc := range cases {
+		got, err := parseRemoteURL(tc.in)
+		if err != nil {
+			t.Fatalf("parseRemoteURL(%q) returned error: %v", tc.in, err)
+		}
+		if got.Host != tc.host || got.Path != tc.path || got.owner() != tc.owner || got.repo() != tc.repo {
+			t.Fatalf("parseRemoteURL(%q) = %+v (owner=%q repo=%q)", tc.in, got, got.owner(), got.repo())
+		}
+	}
+	if _, err := parseRemoteURL("/local/path"); err == nil {
+		t.Fatal("expected error for local path remote")
+	}
+}
+
+func TestDetectForgeKind(t *testing.T) {
+	origOutput := commandOutputFn
+	origMeta := githubMetaURL
+	t.Cleanup(func() {
+		commandOutputFn = origOutput
+		githubMetaURL = origMeta
+	})
+	commandOutputFn = func(dir, name string, args ...string) (string, error) {
+		if name == "gh" && runtimeHasPair(args, "--hostname", "git.gh-login.example") {
+			return "", nil
+		}
+		return "", errors.New("not logged in")
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/git.ghe.example/api/v3/meta" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"verifiable_password_authentication": true}`))
+	}))
+	defer srv.Close()
+	githubMetaURL = srv.URL + "/%s/api/v3/meta"
+
+	cases := map[string]string{
+		"git.ghe.example":      forgeGitHub,
+		"git.gh-login.example": forgeGitHub,
+		"github.com":           forgeGitHub,
+		"GitHub.com":           forgeGitHub,
+		"gitlab.com":           forgeGitLab,
+		"bitbucket.org":        forgeBitbucket,
+		"codeberg.org":         forgeGitea,
+		"git.mapped.example":   forgeGitea,
+	}
+	cfg := &vibeConfig{Forge: forgeConfig{Hosts: map[string]string{"git.mapped.example": forgeGitea}}}
+	for host, want := range cases {
+		got, err := detectForgeKind(cfg, host)
+		if err != nil || got != want {
+			t.Fatalf("detectForgeKind(%q) = %q, %v; want %q", host, got, err, want)
+		}
+	}
+	for _, host := range []string{"git.unknown.example", "github.com.evil.example", "notgitlab.io", "gitea.example.com"} {
+		if kind, err := detectForgeKind(nil, host); err == nil {
+			t.Fatalf("detectForgeKind(%q) = %q, want error for unknown host", host, kind)
+		}
+	}
+	if got, _ := detectForgeKind(&vibeConfig{Forge: forgeConfig{Type: forgeGitLab}}, "github.com"); got != forgeGitLab {
+		t.Fatalf("forge.type override ignored, got %q", got)
+	}
+}
+
+func TestForgeToken(t *testing.T) {
+	t.Setenv("GITLAB_TOKEN", "")
+	t.Setenv("CUSTOM_TOKEN", "from-env")
+	cfg := &vibeConfig{Forge: forgeConfig{TokenEnv: "CUSTOM_TOKEN"}}
+	if got, err := forgeToken(cfg, "GITLAB_TOKEN"); err != nil || got != "from-env" {
+		t.Fatalf("forgeToken = %q, %v; want from-env", got, err)
+	}
+
+	cfg = &vibeConfig{Forge: forgeConfig{TokenCommand: "echo from-command"}}
+	if got, err := forgeToken(cfg, "GITLAB_TOKEN"); err != nil || got != "from-command" {
+		t.Fatalf("forgeToken = %q, %v; want from-command", got, err)
+	}
+
+	if _, err := forgeToken(nil, "GITLAB_TOKEN"); err == nil || !strings.Contains(err.Error(), "GITLAB_TOKEN") {
+		t.Fatalf("expected missing token error, got %v", err)
+	}
+}
+
+func TestGitLabForgeCreatePR(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.EscapedPath() != "/api/v4/projects/group%2Fapp/merge_requests" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.EscapedPath())
+		}
+		if r.Header.Get("Private-Token") != "tok" {
+			t.Errorf("missing token header: %v", r.Header)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"iid": 7, "web_url": "https://gitlab.test/group/app/-/merge_requests/7"}`))
+	}))
+	defer srv.Close()
+
+	f := &gitlabForge{apiURL: srv.URL + "/api/v4", token: "tok", project: "group/app"}
+	pr, err := f.createPR(prRequest{Head: "opencode/x", Base: "main", Title: "Add x", Body: "body", Draft: true})
+	if err != nil {
+		t.Fatalf("createPR returned error: %v", err)
+	}
+	if pr.Number != 7 || !strings.HasSuffix(pr.URL, "/merge_requests/7") {
+		t.Fatalf("pr = %+v", pr)
+	}
+	if got["source_branch"] != "opencode/x" || got["target_branch"] != "main" || got["title"] != "Draft: Add x" {
+		t.Fatalf("unexpected payload: %+v", got)
+	}
+}
+
+func TestGiteaForgeCreatePR(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/team/app/pulls" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "token tok" {
+			t.Errorf("missing token header: %v", r.Header)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"number": 3, "html_url": "https://gitea.test/team/app/pulls/3"}`))
+	}))
+	defer srv.Close()
+
+	f := &giteaForge{apiURL: srv.URL + "/api/v1", token: "tok", owner: "team", repo: "app"}
+	pr, err := f.createPR(prRequest{Head: "opencode/x", Base: "main", Title: "Add x"})
+	if err != nil {
+		t.Fatalf("createPR returned error: %v", err)
+	}
+	if pr.Number != 3 || pr.URL != "https://gitea.test/team/app/pulls/3" {
+		t.Fatalf("pr = %+v", pr)
+	}
+	if got["head"] != "opencode/x" || got["base"] != "main" || got["title"] != "Add x" {
+		t.Fatalf("unexpected payload: %+v", got)
+	}
+}
+
+func TestBitbucketForgeCreatePR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2.0/repositories/ws/app/pullrequests" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me" || pass != "secret" {
+			t.Errorf("missing basic auth: %v", r.Header)
+		}
+		var got struct {
+			Source struct {
+				Branch struct {
+					Name string `json:"name"`
+				} `json:"branch"`
+			} `json:"source"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		if got.Source.Branch.Name != "opencode/x" {
+			t.Errorf("unexpected source branch %q", got.Source.Branch.Name)
+		}
+		_, _ = w.Write([]byte(`{"id": 9, "links": {"html": {"href": "https://bitbucket.test/ws/app/pull-requests/9"}}}`))
+	}))
+	defer srv.Close()
+
+	f := &bitbucketForge{apiURL: srv.URL + "/2.0", username: "me", appPassword: "secret", workspace: "ws", repo: "app"}
+	pr, err := f.createPR(prRequest{Head: "opencode/x", Base: "main", Title: "Add x"})
+	if err != nil {
+		t.Fatalf("createPR returned error: %v", err)
+	}
+	if pr.Number != 9 || pr.URL != "https://bitbucket.test/ws/app/pull-requests/9" {
+		t.Fatalf("pr = %+v", pr)
+	}
+}
+
+func TestForgeAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	f := &giteaForge{apiURL: srv.URL, token: "tok", owner: "team", repo: "app"}
+	_, err := f.createPR(prRequest{Head: "x", Base: "main", Title: "t"})
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "forbidden") {
+		t.Fatalf("expected api error, got %v", err)
+	}
+}
+
+func TestCreatePRThroughGiteaStub(t *testing.T) {
+	origRun := runCommandFn
+	origGit := gitOutputFn
+	t.Cleanup(func() {
+		runCommandFn = origRun
+		gitOutputFn = origGit
+	})
+
+	var head string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var got map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		head, _ = got["head"].(string)
+		_, _ = w.Write([]byte(`{"number": 1, "html_url": "https://gitea.test/team/app/pulls/1"}`))
+	}))
+	defer srv.Close()
+
+	t.Setenv("GITEA_TOKEN", "tok")
+	m := newTestManager(t)
+	m.config = &vibeConfig{Forge: forgeConfig{APIURL: srv.URL, Remote: "upstream", Hosts: map[string]string{"gitea.example.com": forgeGitea}}}
+	meta := &sandboxMeta{Name: "x", Worktree: "/repo/sb", Branch: "opencode/x", BaseRef: "main"}
+	if err := m.writeMeta(meta); err != nil {
+		t.Fatalf("writeMeta: %v", err)
+	}
+	gitOutputFn = fakeGitRemote("https://gitea.example.com/team/app.git", "")
+	var pushArgs []string
+	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error {
+		pushArgs = args
+		return nil
+	}
+
+	if err := m.createPR(meta, prOptions{title: "Add x"}); err != nil {
+		t.Fatalf("createPR returned error: %v", err)
+	}
+	if !equalStrings(pushArgs, []string{"push", "-u", "upstream", "opencode/x"}) {
+		t.Fatalf("push args = %v", pushArgs)
+	}
+	if head != "opencode/x" {
+		t.Fatalf("head = %q", head)
+	}
+}
+
+func TestGitHubForgeExistingPR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"message":"Validation Failed","errors":[{"message":"A pull request already exists for acme:opencode/x."}]}`))
+		case http.MethodGet:
+			if r.URL.Query().Get("head") != "acme:opencode/x" || r.URL.Query().Get("state") != "open" {
+				t.Errorf("unexpected lookup query %s", r.URL.RawQuery)
+			}
+			_, _ = w.Write([]byte(`[{"number": 5, "html_url": "https://github.com/acme/app/pull/5"}]`))
+		}
+	}))
+	defer srv.Close()
+
+	f := &githubForge{apiURL: srv.URL, token: "tok", owner: "acme", repo: "app"}
+	pr, err := f.createPR(prRequest{Head: "opencode/x", Base: "main", Title: "x"})
+	if err != nil {
+		t.Fatalf("createPR returned error: %v", err)
+	}
+	if pr.Number != 5 || pr.URL != "https://github.com/acme/app/pull/5" {
+		t.Fatalf("pr = %+v, want existing pull request", pr)
+	}
+}
+
+func TestNewGitHubForgeEnterprise(t *testing.T) {
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_ENTERPRISE_TOKEN", "")
+	t.Setenv("GITHUB_ENTERPRISE_TOKEN", "")
+	origOut := commandOutputFn
+	t.Cleanup(func() { commandOutputFn = origOut })
+	keyring := map[string]string{}
+	commandOutputFn = func(dir, name string, args ...string) (string, error) {
+		if name == "gh" && len(args) == 4 && strings.Join(args[:3], " ") == "auth token --hostname" {
+			if token, ok := keyring[args[3]]; ok {
+				return token + "\n", nil
+			}
+			return "", errors.New("no oauth token found for " + args[3])
+		}
+		return origOut(dir, name, args...)
+	}
+	dir := t.TempDir()
+	t.Setenv("GH_CONFIG_DIR", dir)
+	hosts := "github.acme.com:\n    user: me\n    oauth_token: ghe-token\n    git_protocol: https\n"
+	if err := os.WriteFile(filepath.Join(dir, "hosts.yml"), []byte(hosts), 0o600); err != nil {
+		t.Fatalf("write hosts.yml: %v", err)
+	}
+
+	remote, err := parseRemoteURL("git@github.acme.com:team/app.git")
+	if err != nil {
+		t.Fatalf("parseRemoteURL: %v", err)
+	}
+	f, err := newGitHubForge(nil, remote)
+	if err != nil {
+		t.Fatalf("newGitHubForge returned error: %v", err)
+	}
+	if f.apiURL != "https://github.acme.com/api/v3" || f.token != "ghe-token" || f.owner != "team" || f.repo != "app" {
+		t.Fatalf("unexpected forge: %+v", f)
+	}
+	t.Setenv("GH_TOKEN", "github-com-token")
+	if f, err = newGitHubForge(nil, remote); err != nil || f.token != "ghe-token" {
+		t.Fatalf("enterprise host must not get GH_TOKEN: %+v, %v", f, err)
+	}
+	t.Setenv("GH_TOKEN", "")
+
+	remote, _ = parseRemoteURL("git@github.com:acme/app.git")
+	if _, err := newGitHubForge(nil, remote); err == nil || !strings.Contains(err.Error(), "gh auth login") {
+		t.Fatalf("expected missing token error, got %v", err)
+	}
+
+	keyring[githubHost] = "keyring-token"
+	f, err = newGitHubForge(nil, remote)
+	if err != nil {
+		t.Fatalf("newGitHubForge returned error: %v", err)
+	}
+	if f.token != "keyring-token" {
+		t.Fatalf("token = %q, want the one from gh auth token", f.token)
+	}
+}
+
+func TestGitHubForgePRStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/app/pulls/5":
+			_, _ = w.Write([]byte(`{"state": "closed", "merged": true, "head": {"sha": "abc"}}`))
+		case "/repos/acme/app/pulls/5/reviews":
+			_, _ = w.Write([]byte(`[{"state": "CHANGES_REQUESTED", "user": {"login": "bob"}}, {"state": "APPROVED", "user": {"login": "bob"}}, {"state": "COMMENTED", "user": {"login": "eve"}}]`))
+		case "/repos/acme/app/commits/abc/check-runs":
+			_, _ = w.Write([]byte(`{"check_runs": [{"status": "completed", "conclusion": "success"}, {"status": "completed", "conclusion": "failure"}, {"status": "in_progress"}]}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	f := &githubForge{apiURL: srv.URL, token: "tok", owner: "acme", repo: "app"}
+	status, err := f.prStatus(5)
+	if err != nil {
+		t.Fatalf("prStatus returned error: %v", err)
+	}
+	want := prStatus{State: prStateMerged, Review: "approved", Checks: "1 passed, 1 failed, 1 pending"}
+	if *status != want {
+		t.Fatalf("status = %+v, want %+v", *status, want)
+	}
+}
+
+func TestGitLabForgePRStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Fapp/merge_requests/7":
+			_, _ = w.Write([]byte(`{"state": "opened", "head_pipeline": {"status": "running"}}`))
+		case "/api/v4/projects/group%2Fapp/merge_requests/7/approvals":
+			_, _ = w.Write([]byte(`{"approved": false}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	f := &gitlabForge{apiURL: srv.URL + "/api/v4", token: "tok", project: "group/app"}
+	status, err := f.prStatus(7)
+	if err != nil {
+		t.Fatalf("prStatus returned error: %v", err)
+	}
+	want := prStatus{State: prStateOpen, Review: "review required", Checks: "1 pending"}
+	if *status != want {
+		t.Fatalf("status = %+v, want %+v", *status, want)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	labelRepo    = "vibe.repo"
+	labelSandbox = "vibe.sandbox"
+	labelBranch  = "vibe.branch"
+	labelVersion = "vibe.version"
+)
+
+type sandboxContainer struct {
+	Name    string
+	Sandbox string
+	State   string
+}
+
+func (m *manager) sandboxLabels(meta *sandboxMeta) map[string]string {
+	return map[string]string{
+		labelRepo:    m.repoRoot,
+		labelSandbox: meta.Name,
+		labelBranch:  meta.Branch,
+		labelVersion: version,
+	}
+}
+
+func labelArgs(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	args := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		args = append(args, "--label", k+"="+labels[k])
+	}
+	return args
+}
+
+// repoContainers lists the containers labelled with this repository,
+// optionally narrowed to one sandbox. Containers created before labels were
+// added are found by the name recorded in the metadata.
+func (m *manager) repoContainers(sandbox string) []sandboxContainer {
+	args := []string{"ps", "-a", "--filter", "label=" + labelRepo + "=" + m.repoRoot}
+	if sandbox != "" {
+		args = append(args, "--filter", "label="+labelSandbox+"="+sandbox)
+	}
+	args = append(args, "--format", `{{.Names}}\t{{.Label "`+labelSandbox+`"}}\t{{.State}}`)
+	out, err := commandOutputFn("", "docker", args...)
+	if err != nil {
+		return nil
+	}
+	var containers []sandboxContainer
+	found := map[string]bool{}
+	for _, line := range splitLines(out) {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 || fields[0] == "" {
+			continue
+		}
+		found[fields[0]] = true
+		containers = append(containers, sandboxContainer{Name: fields[0], Sandbox: fields[1], State: fields[2]})
+	}
+	return append(containers, m.unlabelledContainers(sandbox, found)...)
+}
+
+func (m *manager) unlabelledContainers(sandbox string, found map[string]bool) []sandboxContainer {
+	metas, err := m.listSandboxes()
+	if err != nil {
+		return nil
+	}
+	owners := map[string]string{}
+	args := []string{"ps", "-a"}
+	for _, meta := range metas {
+		if meta.Container == "" || found[meta.Container] || (sandbox != "" && meta.Name != sandbox) {
+			continue
+		}
+		owners[meta.Container] = meta.Name
+		args = append(args, "--filter", "name=^"+meta.Container+"$")
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+	out, err := commandOutputFn("", "docker", append(args, "--format", "{{.Names}}\t{{.State}}")...)
+	if err != nil {
+		return nil
+	}
+	var containers []sandboxContainer
+	for _, line := range splitLines(out) {
+		name, state, ok := strings.Cut(line, "\t")
+		if owner, known := owners[name]; ok && known {
+			containers = append(containers, sandboxContainer{Name: name, Sandbox: owner, State: state})
+		}
+	}
+	return containers
+}
+
+func (m *manager) containerStates() map[string]string {
+	result := map[string]string{}
+	for _, c := range m.repoContainers("") {
+		result[c.Name] = c.State
+	}
+	return result
+}
+
+func (m *manager) removeSandboxContainers(meta *sandboxMeta) {
+	names := []string{meta.Container}
+	for _, c := range m.repoContainers(meta.Name) {
+		if c.Name != meta.Container {
+			names = append(names, c.Name)
+		}
+	}
+	_ = commandOutputNoErrFn("", "docker", append([]string{"rm", "-f"}, names...)...)
+}
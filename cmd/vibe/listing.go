@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputTable    = ""
+	outputWide     = "wide"
+	outputJSON     = "json"
+	outputYAML     = "yaml"
+	outputTemplate = "go-template="
+
+	containerRunning = "running"
+	containerNone    = "none"
+)
+
+type sandboxStatus struct {
+	sandboxMeta
+	Repo           string `json:"repo,omitempty"`
+	ContainerState string `json:"container_state"`
+	WorktreeExists bool   `json:"worktree_exists"`
+	Dirty          bool   `json:"dirty"`
+	Ahead          int    `json:"ahead"`
+	Behind         int    `json:"behind"`
+	DiskUsage      int64  `json:"disk_usage"`
+}
+
+type listFilter struct {
+	running   bool
+	dirty     bool
+	olderThan string
+	base      string
+}
+
+func (f listFilter) active() bool {
+	return f != listFilter{}
+}
+
+func (f listFilter) matcher() (func(*sandboxStatus) bool, error) {
+	var cutoff time.Time
+	if f.olderThan != "" {
+		age, err := parseDuration(f.olderThan)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --older-than: %w", err)
+		}
+		cutoff = time.Now().Add(-age)
+	}
+	return func(s *sandboxStatus) bool {
+		if f.running && s.ContainerState != containerRunning {
+			return false
+		}
+		if f.dirty && !s.Dirty {
+			return false
+		}
+		if f.base != "" && s.BaseRef != f.base {
+			return false
+		}
+		if !cutoff.IsZero() {
+			created, err := time.Parse(time.RFC3339, s.CreatedAt)
+			if err != nil || !created.Before(cutoff) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func diskUsage(dir string) int64 {
+	var total int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+func (m *manager) sandboxStatus(meta sandboxMeta, states map[string]string, withDisk bool) sandboxStatus {
+	s := sandboxStatus{sandboxMeta: meta, ContainerState: containerNone}
+	if state, ok := states[meta.Container]; ok {
+		s.ContainerState = state
+	}
+	if expires, ok := m.expiry(&meta); ok {
+		s.ExpiresAt = expires.Format(time.RFC3339)
+	}
+	if _, err := os.Stat(meta.Worktree); err != nil {
+		return s
+	}
+	s.WorktreeExists = true
+	if out, err := gitOutputFn(meta.Worktree, "status", "--porcelain"); err == nil {
+		s.Dirty = out != ""
+	}
+	if meta.BaseRef != "" {
+		if out, err := gitOutputFn(meta.Worktree, "rev-list", "--left-right", "--count", meta.BaseRef+"..."+meta.Branch); err == nil {
+			if behind, ahead, ok := strings.Cut(out, "\t"); ok {
+				s.Behind, _ = strconv.Atoi(strings.TrimSpace(behind))
+				s.Ahead, _ = strconv.Atoi(strings.TrimSpace(ahead))
+			}
+		}
+	}
+	if withDisk {
+		s.DiskUsage = diskUsage(meta.Worktree)
+	}
+	return s
+}
+
+func (m *manager) listStatuses(filter listFilter, withDisk bool) ([]sandboxStatus, error) {
+	match, err := filter.matcher()
+	if err != nil {
+		return nil, err
+	}
+	metas, err := m.listSandboxes()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(metas, func(i, j int) bool { return metas[i].Name < metas[j].Name })
+	states := m.containerStates()
+	var statuses []sandboxStatus
+	for _, meta := range metas {
+		s := m.sandboxStatus(meta, states, withDisk)
+		if match(&s) {
+			statuses = append(statuses, s)
+		}
+	}
+	return statuses, nil
+}
+
+func globalStatuses(filter listFilter, withDisk bool) ([]sandboxStatus, error) {
+	managers, err := globalManagers()
+	if err != nil {
+		return nil, err
+	}
+	var all []sandboxStatus
+	for _, m := range managers {
+		statuses, err := m.listStatuses(filter, withDisk)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.repoRoot, err)
+		}
+		for _, s := range statuses {
+			s.Repo = m.repoRoot
+			all = append(all, s)
+		}
+	}
+	return all, nil
+}
+
+// displayName is the name shown in tables: the bare name for the current
+// repository, or a repo:name address in global listings.
+func (s sandboxStatus) displayName() string {
+	if s.Repo == "" {
+		return s.Name
+	}
+	return sandboxAddress(s.Repo, s.Name)
+}
+
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func writeStatuses(w io.Writer, statuses []sandboxStatus, output string) error {
+	switch {
+	case output == outputTable:
+		tw := tabwriter.NewWriter(w, 4, 2, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tBRANCH\tBASE\tSPARSE\tWORKTREE\tRUNNING")
+		for _, s := range statuses {
+			status := "no"
+			if s.ContainerState == containerRunning {
+				status = "yes"
+			}
+			if !s.WorktreeExists {
+				status = "missing-worktree"
+			}
+			sparse := "-"
+			if len(s.Sparse) > 0 {
+				sparse = strings.Join(s.Sparse, ",")
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", s.displayName(), s.Branch, s.BaseRef, sparse, s.Worktree, status)
+		}
+		return tw.Flush()
+	case output == outputWide:
+		tw := tabwriter.NewWriter(w, 4, 2, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tBRANCH\tBASE\tCONTAINER\tWORKTREE\tDIRTY\tAHEAD\tBEHIND\tDISK\tPR\tCREATED\tEXPIRES")
+		for _, s := range statuses {
+			worktree := "ok"
+			if !s.WorktreeExists {
+				worktree = "missing"
+			}
+			pr := "-"
+			if s.PRURL != "" {
+				pr = s.PRURL
+			}
+			expires := "-"
+			if s.ExpiresAt != "" {
+				expires = s.ExpiresAt
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%t\t%d\t%d\t%s\t%s\t%s\t%s\n",
+				s.displayName(), s.Branch, s.BaseRef, s.ContainerState, worktree, s.Dirty, s.Ahead, s.Behind, humanBytes(s.DiskUsage), pr, s.CreatedAt, expires)
+		}
+		return tw.Flush()
+	case output == outputJSON:
+		if statuses == nil {
+			statuses = []sandboxStatus{}
+		}
+		b, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case output == outputYAML:
+		// Round-trip through JSON so YAML keys match the JSON field names.
+		b, err := json.Marshal(statuses)
+		if err != nil {
+			return err
+		}
+		var generic []map[string]any
+		if err := json.Unmarshal(b, &generic); err != nil {
+			return err
+		}
+		if generic == nil {
+			generic = []map[string]any{}
+		}
+		out, err := yaml.Marshal(generic)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	case strings.HasPrefix(output, outputTemplate):
+		tmpl, err := template.New("output").Parse(strings.TrimPrefix(output, outputTemplate))
+		if err != nil {
+			return fmt.Errorf("parse output template: %w", err)
+		}
+		for _, s := range statuses {
+			var buf strings.Builder
+			if err := tmpl.Execute(&buf, s); err != nil {
+				return fmt.Errorf("render output template: %w", err)
+			}
+			line := buf.String()
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+			if _, err := io.WriteString(w, line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (want json, yaml, wide or go-template=...)", output)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListStatusesAndFilters(t *testing.T) {
+	origOutput := commandOutputFn
+	t.Cleanup(func() { commandOutputFn = origOutput })
+	var m *manager
+	commandOutputFn = func(dir, name string, args ...string) (string, error) {
+		if name == "docker" && len(args) > 0 && args[0] == "ps" {
+			if !runtimeHasPair(args, "--filter", "label=vibe.repo="+m.repoRoot) {
+				return "", errors.New("containers must be queried by repo label")
+			}
+			return containerName(m.repoRoot, "busy") + "\tbusy\trunning\n" + containerName(m.repoRoot, "old") + "\told\texited\n", nil
+		}
+		return "", errors.New("unexpected command")
+	}
+
+	m, busy := newGitSandbox(t, "busy")
+	commitFile(t, busy.Worktree, "a.txt", "a\n", "work")
+	if err := os.WriteFile(filepath.Join(busy.Worktree, "scratch.txt"), []byte("x\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	old, err := m.createSandbox("old", "main", "", sandboxOptions{})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	old.CreatedAt = time.Now().Add(-10 * 24 * time.Hour).Format(time.RFC3339)
+	if err := m.saveSandbox(old); err != nil {
+		t.Fatalf("saveSandbox: %v", err)
+	}
+
+	statuses, err := m.listStatuses(listFilter{}, true)
+	if err != nil {
+		t.Fatalf("listStatuses: %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+	b := statuses[0]
+	if b.Name != "busy" || b.ContainerState != containerRunning || !b.Dirty || b.Ahead != 1 || b.Behind != 0 || b.DiskUsage == 0 || !b.WorktreeExists {
+		t.Fatalf("busy status = %+v", b)
+	}
+	if statuses[1].ContainerState != "exited" || statuses[1].Dirty {
+		t.Fatalf("old status = %+v", statuses[1])
+	}
+
+	cases := []struct {
+		filter listFilter
+		want   string
+	}{
+		{listFilter{running: true}, "busy"},
+		{listFilter{dirty: true}, "busy"},
+		{listFilter{olderThan: "7d"}, "old"},
+		{listFilter{base: "main"}, "busy,old"},
+		{listFilter{base: "develop"}, ""},
+	}
+	for _, tc := range cases {
+		got, err := m.listStatuses(tc.filter, false)
+		if err != nil {
+			t.Fatalf("listStatuses(%+v): %v", tc.filter, err)
+		}
+		var names []string
+		for _, s := range got {
+			names = append(names, s.Name)
+		}
+		if strings.Join(names, ",") != tc.want {
+			t.Fatalf("filter %+v = %v, want %s", tc.filter, names, tc.want)
+		}
+	}
+	if _, err := m.listStatuses(listFilter{olderThan: "soon"}, false); err == nil {
+		t.Fatal("expected invalid --older-than error")
+	}
+}
+
+func TestWriteStatuses(t *testing.T) {
+	statuses := []sandboxStatus{{
+		sandboxMeta:    sandboxMeta{Name: "feat", Branch: "opencode/feat", BaseRef: "main", PRURL: "https://example.com/pr/1"},
+		ContainerState: containerRunning,
+		WorktreeExists: true,
+		Ahead:          2,
+		DiskUsage:      2048,
+	}}
+
+	var buf bytes.Buffer
+	if err := writeStatuses(&buf, statuses, outputJSON); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode json: %v", err)
+	}
+	if decoded[0]["name"] != "feat" || decoded[0]["container_state"] != "running" || decoded[0]["ahead"] != float64(2) || decoded[0]["pr_url"] == nil {
+		t.Fatalf("json = %v", decoded)
+	}
+
+	buf.Reset()
+	if err := writeStatuses(&buf, statuses, outputYAML); err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	if !strings.Contains(buf.String(), "base_ref: main") || !strings.Contains(buf.String(), "disk_usage: 2048") {
+		t.Fatalf("yaml =\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeStatuses(&buf, statuses, "go-template={{.Name}} {{.Ahead}}"); err != nil {
+		t.Fatalf("template: %v", err)
+	}
+	if buf.String() != "feat 2\n" {
+		t.Fatalf("template = %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeStatuses(&buf, statuses, outputWide); err != nil {
+		t.Fatalf("wide: %v", err)
+	}
+	if !strings.Contains(buf.String(), "2.0KiB") || !strings.Contains(buf.String(), "https://example.com/pr/1") {
+		t.Fatalf("wide =\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeStatuses(&buf, nil, outputJSON); err != nil || strings.TrimSpace(buf.String()) != "[]" {
+		t.Fatalf("empty json = %q, %v", buf.String(), err)
+	}
+	if err := writeStatuses(&buf, statuses, "xml"); err == nil {
+		t.Fatal("expected unknown format error")
+	}
+}
+
+func TestContainerStatesFindsUnlabelledContainers(t *testing.T) {
+	origOutput := commandOutputFn
+	t.Cleanup(func() { commandOutputFn = origOutput })
+	m := newTestManager(t)
+	for _, meta := range []*sandboxMeta{
+		{Name: "labelled", Container: containerName(m.repoRoot, "labelled")},
+		{Name: "legacy", Container: containerName(m.repoRoot, "legacy")},
+		{Name: "gone", Container: containerName(m.repoRoot, "gone")},
+	} {
+		if err := m.writeMeta(meta); err != nil {
+			t.Fatalf("writeMeta: %v", err)
+		}
+	}
+	commandOutputFn = func(dir, name string, args ...string) (string, error) {
+		if runtimeHasPair(args, "--filter", "label=vibe.repo="+m.repoRoot) {
+			if runtimeHasPair(args, "--filter", "label=vibe.sandbox=legacy") {
+				return "", nil
+			}
+			return containerName(m.repoRoot, "labelled") + "\tlabelled\trunning\n", nil
+		}
+		if runtimeHasPair(args, "--filter", "name=^"+containerName(m.repoRoot, "labelled")+"$") {
+			return "", errors.New("labelled containers must not be looked up by name")
+		}
+		if !runtimeHasPair(args, "--filter", "name=^"+containerName(m.repoRoot, "legacy")+"$") {
+			return "", errors.New("legacy container not looked up by name")
+		}
+		return containerName(m.repoRoot, "legacy") + "\texited\n", nil
+	}
+
+	states := m.containerStates()
+	if len(states) != 2 || states[containerName(m.repoRoot, "labelled")] != "running" || states[containerName(m.repoRoot, "legacy")] != "exited" {
+		t.Fatalf("states = %v", states)
+	}
+	if got := m.repoContainers("legacy"); len(got) != 1 || got[0].Sandbox != "legacy" {
+		t.Fatalf("repoContainers(legacy) = %+v", got)
+	}
+}
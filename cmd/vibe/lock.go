@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	storeLockName    = "store"
+	worktreeLockName = "worktrees"
+)
+
+type fileLock struct {
+	f *os.File
+}
+
+func openLock(path string, exclusive bool) (*fileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create lock dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock: %w", err)
+	}
+	if err := flock(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	return &fileLock{f: f}, nil
+}
+
+func (l *fileLock) unlock() {
+	_ = funlock(l.f)
+	_ = l.f.Close()
+}
+
+func (m *manager) lockPath(name string) string {
+	return filepath.Join(m.sandboxRoot, "locks", name+".lock")
+}
+
+// lockStore takes the store-wide lock. Operations on single sandboxes hold it
+// shared; operations that reconcile the whole store hold it exclusively.
+func (m *manager) lockStore(exclusive bool) (*fileLock, error) {
+	return openLock(m.lockPath(storeLockName), exclusive)
+}
+
+// lockSandbox takes the shared store lock and the exclusive lock of one
+// sandbox; the returned func releases both.
+func (m *manager) lockSandbox(name string) (func(), error) {
+	store, err := m.lockStore(false)
+	if err != nil {
+		return nil, err
+	}
+	sandbox, err := openLock(m.lockPath("sandbox-"+name), true)
+	if err != nil {
+		store.unlock()
+		return nil, err
+	}
+	return func() {
+		sandbox.unlock()
+		store.unlock()
+	}, nil
+}
+
+// gitWorktree runs a `git worktree` subcommand. Git does not guard
+// .git/worktrees against concurrent adds and prunes, so these are serialised
+// across processes.
+func (m *manager) gitWorktree(stdout, stderr io.Writer, args ...string) error {
+	lock, err := openLock(m.lockPath(worktreeLockName), true)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+	return runCommandFn(m.repoRoot, stdout, stderr, "git", append([]string{"worktree"}, args...)...)
+}
+
+// writeFileAtomic writes through a uniquely named temp file in the same
+// directory, so concurrent writers never clobber each other's partial data.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// Advisory locks are only implemented on unix; elsewhere concurrent vibe
+// runs rely on the atomic metadata writes alone.
+func flock(*os.File, bool) error { return nil }
+
+func funlock(*os.File) error { return nil }
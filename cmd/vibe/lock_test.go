@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentCreateSameName(t *testing.T) {
+	m := newGitManager(t)
+	const workers = 8
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.createSandbox("same", "main", "", sandboxOptions{}); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded != 1 {
+		t.Fatalf("%d creates succeeded, want exactly 1", succeeded)
+	}
+	if _, err := m.loadSandbox("same"); err != nil {
+		t.Fatalf("loadSandbox: %v", err)
+	}
+}
+
+func TestConcurrentCreateDistinctNames(t *testing.T) {
+	m := newGitManager(t)
+	const workers = 6
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = m.createSandbox(fmt.Sprintf("sb-%d", i), "main", "", sandboxOptions{})
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("create sb-%d: %v", i, err)
+		}
+	}
+	metas, err := m.listSandboxes()
+	if err != nil || len(metas) != workers {
+		t.Fatalf("listSandboxes = %d, %v; want %d", len(metas), err, workers)
+	}
+}
+
+func TestConcurrentSaveSandbox(t *testing.T) {
+	m := newTestManager(t)
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			meta := &sandboxMeta{Name: "x", Branch: "opencode/x", Prompt: strings.Repeat("p", i*100)}
+			if err := m.saveSandbox(meta); err != nil {
+				t.Errorf("saveSandbox: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	b, err := os.ReadFile(m.metaPath("x"))
+	if err != nil {
+		t.Fatalf("read metadata: %v", err)
+	}
+	var meta sandboxMeta
+	if err := json.Unmarshal(b, &meta); err != nil {
+		t.Fatalf("metadata is corrupt: %v\n%s", err, b)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(m.metaDir, "*.tmp"))
+	if len(leftovers) != 0 {
+		t.Fatalf("temp files left behind: %v", leftovers)
+	}
+}
+
+func TestConcurrentDestroyIsIdempotent(t *testing.T) {
+	m, meta := newGitSandbox(t, "twice")
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			copied := *meta
+			errs[i] = m.destroySandbox(&copied, true, true)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("destroySandbox: %v", err)
+		}
+	}
+	if m.refExists("refs/heads/" + meta.Branch) {
+		t.Fatal("branch should be deleted")
+	}
+	if _, err := os.Stat(m.metaPath("twice")); !os.IsNotExist(err) {
+		t.Fatalf("metadata should be removed, stat err=%v", err)
+	}
+}
+
+func TestDestroySandboxResumesAfterInterruption(t *testing.T) {
+	m, meta := newGitSandbox(t, "half")
+	if err := os.RemoveAll(meta.Worktree); err != nil {
+		t.Fatalf("remove worktree: %v", err)
+	}
+	if err := m.destroySandbox(meta, true, true); err != nil {
+		t.Fatalf("destroySandbox: %v", err)
+	}
+	worktrees, err := listWorktrees(m.repoRoot)
+	if err != nil {
+		t.Fatalf("listWorktrees: %v", err)
+	}
+	for _, wt := range worktrees {
+		if wt.Path == meta.Worktree {
+			t.Fatalf("stale worktree record left behind: %+v", wt)
+		}
+	}
+	if m.refExists("refs/heads/" + meta.Branch) {
+		t.Fatal("branch should be deleted")
+	}
+}
+
+func TestLaunchSandboxDoesNotResurrectOrClobberMetadata(t *testing.T) {
+	origInteractive := interactiveCommandFn
+	t.Cleanup(func() { interactiveCommandFn = origInteractive })
+	m, meta := newGitSandbox(t, "racy")
+
+	interactiveCommandFn = func(name string, args ...string) error {
+		if _, err := m.updateSandbox("racy", func(meta *sandboxMeta) { meta.PRURL = "https://example.com/pr/1" }); err != nil {
+			t.Errorf("updateSandbox: %v", err)
+		}
+		return nil
+	}
+	if err := m.launchSandbox(meta, &runtimeSpec{Image: "custom:1"}, "opencode", 0); err != nil {
+		t.Fatalf("launchSandbox: %v", err)
+	}
+	saved, err := m.loadSandbox("racy")
+	if err != nil {
+		t.Fatalf("loadSandbox: %v", err)
+	}
+	if saved.PRURL == "" || saved.ExitCode == nil {
+		t.Fatalf("concurrent update lost: %+v", saved)
+	}
+
+	interactiveCommandFn = func(name string, args ...string) error {
+		if err := m.destroySandbox(meta, true, true); err != nil {
+			t.Errorf("destroySandbox: %v", err)
+		}
+		return nil
+	}
+	if err := m.launchSandbox(meta, &runtimeSpec{Image: "custom:1"}, "opencode", 0); err != nil {
+		t.Fatalf("launchSandbox: %v", err)
+	}
+	if m.sandboxExists("racy") {
+		t.Fatal("exit bookkeeping recreated the metadata of a destroyed sandbox")
+	}
+}
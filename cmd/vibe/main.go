@@ -1,5 +1,7 @@
 package main
 
+var version = "dev"
+
 func main() {
 	if err := newRootCmd().Execute(); err != nil {
 		exitf("%v", err)
@@ -6,6 +6,19 @@ import (
 	"testing"
 )
 
+func TestMain(m *testing.M) {
+	// Keep the sandbox registry of the user running the tests untouched.
+	state, err := os.MkdirTemp("", "vibe-state-")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_STATE_HOME", state)
+	os.Setenv("XDG_CONFIG_HOME", state)
+	code := m.Run()
+	os.RemoveAll(state)
+	os.Exit(code)
+}
+
 func TestNormalizeName(t *testing.T) {
 	cases := []struct {
 		in   string
@@ -17,7 +17,10 @@ func newManager(root string) (*manager, error) {
 	if err != nil {
 		return nil, err
 	}
+	return openManager(repoRoot, root)
+}
 
+func openManager(repoRoot, root string) (*manager, error) {
 	sandboxRoot := resolveSandboxRoot(repoRoot, root)
 	if !filepath.IsAbs(sandboxRoot) {
 		sandboxRoot = filepath.Join(repoRoot, sandboxRoot)
@@ -26,8 +29,16 @@ func newManager(root string) (*manager, error) {
 	if err := os.MkdirAll(metaDir, 0o755); err != nil {
 		return nil, fmt.Errorf("create metadata dir: %w", err)
 	}
+	cfg, err := loadConfig(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	store, err := openStore(sandboxRoot, metaDir, cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	return &manager{repoRoot: repoRoot, sandboxRoot: sandboxRoot, metaDir: metaDir}, nil
+	return &manager{repoRoot: repoRoot, sandboxRoot: sandboxRoot, metaDir: metaDir, config: cfg, store: store}, nil
 }
 
 func resolveSandboxRoot(repoRoot, root string) string {
@@ -65,16 +76,20 @@ func detectRepoRoot() (string, error) {
 	return filepath.Clean(topLevel), nil
 }
 
-func (m *manager) createSandbox(name, baseRef, branchPrefix string) (*sandboxMeta, error) {
+func (m *manager) createSandbox(name, baseRef, branchPrefix string, opts sandboxOptions) (*sandboxMeta, error) {
 	if !validName(name) {
 		return nil, fmt.Errorf("invalid sandbox name %q", name)
 	}
+	unlock, err := m.lockSandbox(name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 	if branchPrefix == "" {
 		branchPrefix = defaultBranchPrefix
 	}
 
-	metaPath := m.metaPath(name)
-	if _, err := os.Stat(metaPath); err == nil {
+	if m.sandboxExists(name) {
 		return nil, fmt.Errorf("sandbox %q already exists", name)
 	}
 
@@ -83,35 +98,128 @@ func (m *manager) createSandbox(name, baseRef, branchPrefix string) (*sandboxMet
 		return nil, fmt.Errorf("worktree path already exists: %s", worktree)
 	}
 
+	ttl := opts.TTL
+	if ttl == "" {
+		ttl = m.config.sandboxTTL()
+	}
+	var expiresAt string
+	if ttl != "" {
+		d, err := parseDuration(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ttl: %w", err)
+		}
+		expiresAt = time.Now().Add(d).Format(time.RFC3339)
+	}
+
+	var parentHead string
+	if opts.Parent != "" {
+		head, err := gitOutputFn(m.repoRoot, "rev-parse", baseRef)
+		if err != nil {
+			return nil, fmt.Errorf("resolve parent branch: %w", err)
+		}
+		parentHead = head
+	}
+
 	branch := fmt.Sprintf("%s/%s", branchPrefix, name)
-	if err := runCommandFn(m.repoRoot, os.Stdout, os.Stderr, "git", "worktree", "add", "-b", branch, worktree, baseRef); err != nil {
+	addArgs := []string{"add"}
+	if len(opts.Sparse) > 0 {
+		addArgs = append(addArgs, "--no-checkout")
+	}
+	addArgs = append(addArgs, "-b", branch, worktree, baseRef)
+	if err := m.gitWorktree(os.Stdout, os.Stderr, addArgs...); err != nil {
 		return nil, fmt.Errorf("create worktree: %w", err)
 	}
+	created := false
+	defer func() {
+		if !created {
+			m.removeWorktreeAndBranch(worktree, branch)
+		}
+	}()
+	if len(opts.Sparse) > 0 {
+		if err := setupSparseCheckout(worktree, opts.Sparse); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.initSubmodules(worktree); err != nil {
+		return nil, err
+	}
+	if err := checkoutLFS(worktree); err != nil {
+		return nil, err
+	}
 
 	meta := &sandboxMeta{
-		Name:      name,
-		Branch:    branch,
-		BaseRef:   baseRef,
-		Worktree:  worktree,
-		Container: containerName(name),
-		CreatedAt: time.Now().Format(time.RFC3339),
+		Name:       name,
+		Branch:     branch,
+		BaseRef:    baseRef,
+		Worktree:   worktree,
+		Container:  containerName(m.repoRoot, name),
+		CreatedAt:  time.Now().Format(time.RFC3339),
+		Sparse:     opts.Sparse,
+		Prompt:     opts.Prompt,
+		Parent:     opts.Parent,
+		ParentHead: parentHead,
+		TTL:        ttl,
+		ExpiresAt:  expiresAt,
 	}
 	if err := m.saveSandbox(meta); err != nil {
-		_ = runCommandFn(m.repoRoot, io.Discard, io.Discard, "git", "worktree", "remove", worktree, "--force")
 		return nil, err
 	}
+	created = true
+	m.recordEvent(sandboxEvent{Type: eventCreate, Sandbox: name, Branch: branch, BaseRef: baseRef})
 	return meta, nil
 }
 
+func (m *manager) removeWorktreeAndBranch(worktree, branch string) {
+	_ = m.gitWorktree(io.Discard, io.Discard, "remove", worktree, "--force")
+	_ = runCommandFn(m.repoRoot, io.Discard, io.Discard, "git", "branch", "-D", branch)
+}
+
+// destroySandbox tears a sandbox down in an order that can be resumed: an
+// interrupted run leaves the metadata behind, and the next run skips the
+// steps that already happened.
 func (m *manager) destroySandbox(meta *sandboxMeta, force, deleteBranch bool) error {
-	_ = commandOutputNoErrFn("", "docker", "rm", "-f", meta.Container)
+	unlock, err := m.lockSandbox(meta.Name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return m.destroyLocked(meta, force, deleteBranch)
+}
+
+// destroyLocked is destroySandbox for callers that hold the sandbox lock.
+func (m *manager) destroyLocked(meta *sandboxMeta, force, deleteBranch bool) error {
+	m.removeSandboxContainers(meta)
+
+	if force || deleteBranch {
+		work, err := m.detectUnsavedWork(meta)
+		if err != nil {
+			return fmt.Errorf("detect unsaved work: %w", err)
+		}
+		if !work.empty() {
+			entry, err := m.archiveSandbox(meta, work)
+			if err != nil {
+				return fmt.Errorf("archive unsaved work: %w", err)
+			}
+			if !deleteBranch {
+				entry.BranchKept = true
+				if err := m.saveTrashEntry(entry); err != nil {
+					return err
+				}
+			}
+			fmt.Printf("archived unsaved work of %s (%d unpushed commit(s), %d changed, %d untracked file(s))\n",
+				meta.Name, work.Unpushed, len(work.Changed), len(work.Untracked))
+			fmt.Printf("recover with: vibe trash restore %s\n", entry.ID)
+		}
+	}
 
-	removeArgs := []string{"worktree", "remove", meta.Worktree}
+	removeArgs := []string{"remove", meta.Worktree}
 	if force {
 		removeArgs = append(removeArgs, "--force")
 	}
-	if err := runCommandFn(m.repoRoot, os.Stdout, os.Stderr, "git", removeArgs...); err != nil {
-		return fmt.Errorf("remove worktree: %w", err)
+	if err := m.gitWorktree(os.Stdout, os.Stderr, removeArgs...); err != nil {
+		if !m.worktreeGone(meta.Worktree) {
+			return fmt.Errorf("remove worktree: %w", err)
+		}
 	}
 
 	if deleteBranch {
@@ -120,17 +228,32 @@ func (m *manager) destroySandbox(meta *sandboxMeta, force, deleteBranch bool) er
 			branchDeleteFlag = "-D"
 		}
 		if err := runCommandFn(m.repoRoot, os.Stdout, os.Stderr, "git", "branch", branchDeleteFlag, meta.Branch); err != nil {
-			return fmt.Errorf("delete branch: %w", err)
+			if m.refExists("refs/heads/" + meta.Branch) {
+				return fmt.Errorf("delete branch: %w", err)
+			}
 		}
 	}
 
-	if err := os.Remove(m.metaPath(meta.Name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+	if err := os.RemoveAll(m.sessionDir(meta.Name)); err != nil {
+		return fmt.Errorf("remove session dir: %w", err)
+	}
+	if err := m.forgetSandbox(meta, force); err != nil {
 		return fmt.Errorf("remove metadata: %w", err)
 	}
+	if err := m.deleteCheckpoints(meta.Name); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	if err := m.unregisterSandbox(meta.Name); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: update sandbox registry: %v\n", err)
+	}
+	m.recordEvent(sandboxEvent{Type: eventDestroy, Sandbox: meta.Name, Branch: meta.Branch, Force: &force})
+	if err := m.reparentChildren(meta); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: restack children of %s: %v\n", meta.Name, err)
+	}
 	return nil
 }
 
-func (m *manager) destroyAllSandboxes(force, deleteBranch bool) (int, error) {
+func (m *manager) destroyAllSandboxes(force, deleteBranch bool, filter func(*sandboxMeta) bool) (int, error) {
 	metas, err := m.listSandboxes()
 	if err != nil {
 		return 0, err
@@ -143,6 +266,9 @@ func (m *manager) destroyAllSandboxes(force, deleteBranch bool) (int, error) {
 	)
 	for i := range metas {
 		meta := metas[i]
+		if filter != nil && !filter(&meta) {
+			continue
+		}
 		if err := m.destroySandbox(&meta, force, deleteBranch); err != nil {
 			failures = append(failures, fmt.Sprintf("%s: %v", meta.Name, err))
 			continue
@@ -156,58 +282,94 @@ func (m *manager) destroyAllSandboxes(force, deleteBranch bool) (int, error) {
 	return count, nil
 }
 
+// worktreeGone reports whether an earlier, interrupted destroy already removed
+// the worktree directory, pruning git's stale record of it if so.
+func (m *manager) worktreeGone(worktree string) bool {
+	if _, err := os.Stat(worktree); !errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	return m.gitWorktree(io.Discard, io.Discard, "prune") == nil
+}
+
 func (m *manager) loadSandbox(name string) (*sandboxMeta, error) {
-	path := m.metaPath(name)
-	b, err := os.ReadFile(path)
+	b, err := m.stateStore().get(name)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return nil, fmt.Errorf("sandbox %q not found", name)
 		}
 		return nil, fmt.Errorf("read metadata: %w", err)
 	}
-	var meta sandboxMeta
-	if err := json.Unmarshal(b, &meta); err != nil {
+	meta, err := m.decodeMeta(b)
+	if err != nil {
 		return nil, fmt.Errorf("decode metadata: %w", err)
 	}
-	return &meta, nil
+	return meta, nil
 }
 
-func (m *manager) saveSandbox(meta *sandboxMeta) error {
-	b, err := json.MarshalIndent(meta, "", "  ")
+// updateSandbox reloads the metadata of name under the sandbox lock, applies
+// fn and saves it, so concurrent commands do not overwrite each other's
+// fields. It refuses to recreate metadata removed in the meantime.
+func (m *manager) updateSandbox(name string, fn func(*sandboxMeta)) (*sandboxMeta, error) {
+	unlock, err := m.lockSandbox(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	tmp := m.metaPath(meta.Name) + ".tmp"
-	if err := os.WriteFile(tmp, b, 0o644); err != nil {
-		return err
+	defer unlock()
+	if !m.sandboxExists(name) {
+		return nil, fmt.Errorf("sandbox %q was removed", name)
+	}
+	meta, err := m.loadSandbox(name)
+	if err != nil {
+		return nil, err
+	}
+	fn(meta)
+	if err := m.saveSandbox(meta); err != nil {
+		return nil, err
 	}
-	if err := os.Rename(tmp, m.metaPath(meta.Name)); err != nil {
+	return meta, nil
+}
+
+func (m *manager) saveSandbox(meta *sandboxMeta) error {
+	if err := m.writeMeta(meta); err != nil {
 		return err
 	}
+	if err := m.registerSandbox(meta); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: update sandbox registry: %v\n", err)
+	}
 	return nil
 }
 
+// writeMeta stores meta at the current schema version. Replacing an older
+// record first moves its container to the upgraded name.
+func (m *manager) writeMeta(meta *sandboxMeta) error {
+	if prev, err := m.stateStore().get(meta.Name); err == nil {
+		if version, container, err := storedSchema(prev); err == nil && version < currentSchemaVersion {
+			if err := renameContainer(container, meta.Container); err != nil {
+				return err
+			}
+		}
+	}
+	meta.SchemaVersion = currentSchemaVersion
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return m.stateStore().put(meta.Name, b)
+}
+
 func (m *manager) listSandboxes() ([]sandboxMeta, error) {
-	entries, err := os.ReadDir(m.metaDir)
+	records, err := m.stateStore().list()
 	if err != nil {
-		return nil, fmt.Errorf("read metadata dir: %w", err)
+		return nil, err
 	}
 
-	metas := make([]sandboxMeta, 0, len(entries))
-	for _, entry := range entries {
-		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
-			continue
-		}
-		path := filepath.Join(m.metaDir, entry.Name())
-		b, err := os.ReadFile(path)
+	metas := make([]sandboxMeta, 0, len(records))
+	for _, r := range records {
+		meta, err := m.decodeMeta(r.data)
 		if err != nil {
-			return nil, err
-		}
-		var meta sandboxMeta
-		if err := json.Unmarshal(b, &meta); err != nil {
-			return nil, fmt.Errorf("decode %s: %w", path, err)
+			return nil, fmt.Errorf("decode %s: %w", r.source, err)
 		}
-		metas = append(metas, meta)
+		metas = append(metas, *meta)
 	}
 	return metas, nil
 }
@@ -108,7 +108,7 @@ func TestMetaPath(t *testing.T) {
 
 func TestCreateSandboxRejectsInvalidName(t *testing.T) {
 	m := newTestManager(t)
-	_, err := m.createSandbox("bad/name", "main", "")
+	_, err := m.createSandbox("bad/name", "main", "", sandboxOptions{})
 	if err == nil || !strings.Contains(err.Error(), "invalid sandbox name") {
 		t.Fatalf("expected invalid name error, got %v", err)
 	}
@@ -120,7 +120,7 @@ func TestCreateSandboxRejectsExistingMetadata(t *testing.T) {
 		t.Fatalf("write metadata: %v", err)
 	}
 
-	_, err := m.createSandbox("dup", "main", "")
+	_, err := m.createSandbox("dup", "main", "", sandboxOptions{})
 	if err == nil || !strings.Contains(err.Error(), `sandbox "dup" already exists`) {
 		t.Fatalf("expected existing metadata error, got %v", err)
 	}
@@ -132,7 +132,7 @@ func TestCreateSandboxRejectsExistingWorktreePath(t *testing.T) {
 		t.Fatalf("mkdir worktree: %v", err)
 	}
 
-	_, err := m.createSandbox("dup", "main", "")
+	_, err := m.createSandbox("dup", "main", "", sandboxOptions{})
 	if err == nil || !strings.Contains(err.Error(), "worktree path already exists") {
 		t.Fatalf("expected existing worktree error, got %v", err)
 	}
@@ -153,14 +153,14 @@ func TestCreateSandboxSuccess(t *testing.T) {
 		return nil
 	}
 
-	meta, err := m.createSandbox("feat-1", "main", "")
+	meta, err := m.createSandbox("feat-1", "main", "", sandboxOptions{})
 	if err != nil {
 		t.Fatalf("createSandbox returned error: %v", err)
 	}
 	if meta.Branch != defaultBranchPrefix+"/feat-1" {
 		t.Fatalf("branch = %q, want default prefix branch", meta.Branch)
 	}
-	if meta.Container != containerName("feat-1") {
+	if meta.Container != containerName(m.repoRoot, "feat-1") {
 		t.Fatalf("container = %q", meta.Container)
 	}
 	if gotDir != m.repoRoot || gotName != "git" {
@@ -191,16 +191,19 @@ func TestCreateSandboxSaveFailureRunsCleanup(t *testing.T) {
 		return nil
 	}
 
-	_, err := m.createSandbox("feat-2", "main", "")
+	_, err := m.createSandbox("feat-2", "main", "", sandboxOptions{})
 	if err == nil {
 		t.Fatal("expected saveSandbox failure")
 	}
-	if len(calls) != 2 {
+	if len(calls) != 3 {
 		t.Fatalf("expected add + cleanup calls, got %d (%+v)", len(calls), calls)
 	}
 	if !equalStrings(calls[1], []string{"worktree", "remove", filepath.Join(m.sandboxRoot, "feat-2"), "--force"}) {
 		t.Fatalf("cleanup args = %+v", calls[1])
 	}
+	if !equalStrings(calls[2], []string{"branch", "-D", "opencode/feat-2"}) {
+		t.Fatalf("cleanup args = %+v", calls[2])
+	}
 }
 
 func TestDestroySandboxSuccess(t *testing.T) {
@@ -282,14 +285,17 @@ func TestDestroySandboxWorktreeError(t *testing.T) {
 func TestDestroySandboxDeleteBranchError(t *testing.T) {
 	origRun := runCommandFn
 	origNoErr := commandOutputNoErrFn
+	origGit := gitOutputFn
 	t.Cleanup(func() {
 		runCommandFn = origRun
 		commandOutputNoErrFn = origNoErr
+		gitOutputFn = origGit
 	})
 
 	m := newTestManager(t)
 	meta := &sandboxMeta{Name: "x", Branch: "codex/x", Worktree: filepath.Join(m.sandboxRoot, "x"), Container: "codex-sb-x"}
 	commandOutputNoErrFn = func(dir, name string, args ...string) string { return "" }
+	gitOutputFn = func(dir string, args ...string) (string, error) { return "", nil }
 	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error {
 		if len(args) > 0 && args[0] == "branch" {
 			return errors.New("cannot delete")
@@ -314,6 +320,9 @@ func TestDestroyAllSandboxesAggregatesFailures(t *testing.T) {
 	m := newTestManager(t)
 	metaA := &sandboxMeta{Name: "a", Branch: "codex/a", BaseRef: "main", Worktree: filepath.Join(m.sandboxRoot, "a"), Container: "codex-sb-a"}
 	metaB := &sandboxMeta{Name: "b", Branch: "codex/b", BaseRef: "main", Worktree: filepath.Join(m.sandboxRoot, "b"), Container: "codex-sb-b"}
+	if err := os.MkdirAll(metaB.Worktree, 0o755); err != nil {
+		t.Fatalf("mkdir worktree: %v", err)
+	}
 	if err := m.saveSandbox(metaA); err != nil {
 		t.Fatalf("saveSandbox a: %v", err)
 	}
@@ -329,7 +338,7 @@ func TestDestroyAllSandboxesAggregatesFailures(t *testing.T) {
 		return nil
 	}
 
-	count, err := m.destroyAllSandboxes(false, false)
+	count, err := m.destroyAllSandboxes(false, false, nil)
 	if count != 1 {
 		t.Fatalf("count = %d, want 1", count)
 	}
@@ -338,6 +347,36 @@ func TestDestroyAllSandboxesAggregatesFailures(t *testing.T) {
 	}
 }
 
+func TestDestroyAllSandboxesFilter(t *testing.T) {
+	origRun := runCommandFn
+	origNoErr := commandOutputNoErrFn
+	t.Cleanup(func() {
+		runCommandFn = origRun
+		commandOutputNoErrFn = origNoErr
+	})
+
+	m := newTestManager(t)
+	for _, name := range []string{"a", "b"} {
+		meta := &sandboxMeta{Name: name, Branch: "codex/" + name, BaseRef: "main", Worktree: filepath.Join(m.sandboxRoot, name), Container: "codex-sb-" + name}
+		if err := m.saveSandbox(meta); err != nil {
+			t.Fatalf("saveSandbox %s: %v", name, err)
+		}
+	}
+	commandOutputNoErrFn = func(dir, name string, args ...string) string { return "" }
+	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error { return nil }
+
+	count, err := m.destroyAllSandboxes(false, false, func(meta *sandboxMeta) bool { return meta.Name == "b" })
+	if err != nil || count != 1 {
+		t.Fatalf("destroyAllSandboxes = %d, %v; want 1, nil", count, err)
+	}
+	if _, err := m.loadSandbox("a"); err != nil {
+		t.Fatalf("sandbox a should be kept: %v", err)
+	}
+	if _, err := m.loadSandbox("b"); err == nil {
+		t.Fatal("sandbox b should be removed")
+	}
+}
+
 func newTestManager(t *testing.T) *manager {
 	t.Helper()
 	repoRoot := t.TempDir()
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	currentSchemaVersion = 1
+	legacyBranchPrefix   = "codex"
+	legacyContainerName  = "codex-sb-"
+)
+
+// metaMigrations[v] upgrades decoded metadata from schema version v to v+1.
+// Files written before schema versions existed are version 0.
+var metaMigrations = []func(m *manager, raw map[string]any) error{
+	migrateMetaV0,
+}
+
+// migrateMetaV0 renames the container: unversioned metadata used names
+// without the repository hash, which collide across clones.
+func migrateMetaV0(m *manager, raw map[string]any) error {
+	name, _ := raw["name"].(string)
+	if name == "" {
+		return errors.New("missing sandbox name")
+	}
+	raw["container"] = containerName(m.repoRoot, name)
+	return nil
+}
+
+// decodeMeta decodes a metadata record and upgrades it to the current schema
+// in memory. Reads never write; vibe migrate persists upgrades.
+func (m *manager) decodeMeta(b []byte) (*sandboxMeta, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	version := rawSchemaVersion(raw)
+	if version > currentSchemaVersion {
+		return nil, fmt.Errorf("schema version %d is newer than this vibe supports (%d); upgrade vibe", version, currentSchemaVersion)
+	}
+	if version < currentSchemaVersion {
+		for ; version < currentSchemaVersion; version++ {
+			if err := metaMigrations[version](m, raw); err != nil {
+				return nil, fmt.Errorf("migrate from schema version %d: %w", version, err)
+			}
+			raw["schema_version"] = version + 1
+		}
+		var err error
+		if b, err = json.Marshal(raw); err != nil {
+			return nil, err
+		}
+	}
+	var meta sandboxMeta
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func rawSchemaVersion(raw map[string]any) int {
+	if v, ok := raw["schema_version"].(float64); ok {
+		return int(v)
+	}
+	return 0
+}
+
+// storedSchema reports the schema version and container name a metadata
+// record was written with.
+func storedSchema(b []byte) (int, string, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return 0, "", err
+	}
+	container, _ := raw["container"].(string)
+	return rawSchemaVersion(raw), container, nil
+}
+
+// upgradeSandboxes persists metadata upgrades, renaming containers whose
+// name changed. It returns the number of sandboxes upgraded, or that would be
+// with dryRun.
+func (m *manager) upgradeSandboxes(dryRun bool) (int, error) {
+	records, err := m.stateStore().list()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, r := range records {
+		version, _, err := storedSchema(r.data)
+		if err != nil {
+			return count, fmt.Errorf("decode %s: %w", r.source, err)
+		}
+		if version >= currentSchemaVersion {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("would upgrade metadata of %s from schema version %d\n", r.name, version)
+			count++
+			continue
+		}
+		if err := m.upgradeSandbox(r.name); err != nil {
+			return count, fmt.Errorf("upgrade %s: %w", r.name, err)
+		}
+		fmt.Printf("upgraded metadata of %s from schema version %d\n", r.name, version)
+		count++
+	}
+	return count, nil
+}
+
+func (m *manager) upgradeSandbox(name string) error {
+	unlock, err := m.lockSandbox(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	meta, err := m.loadSandbox(name)
+	if err != nil {
+		return err
+	}
+	return m.writeMeta(meta)
+}
+
+// renameContainer moves an existing container to the name upgraded metadata
+// points at, so the upgrade does not orphan it.
+func renameContainer(from, to string) error {
+	if from == "" || from == to {
+		return nil
+	}
+	out, _ := commandOutputFn("", "docker", "ps", "-a", "--filter", "name=^"+from+"$", "--format", "{{.Names}}")
+	if strings.TrimSpace(out) != from {
+		return nil
+	}
+	if _, err := commandOutputFn("", "docker", "rename", from, to); err != nil {
+		return fmt.Errorf("rename container %s to %s: %w", from, to, err)
+	}
+	return nil
+}
+
+type legacyMigration struct {
+	repoRoot   string
+	legacyRoot string
+	newRoot    string
+	dryRun     bool
+}
+
+// migrateLegacyLayout moves sandboxes created by the codex-era tool into the
+// current layout: the sandbox root moves to .opencode-sandboxes, codex/
+// branches become opencode/ branches and containers take hashed names.
+func migrateLegacyLayout(repoRoot string, dryRun bool) (int, error) {
+	lm := legacyMigration{
+		repoRoot:   repoRoot,
+		legacyRoot: filepath.Join(repoRoot, legacySandboxDir),
+		newRoot:    filepath.Join(repoRoot, defaultSandboxDir),
+		dryRun:     dryRun,
+	}
+	if _, err := os.Stat(lm.legacyRoot); errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("no legacy sandboxes in %s\n", lm.legacyRoot)
+		return 0, nil
+	}
+	if _, err := os.Stat(lm.newRoot); err == nil {
+		return 0, fmt.Errorf("both %s and %s exist; finish the sandboxes in one of them first", lm.legacyRoot, lm.newRoot)
+	}
+	return lm.run()
+}
+
+func (lm legacyMigration) run() (int, error) {
+	old, err := openManager(lm.repoRoot, lm.legacyRoot)
+	if err != nil {
+		return 0, err
+	}
+	lock, err := old.lockStore(true)
+	if err != nil {
+		return 0, err
+	}
+	metas, err := old.listSandboxes()
+	if err != nil {
+		lock.unlock()
+		return 0, err
+	}
+	legacyStates := map[string]string{}
+	for _, meta := range metas {
+		name := legacyContainerName + meta.Name
+		state, _ := commandOutputFn("", "docker", "ps", "-a", "--filter", "name=^"+name+"$", "--format", "{{.State}}")
+		if state == containerRunning {
+			lock.unlock()
+			return 0, fmt.Errorf("container %s of sandbox %s is running; stop it before migrating", name, meta.Name)
+		}
+		legacyStates[name] = state
+	}
+
+	branches := map[string]string{}
+	for _, meta := range metas {
+		if to := lm.renamedBranch(old, &meta); to != meta.Branch {
+			branches[meta.Branch] = to
+		}
+	}
+	if lm.dryRun {
+		lock.unlock()
+		fmt.Printf("would move %s to %s\n", lm.legacyRoot, lm.newRoot)
+		for _, meta := range metas {
+			if to, ok := branches[meta.Branch]; ok {
+				fmt.Printf("would rename branch %s to %s\n", meta.Branch, to)
+			}
+		}
+		return len(metas), nil
+	}
+
+	// The store lock lives inside the root being moved, so it is released
+	// once the move is done; the new root's lock takes over.
+	err = os.Rename(lm.legacyRoot, lm.newRoot)
+	lock.unlock()
+	if err != nil {
+		return 0, fmt.Errorf("move sandbox root: %w", err)
+	}
+	fmt.Printf("moved %s to %s\n", lm.legacyRoot, lm.newRoot)
+
+	m, err := openManager(lm.repoRoot, lm.newRoot)
+	if err != nil {
+		return 0, err
+	}
+	newLock, err := m.lockStore(true)
+	if err != nil {
+		return 0, err
+	}
+	defer newLock.unlock()
+
+	worktrees := []string{"repair"}
+	for i := range metas {
+		metas[i].Worktree = lm.relocate(metas[i].Worktree)
+		worktrees = append(worktrees, metas[i].Worktree)
+	}
+	if err := m.gitWorktree(io.Discard, os.Stderr, worktrees...); err != nil {
+		return 0, fmt.Errorf("repair worktrees: %w", err)
+	}
+
+	var failures []string
+	for i := range metas {
+		meta := &metas[i]
+		if to, ok := branches[meta.Branch]; ok {
+			if err := runCommandFn(m.repoRoot, io.Discard, os.Stderr, "git", "branch", "-m", meta.Branch, to); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: rename branch: %v", meta.Name, err))
+			} else {
+				fmt.Printf("renamed branch %s to %s\n", meta.Branch, to)
+				meta.Branch = to
+			}
+		}
+		if to, ok := branches[meta.BaseRef]; ok {
+			meta.BaseRef = to
+		}
+		legacy := legacyContainerName + meta.Name
+		if legacyStates[legacy] != "" {
+			_ = commandOutputNoErrFn("", "docker", "rm", legacy)
+		}
+		meta.Container = containerName(m.repoRoot, meta.Name)
+		if err := m.saveSandbox(meta); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", meta.Name, err))
+		}
+	}
+	if err := lm.relocateTrash(m, branches); err != nil {
+		failures = append(failures, fmt.Sprintf("trash: %v", err))
+	}
+	if len(failures) > 0 {
+		return len(metas), fmt.Errorf("failed to migrate some sandboxes:\n%s", strings.Join(failures, "\n"))
+	}
+	return len(metas), nil
+}
+
+// renamedBranch maps a codex/ branch to its opencode/ name. Branches behind
+// an open PR keep their name so later pushes still update the PR.
+func (lm legacyMigration) renamedBranch(m *manager, meta *sandboxMeta) string {
+	rest, ok := strings.CutPrefix(meta.Branch, legacyBranchPrefix+"/")
+	if !ok || meta.PRNumber != 0 {
+		return meta.Branch
+	}
+	to := defaultBranchPrefix + "/" + rest
+	if m.branchExists(to) {
+		fmt.Fprintf(os.Stderr, "warning: keep branch %s: %s already exists\n", meta.Branch, to)
+		return meta.Branch
+	}
+	return to
+}
+
+func (lm legacyMigration) relocate(path string) string {
+	rel, err := filepath.Rel(lm.legacyRoot, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.Join(lm.newRoot, rel)
+}
+
+func (lm legacyMigration) relocateTrash(m *manager, branches map[string]string) error {
+	entries, err := m.listTrash()
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		entry := &entries[i]
+		if entry.Tarball != "" {
+			entry.Tarball = lm.relocate(entry.Tarball)
+		}
+		entry.Sandbox.Worktree = lm.relocate(entry.Sandbox.Worktree)
+		entry.Sandbox.Container = containerName(m.repoRoot, entry.Sandbox.Name)
+		if to, ok := branches[entry.Sandbox.Branch]; ok {
+			entry.Sandbox.Branch = to
+		}
+		if err := m.saveTrashEntry(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeMetaUpgradesSchema(t *testing.T) {
+	origOutput := commandOutputFn
+	t.Cleanup(func() { commandOutputFn = origOutput })
+	var renamed []string
+	commandOutputFn = func(dir, name string, args ...string) (string, error) {
+		switch args[0] {
+		case "ps":
+			return "opencode-sb-old\n", nil
+		case "rename":
+			renamed = append(renamed, args[1:]...)
+		}
+		return "", nil
+	}
+	m := newTestManager(t)
+	legacy := `{"name": "old", "branch": "opencode/old", "worktree": "/tmp/old", "container": "opencode-sb-old", "created_at": "2025-01-01T00:00:00Z"}`
+	if err := os.WriteFile(m.metaPath("old"), []byte(legacy), 0o644); err != nil {
+		t.Fatalf("write metadata: %v", err)
+	}
+
+	meta, err := m.loadSandbox("old")
+	if err != nil {
+		t.Fatalf("loadSandbox: %v", err)
+	}
+	if meta.SchemaVersion != currentSchemaVersion || meta.Container != containerName(m.repoRoot, "old") {
+		t.Fatalf("not upgraded: %+v", meta)
+	}
+	if b, err := os.ReadFile(m.metaPath("old")); err != nil || string(b) != legacy || len(renamed) != 0 {
+		t.Fatalf("reads must not rewrite metadata: %s (%v), renamed %v", b, err, renamed)
+	}
+	if count, err := m.upgradeSandboxes(true); err != nil || count != 1 {
+		t.Fatalf("dry-run upgrade = %d, %v", count, err)
+	}
+	if b, err := os.ReadFile(m.metaPath("old")); err != nil || string(b) != legacy || len(renamed) != 0 {
+		t.Fatalf("dry run must not rewrite metadata: %s (%v), renamed %v", b, err, renamed)
+	}
+	if count, err := m.upgradeSandboxes(false); err != nil || count != 1 {
+		t.Fatalf("upgrade = %d, %v", count, err)
+	}
+	if strings.Join(renamed, " ") != "opencode-sb-old "+meta.Container {
+		t.Fatalf("container not renamed: %v", renamed)
+	}
+	b, err := os.ReadFile(m.metaPath("old"))
+	if err != nil {
+		t.Fatalf("read metadata: %v", err)
+	}
+	var onDisk sandboxMeta
+	if err := json.Unmarshal(b, &onDisk); err != nil || onDisk.SchemaVersion != currentSchemaVersion {
+		t.Fatalf("file not rewritten: %s (%v)", b, err)
+	}
+	if count, err := m.upgradeSandboxes(false); err != nil || count != 0 {
+		t.Fatalf("second upgrade = %d, %v", count, err)
+	}
+
+	future := `{"schema_version": 99, "name": "new"}`
+	if err := os.WriteFile(m.metaPath("new"), []byte(future), 0o644); err != nil {
+		t.Fatalf("write metadata: %v", err)
+	}
+	if _, err := m.loadSandbox("new"); err == nil || !strings.Contains(err.Error(), "newer than this vibe supports") {
+		t.Fatalf("expected newer schema error, got %v", err)
+	}
+}
+
+func TestMigrateLegacyLayout(t *testing.T) {
+	origOutput := commandOutputFn
+	t.Cleanup(func() { commandOutputFn = origOutput })
+	commandOutputFn = func(dir, name string, args ...string) (string, error) { return "", nil }
+
+	m := newGitManager(t)
+	if err := os.RemoveAll(m.sandboxRoot); err != nil {
+		t.Fatalf("remove sandbox root: %v", err)
+	}
+	legacyRoot := filepath.Join(m.repoRoot, legacySandboxDir)
+	old, err := openManager(m.repoRoot, legacyRoot)
+	if err != nil {
+		t.Fatalf("openManager: %v", err)
+	}
+	parent, err := old.createSandbox("parent", "main", legacyBranchPrefix, sandboxOptions{})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	commitFile(t, parent.Worktree, "work.txt", "x\n", "work")
+	if _, err := old.createSandbox("child", parent.Branch, legacyBranchPrefix, sandboxOptions{Parent: "parent"}); err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+
+	if count, err := migrateLegacyLayout(m.repoRoot, true); err != nil || count != 2 {
+		t.Fatalf("dry run = %d, %v", count, err)
+	}
+	if _, err := os.Stat(legacyRoot); err != nil {
+		t.Fatalf("dry run moved the sandbox root: %v", err)
+	}
+	if count, err := migrateLegacyLayout(m.repoRoot, false); err != nil || count != 2 {
+		t.Fatalf("migrateLegacyLayout = %d, %v", count, err)
+	}
+	if _, err := os.Stat(legacyRoot); !os.IsNotExist(err) {
+		t.Fatalf("legacy root should be gone, stat err=%v", err)
+	}
+
+	m, err = openManager(m.repoRoot, "")
+	if err != nil {
+		t.Fatalf("openManager: %v", err)
+	}
+	if m.sandboxRoot != filepath.Join(m.repoRoot, defaultSandboxDir) {
+		t.Fatalf("sandbox root = %s", m.sandboxRoot)
+	}
+	child, err := m.loadSandbox("child")
+	if err != nil {
+		t.Fatalf("loadSandbox: %v", err)
+	}
+	if child.Branch != "opencode/child" || child.BaseRef != "opencode/parent" || child.Worktree != filepath.Join(m.sandboxRoot, "child") {
+		t.Fatalf("child not migrated: %+v", child)
+	}
+	if child.Container != containerName(m.repoRoot, "child") {
+		t.Fatalf("container = %s", child.Container)
+	}
+	if m.branchExists("codex/parent") || !m.branchExists("opencode/parent") {
+		t.Fatal("parent branch not renamed")
+	}
+	head, err := gitOutputFn(child.Worktree, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil || head != "opencode/child" {
+		t.Fatalf("moved worktree is broken: %q, %v", head, err)
+	}
+	if issues, err := m.diagnose(); err != nil || len(issues) != 0 {
+		t.Fatalf("drift after migration: %+v (%v)", issues, err)
+	}
+
+	if err := os.MkdirAll(legacyRoot, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if _, err := migrateLegacyLayout(m.repoRoot, false); err == nil || !strings.Contains(err.Error(), "both") {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+}
@@ -23,8 +23,12 @@ func shortHash(input string) string {
 	return hex.EncodeToString(sum[:6])
 }
 
-func containerName(name string) string {
-	return "opencode-sb-" + normalizeName(name)
+const containerNamePrefix = "opencode-sb-"
+
+// containerName includes a hash of the repository root so sandboxes with the
+// same name in different repositories do not collide.
+func containerName(repoRoot, name string) string {
+	return containerNamePrefix + normalizeName(name) + "-" + shortHash(repoRoot)[:8]
 }
 
 var validNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
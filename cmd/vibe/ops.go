@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -21,34 +22,226 @@ func resolveBaseRef(repoRoot, base string) (string, error) {
 	return current, nil
 }
 
-func createPR(meta *sandboxMeta, base, title, body string, draft bool) error {
+func (m *manager) forgeFor(dir, remote string) (forge, error) {
+	remoteURL, err := gitOutputFn(dir, "remote", "get-url", remote)
+	if err != nil {
+		return nil, fmt.Errorf("resolve remote %q: %w", remote, err)
+	}
+	info, err := parseRemoteURL(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+	return newForge(m.config, info)
+}
+
+func (m *manager) createPR(meta *sandboxMeta, opts prOptions) error {
 	prBase := meta.BaseRef
-	if base != "" {
-		prBase = base
+	if opts.base != "" {
+		prBase = opts.base
+	}
+	remote := m.config.forgeRemote(opts.remote)
+	fg, err := m.forgeFor(meta.Worktree, remote)
+	if err != nil {
+		return err
 	}
 
-	if err := runCommandFn(meta.Worktree, os.Stdout, os.Stderr, "git", "push", "-u", "origin", meta.Branch); err != nil {
-		return fmt.Errorf("push branch: %w", err)
+	findings, err := m.scanSandboxSecrets(meta, prBase)
+	if err != nil {
+		return fmt.Errorf("scan for secrets: %w", err)
+	}
+	if len(findings) > 0 {
+		lines := make([]string, 0, len(findings))
+		for _, f := range findings {
+			lines = append(lines, "  "+f.String())
+		}
+		return fmt.Errorf("possible secrets in sandbox diff; remove them or add them to secrets.allowlist in %s/%s:\n%s", configDir, configFile, strings.Join(lines, "\n"))
 	}
 
-	ghArgs := []string{"pr", "create", "--head", meta.Branch, "--base", prBase}
-	if title != "" {
-		ghArgs = append(ghArgs, "--title", title)
+	violations, err := m.checkCommitPolicy(meta, prBase)
+	if err != nil {
+		return fmt.Errorf("check commit policy: %w", err)
 	}
-	if body != "" {
-		ghArgs = append(ghArgs, "--body", body)
+	if len(violations) > 0 {
+		lines := make([]string, 0, len(violations))
+		for _, v := range violations {
+			lines = append(lines, fmt.Sprintf("  %s: %s", v.Commit, v.Reason))
+		}
+		return fmt.Errorf("commits do not meet the commit policy:\n%s", strings.Join(lines, "\n"))
 	}
-	if title == "" && body == "" {
-		ghArgs = append(ghArgs, "--fill")
+
+	if !opts.skipChecks {
+		report, err := m.runChecks(meta)
+		if err != nil {
+			return err
+		}
+		if report != nil && !report.Passed {
+			return fmt.Errorf("checks failed: %s; see %s or pass --skip-checks", strings.Join(report.failed(), ", "), m.checksReportPath(meta.Name))
+		}
 	}
-	if draft {
-		ghArgs = append(ghArgs, "--draft")
+
+	req := prRequest{Base: prBase, Title: opts.title, Body: opts.body, Draft: opts.draft}
+	if req.Title == "" || req.Body == "" {
+		commits := listPRCommits(meta, prBase)
+		if req.Title == "" && req.Body == "" {
+			body, err := m.renderPRBody(meta, prBase, commits)
+			if err != nil {
+				return err
+			}
+			req.Body = body
+		}
+		if req.Title == "" {
+			req.Title = prTitle(meta, commits)
+		}
+	}
+	if opts.edit {
+		body, err := editText(req.Body)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(body) == "" {
+			return errors.New("aborting: pr body is empty")
+		}
+		req.Body = body
 	}
 
-	out, err := commandOutputFn(meta.Worktree, "gh", ghArgs...)
+	pushRemote, headOwner, err := m.resolvePushRemote(fg, remote, opts.pushRemote)
+	if err != nil {
+		return err
+	}
+	if err := runCommandFn(meta.Worktree, os.Stdout, os.Stderr, "git", "push", "-u", pushRemote, meta.Branch); err != nil {
+		return fmt.Errorf("push branch: %w", err)
+	}
+
+	req.Head = meta.Branch
+	if headOwner != "" {
+		req.Head = headOwner + ":" + meta.Branch
+	}
+	pr, err := fg.createPR(req)
 	if err != nil {
 		return fmt.Errorf("create pr: %w", err)
 	}
-	fmt.Println(strings.TrimSpace(out))
+	fmt.Println(pr.URL)
+
+	m.recordEvent(sandboxEvent{Type: eventPR, Sandbox: meta.Name, Branch: meta.Branch, BaseRef: prBase, PRNumber: pr.Number, PRURL: pr.URL})
+	saved, err := m.updateSandbox(meta.Name, func(meta *sandboxMeta) {
+		meta.PRNumber = pr.Number
+		meta.PRURL = pr.URL
+	})
+	if err != nil {
+		return fmt.Errorf("record pr in metadata: %w", err)
+	}
+	*meta = *saved
 	return nil
 }
+
+func (m *manager) resolvePushRemote(fg forge, remote, override string) (string, string, error) {
+	pushRemote := m.config.forgePushRemote(override)
+	f, canFork := fg.(forker)
+	if pushRemote == "" {
+		if !canFork {
+			return remote, "", nil
+		}
+		canPush, err := f.canPush()
+		if err != nil || canPush {
+			return remote, "", nil
+		}
+		if pushRemote, err = m.ensureForkRemote(f, remote); err != nil {
+			return "", "", err
+		}
+	}
+	if pushRemote == remote {
+		return remote, "", nil
+	}
+	if !canFork {
+		return "", "", fmt.Errorf("pushing to a fork remote is not supported for this forge; push to %q instead", remote)
+	}
+
+	pushURL, err := gitOutputFn(m.repoRoot, "remote", "get-url", pushRemote)
+	if err != nil {
+		return "", "", fmt.Errorf("resolve push remote %q: %w", pushRemote, err)
+	}
+	info, err := parseRemoteURL(pushURL)
+	if err != nil {
+		return "", "", err
+	}
+	return pushRemote, info.owner(), nil
+}
+
+func (m *manager) ensureForkRemote(f forker, remote string) (string, error) {
+	if _, err := gitOutputFn(m.repoRoot, "remote", "get-url", forkRemote); err == nil {
+		return forkRemote, nil
+	}
+	fmt.Printf("no push access to %s; forking the repository\n", remote)
+	repo, err := f.fork()
+	if err != nil {
+		return "", fmt.Errorf("fork repository: %w", err)
+	}
+	forkURL := repo.CloneURL
+	if upstreamURL, err := gitOutputFn(m.repoRoot, "remote", "get-url", remote); err == nil {
+		if info, err := parseRemoteURL(upstreamURL); err == nil && info.Scheme == "ssh" && repo.SSHURL != "" {
+			forkURL = repo.SSHURL
+		}
+	}
+	if forkURL == "" {
+		return "", fmt.Errorf("fork of %s returned no clone url", remote)
+	}
+	if err := waitForFork(f, repo); err != nil {
+		return "", err
+	}
+	if _, err := gitOutputFn(m.repoRoot, "remote", "add", forkRemote, forkURL); err != nil {
+		return "", fmt.Errorf("add fork remote: %w", err)
+	}
+	fmt.Printf("added remote %q for fork %s\n", forkRemote, forkURL)
+	return forkRemote, nil
+}
+
+type sandboxPRStatus struct {
+	Meta   sandboxMeta
+	Status *prStatus
+	Err    error
+}
+
+func (m *manager) prStatuses(remote string) ([]sandboxPRStatus, error) {
+	metas, err := m.listSandboxes()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(metas, func(i, j int) bool { return metas[i].Name < metas[j].Name })
+
+	var (
+		fg       forge
+		statuses []sandboxPRStatus
+	)
+	for _, meta := range metas {
+		if meta.PRNumber == 0 {
+			continue
+		}
+		if fg == nil {
+			fg, err = m.forgeFor(m.repoRoot, m.config.forgeRemote(remote))
+			if err != nil {
+				return nil, err
+			}
+		}
+		status, err := fg.prStatus(meta.PRNumber)
+		statuses = append(statuses, sandboxPRStatus{Meta: meta, Status: status, Err: err})
+	}
+	return statuses, nil
+}
+
+func (m *manager) mergedSandboxes(remote string) (map[string]bool, error) {
+	statuses, err := m.prStatuses(remote)
+	if err != nil {
+		return nil, err
+	}
+	merged := map[string]bool{}
+	for _, s := range statuses {
+		if s.Err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skip %s: pr status: %v\n", s.Meta.Name, s.Err)
+			continue
+		}
+		if s.Status.State == prStateMerged {
+			merged[s.Meta.Name] = true
+		}
+	}
+	return merged, nil
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestResolveBaseRef(t *testing.T) {
@@ -61,13 +67,38 @@ func TestResolveBaseRef(t *testing.T) {
 
 func TestCreatePRSuccess(t *testing.T) {
 	origRun := runCommandFn
-	origOut := commandOutputFn
+	origGit := gitOutputFn
 	t.Cleanup(func() {
 		runCommandFn = origRun
-		commandOutputFn = origOut
+		gitOutputFn = origGit
 	})
 
-	meta := &sandboxMeta{Worktree: "/repo/sb", Branch: "codex/feat-a", BaseRef: "main"}
+	var payload map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/repos/acme/app" {
+			_, _ = w.Write([]byte(`{"permissions": {"push": true}}`))
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/acme/app/pulls" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("missing auth header: %v", r.Header)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"number": 12, "html_url": "https://github.com/acme/app/pull/12"}`))
+	}))
+	defer srv.Close()
+
+	t.Setenv("GH_TOKEN", "tok")
+	m := newTestManager(t)
+	m.config = &vibeConfig{Forge: forgeConfig{APIURL: srv.URL}}
+	meta := &sandboxMeta{Name: "feat-a", Worktree: "/repo/sb", Branch: "codex/feat-a", BaseRef: "main"}
+	if err := m.writeMeta(meta); err != nil {
+		t.Fatalf("writeMeta: %v", err)
+	}
+	gitOutputFn = fakeGitRemote("git@github.com:acme/app.git", "abc123\x1fAdd login\x1fDetails\x1e")
 
 	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error {
 		if dir != meta.Worktree {
@@ -83,78 +114,297 @@ func TestCreatePRSuccess(t *testing.T) {
 		return nil
 	}
 
-	commandOutputFn = func(dir, name string, args ...string) (string, error) {
-		if dir != meta.Worktree {
-			t.Fatalf("gh dir = %q, want %q", dir, meta.Worktree)
-		}
-		if name != "gh" {
-			t.Fatalf("gh command = %q, want gh", name)
-		}
-		if !containsArg(args, "--head") || !containsArg(args, meta.Branch) {
-			t.Fatalf("gh args missing head branch: %+v", args)
-		}
-		if !containsPairArg(args, "--base", "develop") {
-			t.Fatalf("gh args missing base override: %+v", args)
-		}
-		if !containsArg(args, "--fill") {
-			t.Fatalf("gh args missing --fill: %+v", args)
-		}
-		if !containsArg(args, "--draft") {
-			t.Fatalf("gh args missing --draft: %+v", args)
-		}
-		return "https://example.test/pull/1\n", nil
+	if err := m.createPR(meta, prOptions{base: "develop", draft: true}); err != nil {
+		t.Fatalf("createPR returned error: %v", err)
+	}
+	if payload["head"] != meta.Branch || payload["base"] != "develop" || payload["draft"] != true {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+	body, _ := payload["body"].(string)
+	if payload["title"] != "Add login" || !strings.Contains(body, "- Add login (abc123)") || !strings.Contains(body, "Sandbox: feat-a (base: develop)") {
+		t.Fatalf("payload missing generated title/body: %+v", payload)
 	}
 
-	if err := createPR(meta, "develop", "", "", true); err != nil {
-		t.Fatalf("createPR returned error: %v", err)
+	saved, err := m.loadSandbox("feat-a")
+	if err != nil {
+		t.Fatalf("loadSandbox: %v", err)
+	}
+	if saved.PRNumber != 12 || saved.PRURL != "https://github.com/acme/app/pull/12" {
+		t.Fatalf("pr not recorded in metadata: %+v", saved)
 	}
 }
 
 func TestCreatePRPushError(t *testing.T) {
 	origRun := runCommandFn
-	origOut := commandOutputFn
+	origGit := gitOutputFn
 	t.Cleanup(func() {
 		runCommandFn = origRun
-		commandOutputFn = origOut
+		gitOutputFn = origGit
 	})
 
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"permissions": {"push": true}}`))
+	}))
+	defer srv.Close()
+
+	t.Setenv("GH_TOKEN", "tok")
+	m := newTestManager(t)
+	m.config = &vibeConfig{Forge: forgeConfig{APIURL: srv.URL}}
 	meta := &sandboxMeta{Worktree: "/repo/sb", Branch: "codex/feat-a", BaseRef: "main"}
+	gitOutputFn = fakeGitRemote("https://github.com/acme/app.git", "")
 	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error {
 		return errors.New("push failed")
 	}
-	commandOutputFn = func(dir, name string, args ...string) (string, error) {
-		t.Fatal("commandOutputFn should not be called on push failure")
-		return "", nil
-	}
 
-	err := createPR(meta, "", "", "", false)
+	err := m.createPR(meta, prOptions{})
 	if err == nil || !strings.Contains(err.Error(), "push branch") {
 		t.Fatalf("expected push branch error, got %v", err)
 	}
 }
 
-func TestCreatePRGhError(t *testing.T) {
+func TestCreatePRAPIError(t *testing.T) {
 	origRun := runCommandFn
-	origOut := commandOutputFn
+	origGit := gitOutputFn
 	t.Cleanup(func() {
 		runCommandFn = origRun
-		commandOutputFn = origOut
+		gitOutputFn = origGit
 	})
 
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	t.Setenv("GH_TOKEN", "tok")
+	m := newTestManager(t)
+	m.config = &vibeConfig{Forge: forgeConfig{APIURL: srv.URL}}
 	meta := &sandboxMeta{Worktree: "/repo/sb", Branch: "codex/feat-a", BaseRef: "main"}
+	gitOutputFn = fakeGitRemote("https://github.com/acme/app.git", "")
 	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error {
 		return nil
 	}
-	commandOutputFn = func(dir, name string, args ...string) (string, error) {
-		return "", errors.New("gh failed")
-	}
 
-	err := createPR(meta, "", "title", "body", false)
-	if err == nil || !strings.Contains(err.Error(), "create pr") {
+	err := m.createPR(meta, prOptions{title: "title", body: "body"})
+	if err == nil || !strings.Contains(err.Error(), "create pr") || !strings.Contains(err.Error(), "Bad credentials") {
 		t.Fatalf("expected create pr error, got %v", err)
 	}
 }
 
+func TestCreatePRForksWithoutPushAccess(t *testing.T) {
+	origRun := runCommandFn
+	origGit := gitOutputFn
+	origInterval := forkPollInterval
+	t.Cleanup(func() {
+		runCommandFn = origRun
+		gitOutputFn = origGit
+		forkPollInterval = origInterval
+	})
+	forkPollInterval = time.Millisecond
+
+	var head string
+	forked := false
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/app":
+			_, _ = w.Write([]byte(`{"permissions": {"push": false}}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/app/forks":
+			forked = true
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte(`{"full_name": "me/app", "clone_url": "https://github.com/me/app.git", "ssh_url": "git@github.com:me/app.git"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/me/app":
+			polls++
+			if polls < 3 {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(`{"full_name": "me/app"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/app/pulls":
+			var got map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&got)
+			head, _ = got["head"].(string)
+			_, _ = w.Write([]byte(`{"number": 3, "html_url": "https://github.com/acme/app/pull/3"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("GH_TOKEN", "tok")
+	m := newTestManager(t)
+	m.config = &vibeConfig{Forge: forgeConfig{APIURL: srv.URL}}
+	meta := &sandboxMeta{Name: "feat-a", Worktree: "/repo/sb", Branch: "codex/feat-a", BaseRef: "main"}
+	if err := m.writeMeta(meta); err != nil {
+		t.Fatalf("writeMeta: %v", err)
+	}
+
+	remotes := map[string]string{"origin": "git@github.com:acme/app.git"}
+	gitOutputFn = func(dir string, args ...string) (string, error) {
+		switch {
+		case len(args) == 3 && args[0] == "remote" && args[1] == "get-url":
+			if url, ok := remotes[args[2]]; ok {
+				return url, nil
+			}
+			return "", fmt.Errorf("no such remote %q", args[2])
+		case len(args) == 4 && args[0] == "remote" && args[1] == "add":
+			if polls < 3 {
+				t.Errorf("fork remote added before the fork was ready (polls=%d)", polls)
+			}
+			remotes[args[2]] = args[3]
+			return "", nil
+		case len(args) > 0 && args[0] == "diff":
+			return "", nil
+		}
+		return "", fmt.Errorf("unexpected git call %v", args)
+	}
+	var pushArgs []string
+	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error {
+		pushArgs = args
+		return nil
+	}
+
+	if err := m.createPR(meta, prOptions{title: "t", body: "b"}); err != nil {
+		t.Fatalf("createPR returned error: %v", err)
+	}
+	if !forked || remotes[forkRemote] != "git@github.com:me/app.git" {
+		t.Fatalf("fork remote not created: forked=%v remotes=%v", forked, remotes)
+	}
+	if !equalStrings(pushArgs, []string{"push", "-u", forkRemote, "codex/feat-a"}) {
+		t.Fatalf("push args = %v", pushArgs)
+	}
+	if head != "me:codex/feat-a" {
+		t.Fatalf("head = %q, want me:codex/feat-a", head)
+	}
+}
+
+func TestResolvePushRemoteExplicit(t *testing.T) {
+	origGit := gitOutputFn
+	t.Cleanup(func() { gitOutputFn = origGit })
+
+	gitOutputFn = fakeGitRemote("https://github.com/me/app.git", "")
+	m := newTestManager(t)
+	m.config = &vibeConfig{Forge: forgeConfig{PushRemote: "mine"}}
+
+	remote, owner, err := m.resolvePushRemote(&githubForge{}, "origin", "")
+	if err != nil || remote != "mine" || owner != "me" {
+		t.Fatalf("resolvePushRemote = %q, %q, %v", remote, owner, err)
+	}
+	if remote, owner, err := m.resolvePushRemote(&githubForge{}, "origin", "origin"); err != nil || remote != "origin" || owner != "" {
+		t.Fatalf("push to upstream = %q, %q, %v", remote, owner, err)
+	}
+	if _, _, err := m.resolvePushRemote(&gitlabForge{}, "origin", "mine"); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Fatalf("expected unsupported fork error, got %v", err)
+	}
+}
+
+func TestCreatePRUnknownForge(t *testing.T) {
+	origRun := runCommandFn
+	origGit := gitOutputFn
+	t.Cleanup(func() {
+		runCommandFn = origRun
+		gitOutputFn = origGit
+	})
+
+	meta := &sandboxMeta{Worktree: "/repo/sb", Branch: "opencode/x", BaseRef: "main"}
+	gitOutputFn = fakeGitRemote("git@git.internal.example:team/app.git", "")
+	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error {
+		t.Fatal("branch must not be pushed when the forge is unknown")
+		return nil
+	}
+
+	err := newTestManager(t).createPR(meta, prOptions{})
+	if err == nil || !strings.Contains(err.Error(), "cannot detect forge") {
+		t.Fatalf("expected forge detection error, got %v", err)
+	}
+}
+
+func TestMergedSandboxes(t *testing.T) {
+	origGit := gitOutputFn
+	t.Cleanup(func() { gitOutputFn = origGit })
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/app/pulls/1":
+			_, _ = w.Write([]byte(`{"state": "closed", "merged": true, "head": {"sha": "a1"}}`))
+		case "/repos/acme/app/pulls/2":
+			_, _ = w.Write([]byte(`{"state": "open", "merged": false, "head": {"sha": "b2"}}`))
+		case "/repos/acme/app/pulls/3":
+			w.WriteHeader(http.StatusNotFound)
+		case "/repos/acme/app/commits/a1/check-runs", "/repos/acme/app/commits/b2/check-runs":
+			_, _ = w.Write([]byte(`{"check_runs": []}`))
+		default:
+			_, _ = w.Write([]byte(`[]`))
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("GH_TOKEN", "tok")
+	m := newTestManager(t)
+	m.config = &vibeConfig{Forge: forgeConfig{APIURL: srv.URL}}
+	for _, meta := range []*sandboxMeta{
+		{Name: "merged", Branch: "codex/merged", PRNumber: 1},
+		{Name: "open", Branch: "codex/open", PRNumber: 2},
+		{Name: "gone", Branch: "codex/gone", PRNumber: 3},
+		{Name: "nopr", Branch: "codex/nopr"},
+	} {
+		if err := m.saveSandbox(meta); err != nil {
+			t.Fatalf("saveSandbox %s: %v", meta.Name, err)
+		}
+	}
+	gitOutputFn = fakeGitRemote("https://github.com/acme/app.git", "")
+
+	statuses, err := m.prStatuses("")
+	if err != nil {
+		t.Fatalf("prStatuses returned error: %v", err)
+	}
+	if len(statuses) != 3 || statuses[0].Meta.Name != "gone" || statuses[0].Err == nil {
+		t.Fatalf("unexpected statuses: %+v", statuses)
+	}
+
+	merged, err := m.mergedSandboxes("")
+	if err != nil {
+		t.Fatalf("mergedSandboxes returned error: %v", err)
+	}
+	if len(merged) != 1 || !merged["merged"] {
+		t.Fatalf("merged = %v, want only merged", merged)
+	}
+}
+
+func TestPRTitle(t *testing.T) {
+	origGit := gitOutputFn
+	t.Cleanup(func() { gitOutputFn = origGit })
+	meta := &sandboxMeta{Name: "feat-login", Branch: "opencode/feat-login"}
+
+	gitOutputFn = fakeGitRemote("", "a1\x1fFirst\x1f\x1eb2\x1fSecond\x1fbody\x1e")
+	commits := listPRCommits(meta, "main")
+	if len(commits) != 2 || commits[1].Hash != "b2" || commits[1].Body != "body" {
+		t.Fatalf("listPRCommits = %+v", commits)
+	}
+	if got := prTitle(meta, commits); got != "feat login" {
+		t.Fatalf("prTitle = %q, want %q", got, "feat login")
+	}
+	if got := prTitle(meta, commits[:1]); got != "First" {
+		t.Fatalf("prTitle single commit = %q, want %q", got, "First")
+	}
+	if got := prTitle(meta, nil); got != "feat-login" {
+		t.Fatalf("prTitle without commits = %q", got)
+	}
+}
+
+func fakeGitRemote(remoteURL, log string) func(string, ...string) (string, error) {
+	return func(dir string, args ...string) (string, error) {
+		switch {
+		case len(args) >= 2 && args[0] == "remote" && args[1] == "get-url":
+			return remoteURL, nil
+		case len(args) > 0 && args[0] == "log":
+			return log, nil
+		case len(args) > 0 && (args[0] == "diff" || args[0] == "status"):
+			return "", nil
+		}
+		return "", fmt.Errorf("unexpected git call %v", args)
+	}
+}
+
 func containsArg(args []string, want string) bool {
 	for _, arg := range args {
 		if arg == want {
@@ -172,3 +422,37 @@ func containsPairArg(args []string, key, val string) bool {
 	}
 	return false
 }
+
+func TestCreatePRAbortedEditDoesNotPush(t *testing.T) {
+	origRun := runCommandFn
+	origGit := gitOutputFn
+	origInteractive := interactiveCommandFn
+	t.Cleanup(func() {
+		runCommandFn = origRun
+		gitOutputFn = origGit
+		interactiveCommandFn = origInteractive
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	t.Setenv("GH_TOKEN", "tok")
+	t.Setenv("VISUAL", "true")
+	m := newTestManager(t)
+	m.config = &vibeConfig{Forge: forgeConfig{APIURL: srv.URL}}
+	meta := &sandboxMeta{Name: "feat-a", Worktree: "/repo/sb", Branch: "codex/feat-a", BaseRef: "main"}
+	gitOutputFn = fakeGitRemote("git@github.com:acme/app.git", "abc123\x1fAdd login\x1fDetails\x1e")
+	interactiveCommandFn = func(name string, args ...string) error {
+		return os.WriteFile(args[len(args)-1], []byte("  \n"), 0o644)
+	}
+	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error {
+		t.Fatalf("pushed before the body was edited: %s %v", name, args)
+		return nil
+	}
+
+	if err := m.createPR(meta, prOptions{edit: true}); err == nil || !strings.Contains(err.Error(), "pr body is empty") {
+		t.Fatalf("expected empty body error, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const (
+	prTemplateFile     = "pr_template.md"
+	maxTestResultsSize = 20000
+)
+
+const defaultPRTemplate = `{{if .Prompt}}## Task
+
+{{.Prompt}}
+
+{{end}}## Changes
+
+{{range .Commits}}- {{.Subject}} ({{.Hash}})
+{{end}}{{if .Diffstat}}
+~~~
+{{.Diffstat}}
+~~~
+{{end}}{{if .TestResults}}
+## Test results
+
+~~~
+{{.TestResults}}
+~~~
+{{end}}{{if .Checks}}
+## Checks
+
+{{.Checks}}
+{{end}}
+Sandbox: {{.Name}} (base: {{.Base}})
+`
+
+type prCommit struct {
+	Hash    string
+	Subject string
+	Body    string
+}
+
+type prTemplateData struct {
+	Name        string
+	Branch      string
+	Base        string
+	Prompt      string
+	Commits     []prCommit
+	Diffstat    string
+	TestResults string
+	Checks      string
+}
+
+func listPRCommits(meta *sandboxMeta, base string) []prCommit {
+	out, err := gitOutputFn(meta.Worktree, "log", "--reverse", "--format=%h%x1f%s%x1f%b%x1e", base+".."+meta.Branch)
+	if err != nil {
+		return nil
+	}
+	var commits []prCommit
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.SplitN(strings.TrimSpace(record), "\x1f", 3)
+		if len(fields) < 2 || fields[1] == "" {
+			continue
+		}
+		commit := prCommit{Hash: fields[0], Subject: fields[1]}
+		if len(fields) == 3 {
+			commit.Body = strings.TrimSpace(fields[2])
+		}
+		commits = append(commits, commit)
+	}
+	return commits
+}
+
+func prTitle(meta *sandboxMeta, commits []prCommit) string {
+	switch len(commits) {
+	case 0:
+		return meta.Name
+	case 1:
+		return commits[0].Subject
+	}
+	return strings.ReplaceAll(meta.Name, "-", " ")
+}
+
+func (m *manager) renderPRBody(meta *sandboxMeta, base string, commits []prCommit) (string, error) {
+	text := defaultPRTemplate
+	path := filepath.Join(m.repoRoot, configDir, prTemplateFile)
+	raw, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		text = string(raw)
+	case !errors.Is(err, os.ErrNotExist):
+		return "", fmt.Errorf("read pr template: %w", err)
+	}
+	tmpl, err := template.New(prTemplateFile).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse pr template: %w", err)
+	}
+
+	data := prTemplateData{
+		Name:    meta.Name,
+		Branch:  meta.Branch,
+		Base:    base,
+		Prompt:  meta.Prompt,
+		Commits: commits,
+	}
+	data.Diffstat, _ = gitOutputFn(meta.Worktree, "diff", "--stat", base+"..."+meta.Branch)
+	if b, err := os.ReadFile(filepath.Join(m.sessionDir(meta.Name), sessionTestResults)); err == nil {
+		results := strings.TrimSpace(string(b))
+		if len(results) > maxTestResultsSize {
+			results = results[len(results)-maxTestResultsSize:]
+		}
+		data.TestResults = results
+	}
+
+	head, _ := gitOutputFn(meta.Worktree, "rev-parse", meta.Branch)
+	if report, err := m.loadCheckReport(meta.Name); err == nil && report.Commit == head {
+		data.Checks = report.summary()
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render pr template: %w", err)
+	}
+	return strings.TrimSpace(buf.String()) + "\n", nil
+}
+
+func editText(text string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	f, err := os.CreateTemp("", "vibe-pr-*.md")
+	if err != nil {
+		return "", fmt.Errorf("create temp file: %w", err)
+	}
+	path := f.Name()
+	defer os.Remove(path)
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return "", fmt.Errorf("write temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("write temp file: %w", err)
+	}
+	if err := interactiveCommandFn("sh", "-c", editor+` "$1"`, "vibe-editor", path); err != nil {
+		return "", fmt.Errorf("run editor: %w", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read edited text: %w", err)
+	}
+	return string(b), nil
+}
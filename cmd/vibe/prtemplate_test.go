@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderPRBodyDefaultTemplate(t *testing.T) {
+	m, meta := newGitSandbox(t, "tmpl")
+	meta.Prompt = "Implement login"
+	commitFile(t, meta.Worktree, "login.go", "package login\n", "Add login")
+	if err := os.MkdirAll(m.sessionDir(meta.Name), 0o755); err != nil {
+		t.Fatalf("mkdir session: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(m.sessionDir(meta.Name), sessionTestResults), []byte("ok  pkg/login\n"), 0o644); err != nil {
+		t.Fatalf("write test results: %v", err)
+	}
+	head, _ := gitOutput(meta.Worktree, "rev-parse", "HEAD")
+	report := `{"commit": "` + head + `", "passed": true, "results": [{"name": "lint", "passed": true, "duration": "1s"}]}`
+	if err := os.WriteFile(m.checksReportPath(meta.Name), []byte(report), 0o644); err != nil {
+		t.Fatalf("write checks report: %v", err)
+	}
+
+	body, err := m.renderPRBody(meta, "main", listPRCommits(meta, "main"))
+	if err != nil {
+		t.Fatalf("renderPRBody: %v", err)
+	}
+	for _, want := range []string{"## Task\n\nImplement login", "- Add login (", "login.go", "ok  pkg/login", "## Checks\n\n- lint: passed (1s)", "Sandbox: tmpl (base: main)"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("body missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestRenderPRBodyRepoTemplate(t *testing.T) {
+	m, meta := newGitSandbox(t, "custom")
+	commitFile(t, meta.Worktree, "a.txt", "a\n", "First")
+	commitFile(t, meta.Worktree, "b.txt", "b\n", "Second")
+	if err := os.MkdirAll(filepath.Join(m.repoRoot, configDir), 0o755); err != nil {
+		t.Fatalf("mkdir config dir: %v", err)
+	}
+	tmpl := "{{.Name}} -> {{.Base}}\n{{range .Commits}}* {{.Subject}}\n{{end}}"
+	if err := os.WriteFile(filepath.Join(m.repoRoot, configDir, prTemplateFile), []byte(tmpl), 0o644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+
+	body, err := m.renderPRBody(meta, "main", listPRCommits(meta, "main"))
+	if err != nil {
+		t.Fatalf("renderPRBody: %v", err)
+	}
+	if body != "custom -> main\n* First\n* Second\n" {
+		t.Fatalf("body = %q", body)
+	}
+
+	if err := os.WriteFile(filepath.Join(m.repoRoot, configDir, prTemplateFile), []byte("{{.Nope"), 0o644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	if _, err := m.renderPRBody(meta, "main", nil); err == nil || !strings.Contains(err.Error(), "parse pr template") {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+}
+
+func TestEditText(t *testing.T) {
+	origInteractive := interactiveCommandFn
+	t.Cleanup(func() { interactiveCommandFn = origInteractive })
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "my-editor --wait")
+
+	interactiveCommandFn = func(name string, args ...string) error {
+		if name != "sh" || len(args) != 4 || args[1] != `my-editor --wait "$1"` {
+			t.Fatalf("unexpected editor invocation %s %v", name, args)
+		}
+		return os.WriteFile(args[3], []byte("edited body\n"), 0o644)
+	}
+
+	got, err := editText("original")
+	if err != nil {
+		t.Fatalf("editText: %v", err)
+	}
+	if got != "edited body\n" {
+		t.Fatalf("editText = %q", got)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const registryFile = "registry.json"
+
+type registryEntry struct {
+	Repo        string `json:"repo"`
+	SandboxRoot string `json:"sandbox_root"`
+	Name        string `json:"name"`
+	Branch      string `json:"branch"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type registry struct {
+	Sandboxes []registryEntry `json:"sandboxes"`
+}
+
+func registryDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "vibe"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve home dir: %w", err)
+	}
+	return filepath.Join(home, ".local", "state", "vibe"), nil
+}
+
+func loadRegistry() (*registry, error) {
+	dir, err := registryDir()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(filepath.Join(dir, registryFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &registry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read registry: %w", err)
+	}
+	var reg registry
+	if err := json.Unmarshal(b, &reg); err != nil {
+		return nil, fmt.Errorf("decode registry: %w", err)
+	}
+	return &reg, nil
+}
+
+func (r *registry) save() error {
+	dir, err := registryDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create registry dir: %w", err)
+	}
+	sort.Slice(r.Sandboxes, func(i, j int) bool {
+		if r.Sandboxes[i].Repo != r.Sandboxes[j].Repo {
+			return r.Sandboxes[i].Repo < r.Sandboxes[j].Repo
+		}
+		return r.Sandboxes[i].Name < r.Sandboxes[j].Name
+	})
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, registryFile), b); err != nil {
+		return fmt.Errorf("write registry: %w", err)
+	}
+	return nil
+}
+
+// lockRegistry serialises read-modify-write cycles on the registry across
+// processes working in different repositories.
+func lockRegistry() (*fileLock, error) {
+	dir, err := registryDir()
+	if err != nil {
+		return nil, err
+	}
+	return openLock(filepath.Join(dir, registryFile+".lock"), true)
+}
+
+// updateRegistry applies fn to the registry under its lock and saves the
+// result when fn reports a change.
+func updateRegistry(fn func(*registry) bool) error {
+	lock, err := lockRegistry()
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+	reg, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	if !fn(reg) {
+		return nil
+	}
+	return reg.save()
+}
+
+func (r *registry) remove(repo, name string) bool {
+	kept := r.Sandboxes[:0]
+	for _, e := range r.Sandboxes {
+		if e.Repo != repo || e.Name != name {
+			kept = append(kept, e)
+		}
+	}
+	removed := len(kept) != len(r.Sandboxes)
+	r.Sandboxes = kept
+	return removed
+}
+
+// repos returns each registered repository once, with its sandbox root.
+func (r *registry) repos() []registryEntry {
+	seen := map[string]bool{}
+	var repos []registryEntry
+	for _, e := range r.Sandboxes {
+		if !seen[e.Repo] {
+			seen[e.Repo] = true
+			repos = append(repos, registryEntry{Repo: e.Repo, SandboxRoot: e.SandboxRoot})
+		}
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Repo < repos[j].Repo })
+	return repos
+}
+
+func (m *manager) registerSandbox(meta *sandboxMeta) error {
+	return updateRegistry(func(reg *registry) bool {
+		reg.remove(m.repoRoot, meta.Name)
+		reg.Sandboxes = append(reg.Sandboxes, registryEntry{
+			Repo:        m.repoRoot,
+			SandboxRoot: m.sandboxRoot,
+			Name:        meta.Name,
+			Branch:      meta.Branch,
+			CreatedAt:   meta.CreatedAt,
+		})
+		return true
+	})
+}
+
+func (m *manager) unregisterSandbox(name string) error {
+	return updateRegistry(func(reg *registry) bool {
+		return reg.remove(m.repoRoot, name)
+	})
+}
+
+// globalManagers opens a manager for every repository in the registry and
+// re-indexes each repository from its metadata, so the registry heals after
+// sandboxes are added or removed behind its back.
+func globalManagers() ([]*manager, error) {
+	lock, err := lockRegistry()
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlock()
+	reg, err := loadRegistry()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		managers []*manager
+		entries  []registryEntry
+	)
+	for _, repo := range reg.repos() {
+		if _, err := os.Stat(repo.Repo); errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "warning: drop %s from registry: repository is gone\n", repo.Repo)
+			continue
+		}
+		m, err := openManager(repo.Repo, repo.SandboxRoot)
+		if err == nil {
+			var metas []sandboxMeta
+			if metas, err = m.listSandboxes(); err == nil {
+				for _, meta := range metas {
+					entries = append(entries, registryEntry{Repo: m.repoRoot, SandboxRoot: m.sandboxRoot, Name: meta.Name, Branch: meta.Branch, CreatedAt: meta.CreatedAt})
+				}
+				managers = append(managers, m)
+				continue
+			}
+		}
+		fmt.Fprintf(os.Stderr, "warning: skip %s: %v\n", repo.Repo, err)
+		for _, e := range reg.Sandboxes {
+			if e.Repo == repo.Repo {
+				entries = append(entries, e)
+			}
+		}
+	}
+	reg.Sandboxes = entries
+	if err := reg.save(); err != nil {
+		return nil, err
+	}
+	return managers, nil
+}
+
+func sandboxAddress(repo, name string) string {
+	return filepath.Base(repo) + ":" + name
+}
+
+// resolveRepoAddress maps the repo part of a repo:name address to a
+// registered repository, given either as a path or as the directory name.
+// An explicit sandboxRoot applies to repositories given by path.
+func resolveRepoAddress(repo, sandboxRoot string) (registryEntry, error) {
+	reg, err := loadRegistry()
+	if err != nil {
+		return registryEntry{}, err
+	}
+	repos := reg.repos()
+	if filepath.IsAbs(repo) || strings.HasPrefix(repo, ".") || strings.Contains(repo, string(filepath.Separator)) {
+		abs, err := filepath.Abs(repo)
+		if err != nil {
+			return registryEntry{}, err
+		}
+		if sandboxRoot != "" {
+			return registryEntry{Repo: abs, SandboxRoot: sandboxRoot}, nil
+		}
+		for _, r := range repos {
+			if r.Repo == abs {
+				return r, nil
+			}
+		}
+		return registryEntry{Repo: abs, SandboxRoot: resolveSandboxRoot(abs, "")}, nil
+	}
+	var matches []registryEntry
+	for _, r := range repos {
+		if filepath.Base(r.Repo) == repo {
+			matches = append(matches, r)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return registryEntry{}, fmt.Errorf("no registered repository named %q", repo)
+	case 1:
+		return matches[0], nil
+	}
+	var paths []string
+	for _, r := range matches {
+		paths = append(paths, r.Repo)
+	}
+	return registryEntry{}, fmt.Errorf("repository name %q is ambiguous, use a path: %s", repo, strings.Join(paths, ", "))
+}
+
+// openAddress opens the manager for a sandbox address, which is either a
+// name in the current repository or repo:name for any registered repository.
+func openAddress(rootOpts *rootOptions, address string) (*manager, string, error) {
+	i := strings.LastIndex(address, ":")
+	if i < 0 {
+		m, err := newManager(rootOpts.sandboxRoot)
+		if err != nil {
+			return nil, "", fmt.Errorf("init failed: %w", err)
+		}
+		return m, normalizeName(address), nil
+	}
+	repo, err := resolveRepoAddress(address[:i], rootOpts.sandboxRoot)
+	if err != nil {
+		return nil, "", err
+	}
+	m, err := openManager(repo.Repo, repo.SandboxRoot)
+	if err != nil {
+		return nil, "", fmt.Errorf("init failed: %w", err)
+	}
+	return m, normalizeName(address[i+1:]), nil
+}
+
+func openSandbox(rootOpts *rootOptions, address string) (*manager, *sandboxMeta, error) {
+	m, name, err := openAddress(rootOpts, address)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta, err := m.loadSandbox(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, meta, nil
+}
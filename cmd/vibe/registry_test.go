@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegistryAcrossRepos(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	first, _ := newGitSandbox(t, "same")
+	second, _ := newGitSandbox(t, "same")
+	if _, err := second.createSandbox("other", "main", "", sandboxOptions{}); err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+
+	reg, err := loadRegistry()
+	if err != nil {
+		t.Fatalf("loadRegistry: %v", err)
+	}
+	if len(reg.Sandboxes) != 3 || len(reg.repos()) != 2 {
+		t.Fatalf("unexpected registry %+v", reg.Sandboxes)
+	}
+
+	statuses, err := globalStatuses(listFilter{}, false)
+	if err != nil {
+		t.Fatalf("globalStatuses: %v", err)
+	}
+	var names []string
+	for _, s := range statuses {
+		names = append(names, s.displayName())
+	}
+	want := []string{
+		sandboxAddress(first.repoRoot, "same"),
+		sandboxAddress(second.repoRoot, "other"),
+		sandboxAddress(second.repoRoot, "same"),
+	}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Fatalf("global names = %v, want %v", names, want)
+	}
+
+	m, meta, err := openSandbox(&rootOptions{}, filepath.Base(second.repoRoot)+":other")
+	if err != nil {
+		t.Fatalf("openSandbox: %v", err)
+	}
+	if m.repoRoot != second.repoRoot || meta.Name != "other" {
+		t.Fatalf("opened %s:%s", m.repoRoot, meta.Name)
+	}
+	if _, _, err := openSandbox(&rootOptions{}, second.repoRoot+":same"); err != nil {
+		t.Fatalf("openSandbox by path: %v", err)
+	}
+	if _, meta, err := openSandbox(&rootOptions{}, filepath.Base(second.repoRoot)+":Other"); err != nil || meta.Name != "other" {
+		t.Fatalf("openSandbox should normalize the name: %+v, %v", meta, err)
+	}
+	custom, err := openManager(second.repoRoot, filepath.Join(t.TempDir(), "custom"))
+	if err != nil {
+		t.Fatalf("openManager: %v", err)
+	}
+	if _, err := custom.createSandbox("elsewhere", "main", "", sandboxOptions{}); err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	if _, meta, err := openSandbox(&rootOptions{sandboxRoot: custom.sandboxRoot}, second.repoRoot+":elsewhere"); err != nil || meta.Name != "elsewhere" {
+		t.Fatalf("path address should honor --sandbox-root: %+v, %v", meta, err)
+	}
+	if err := custom.destroySandbox(&sandboxMeta{Name: "elsewhere", Branch: "opencode/elsewhere", Worktree: filepath.Join(custom.sandboxRoot, "elsewhere")}, true, true); err != nil {
+		t.Fatalf("destroySandbox: %v", err)
+	}
+	if _, _, err := openSandbox(&rootOptions{}, "nope:same"); err == nil || !strings.Contains(err.Error(), "no registered repository") {
+		t.Fatalf("expected unknown repo error, got %v", err)
+	}
+
+	if err := m.destroySandbox(meta, true, true); err != nil {
+		t.Fatalf("destroySandbox: %v", err)
+	}
+	if err := os.Remove(first.metaPath("same")); err != nil {
+		t.Fatalf("remove meta: %v", err)
+	}
+	if _, err := globalManagers(); err != nil {
+		t.Fatalf("globalManagers: %v", err)
+	}
+	reg, err = loadRegistry()
+	if err != nil {
+		t.Fatalf("loadRegistry: %v", err)
+	}
+	if len(reg.Sandboxes) != 1 || reg.Sandboxes[0].Repo != second.repoRoot || reg.Sandboxes[0].Name != "same" {
+		t.Fatalf("registry not reconciled: %+v", reg.Sandboxes)
+	}
+}
+
+func TestDoneAllGlobal(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	first, _ := newGitSandbox(t, "a")
+	second, _ := newGitSandbox(t, "b")
+
+	count, err := forEachManager(&rootOptions{}, true, func(mgr *manager) (int, error) {
+		return doneAll(mgr, doneOptions{force: true, deleteBranch: true})
+	})
+	if err != nil {
+		t.Fatalf("forEachManager: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("count = %d, want 2", count)
+	}
+	for _, m := range []*manager{first, second} {
+		metas, err := m.listSandboxes()
+		if err != nil || len(metas) != 0 {
+			t.Fatalf("%s still has sandboxes %+v (%v)", m.repoRoot, metas, err)
+		}
+	}
+}
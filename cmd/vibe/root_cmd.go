@@ -10,13 +10,28 @@ func newRootCmd() *cobra.Command {
 		Short:        "Manage opencode worktree sandboxes",
 		SilenceUsage: true,
 		Long:         "vibe creates git-worktree + docker sandboxes and runs opencode.",
+		Version:      version,
 	}
 	root.PersistentFlags().StringVar(&rootOpts.sandboxRoot, "sandbox-root", "", "sandbox root path (default: <repo>/.opencode-sandboxes)")
 
 	root.AddCommand(newGoCmd(&rootOpts))
 	root.AddCommand(newDoneCmd(&rootOpts))
 	root.AddCommand(newListCmd(&rootOpts))
+	root.AddCommand(newStatusCmd(&rootOpts))
+	root.AddCommand(newHistoryCmd(&rootOpts))
+	root.AddCommand(newEventsCmd(&rootOpts))
 	root.AddCommand(newPRCmd(&rootOpts))
+	root.AddCommand(newSyncCmd(&rootOpts))
+	root.AddCommand(newTidyCmd(&rootOpts))
+	root.AddCommand(newExportCmd(&rootOpts))
+	root.AddCommand(newImportCmd(&rootOpts))
+	root.AddCommand(newCheckpointsCmd(&rootOpts))
+	root.AddCommand(newRestoreCmd(&rootOpts))
+	root.AddCommand(newTrashCmd(&rootOpts))
+	root.AddCommand(newGCCmd(&rootOpts))
+	root.AddCommand(newDoctorCmd(&rootOpts))
+	root.AddCommand(newPruneCmd(&rootOpts))
+	root.AddCommand(newMigrateCmd(&rootOpts))
 
 	// Compatibility subcommands.
 	root.AddCommand(newCreateCmd(&rootOpts))
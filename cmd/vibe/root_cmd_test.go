@@ -13,7 +13,7 @@ func TestNewRootCmd(t *testing.T) {
 		t.Fatal("missing --sandbox-root persistent flag")
 	}
 
-	expected := []string{"go", "done", "list", "pr", "create", "run", "destroy"}
+	expected := []string{"go", "done", "list", "status", "history", "events", "pr", "sync", "tidy", "export", "import", "checkpoints", "restore", "trash", "gc", "doctor", "prune", "migrate", "create", "run", "destroy"}
 	for _, name := range expected {
 		if root.CommandPath() == "" {
 			t.Fatal("unexpected empty command path")
@@ -2,19 +2,23 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/tailscale/hujson"
 )
 
-func resolveRuntimeSpec(worktree, explicitImage, devcontainerPath string, strictDevcontainer bool) (*runtimeSpec, error) {
+func resolveRuntimeSpec(worktree, explicitImage, devcontainerPath string, strictDevcontainer bool, labels map[string]string) (*runtimeSpec, error) {
 	spec := &runtimeSpec{
 		Image:        defaultImage,
 		ContainerEnv: map[string]string{},
+		Labels:       labels,
 	}
 	if explicitImage != "" {
 		spec.Image = explicitImage
@@ -68,7 +72,7 @@ func resolveRuntimeSpec(worktree, explicitImage, devcontainerPath string, strict
 		return spec, nil
 	}
 
-	image, err := buildDevcontainerImage(dcPath, build)
+	image, err := buildDevcontainerImage(dcPath, build, labels)
 	if err != nil {
 		return nil, err
 	}
@@ -135,7 +139,7 @@ func parseDevcontainerBuild(cfg *devcontainerConfig) (devcontainerBuild, bool, e
 	return build, true, nil
 }
 
-func buildDevcontainerImage(devcontainerPath string, build devcontainerBuild) (string, error) {
+func buildDevcontainerImage(devcontainerPath string, build devcontainerBuild, labels map[string]string) (string, error) {
 	baseDir := filepath.Dir(devcontainerPath)
 	dockerfile := build.Dockerfile
 	if dockerfile == "" {
@@ -162,6 +166,7 @@ func buildDevcontainerImage(devcontainerPath string, build devcontainerBuild) (s
 
 	tag := "vibe-devcontainer:" + shortHash(devcontainerPath+"|"+dockerfile+"|"+contextPath)
 	args := []string{"build", "-t", tag, "-f", dockerfile}
+	args = append(args, labelArgs(labels)...)
 	if len(build.Args) > 0 {
 		keys := make([]string, 0, len(build.Args))
 		for k := range build.Args {
@@ -181,6 +186,87 @@ func buildDevcontainerImage(devcontainerPath string, build devcontainerBuild) (s
 }
 
 func runOpenCodeContainer(meta *sandboxMeta, runtime *runtimeSpec, command string) error {
+	dockerArgs := dockerRunArgs(meta, runtime, meta.Container, true, command)
+	if err := interactiveCommandFn("docker", dockerArgs...); err != nil {
+		return fmt.Errorf("docker run: %w", err)
+	}
+	return nil
+}
+
+func (m *manager) launchSandbox(meta *sandboxMeta, runtime *runtimeSpec, command string, interval time.Duration) error {
+	saved, err := m.updateSandbox(meta.Name, func(meta *sandboxMeta) {
+		meta.Runtime = redactRuntime(runtime)
+		meta.Command = command
+		meta.LastRunAt = time.Now().UTC().Format(time.RFC3339)
+		meta.ExitCode = nil
+	})
+	if err != nil {
+		return fmt.Errorf("save metadata: %w", err)
+	}
+	*meta = *saved
+	mounts, env := runAudit(dockerRunArgs(meta, runtime, meta.Container, true, command))
+	m.recordEvent(sandboxEvent{Type: eventRun, Sandbox: meta.Name, Image: runtime.Image, Command: command, Mounts: mounts, Env: env})
+	runErr := m.runWithCheckpoints(meta, interval, func() error { return runOpenCodeContainer(meta, runtime, command) })
+	code := exitCode(runErr)
+	m.recordEvent(sandboxEvent{Type: eventExit, Sandbox: meta.Name, ExitCode: &code})
+	saved, err = m.updateSandbox(meta.Name, func(meta *sandboxMeta) {
+		meta.ExitCode = &code
+		touchSandbox(meta)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: record exit code: %v\n", err)
+	} else {
+		*meta = *saved
+	}
+	return runErr
+}
+
+const redactedValue = "<redacted>"
+
+// redactRuntime returns a copy of runtime that is safe to persist: container
+// environment values, which can carry prompts and signing keys, are replaced
+// and only the variable names are kept.
+func redactRuntime(runtime *runtimeSpec) *runtimeSpec {
+	if runtime == nil {
+		return nil
+	}
+	redacted := *runtime
+	if runtime.ContainerEnv != nil {
+		redacted.ContainerEnv = make(map[string]string, len(runtime.ContainerEnv))
+		for k := range runtime.ContainerEnv {
+			redacted.ContainerEnv[k] = redactedValue
+		}
+	}
+	redacted.RunArgs = nil
+	for i := 0; i < len(runtime.RunArgs); i++ {
+		arg := runtime.RunArgs[i]
+		switch {
+		case (arg == "-e" || arg == "--env") && i+1 < len(runtime.RunArgs):
+			name, _, _ := strings.Cut(runtime.RunArgs[i+1], "=")
+			redacted.RunArgs = append(redacted.RunArgs, arg, name+"="+redactedValue)
+			i++
+		case strings.HasPrefix(arg, "--env="):
+			name, _, _ := strings.Cut(strings.TrimPrefix(arg, "--env="), "=")
+			redacted.RunArgs = append(redacted.RunArgs, "--env="+name+"="+redactedValue)
+		default:
+			redacted.RunArgs = append(redacted.RunArgs, arg)
+		}
+	}
+	return &redacted
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+func dockerRunArgs(meta *sandboxMeta, runtime *runtimeSpec, container string, interactive bool, command string) []string {
 	if runtime == nil {
 		runtime = &runtimeSpec{Image: defaultImage}
 	}
@@ -193,7 +279,12 @@ func runOpenCodeContainer(meta *sandboxMeta, runtime *runtimeSpec, command strin
 		workspaceFolder = expandWorkspaceVariables(runtime.WorkspaceFolder, meta.Worktree)
 	}
 
-	dockerArgs := []string{"run", "--rm", "-it", "--name", meta.Container}
+	dockerArgs := []string{"run", "--rm"}
+	if interactive {
+		dockerArgs = append(dockerArgs, "-it")
+	}
+	dockerArgs = append(dockerArgs, "--name", container)
+	dockerArgs = append(dockerArgs, labelArgs(runtime.Labels)...)
 	if runtime.WorkspaceMount != "" {
 		dockerArgs = append(dockerArgs, "--mount", expandWorkspaceVariables(runtime.WorkspaceMount, meta.Worktree))
 	} else {
@@ -217,12 +308,7 @@ func runOpenCodeContainer(meta *sandboxMeta, runtime *runtimeSpec, command strin
 		dockerArgs = append(dockerArgs, runtime.RunArgs...)
 	}
 
-	dockerArgs = append(dockerArgs, runtime.Image, "bash", "-lc", command)
-
-	if err := interactiveCommandFn("docker", dockerArgs...); err != nil {
-		return fmt.Errorf("docker run: %w", err)
-	}
-	return nil
+	return append(dockerArgs, runtime.Image, "bash", "-lc", command)
 }
 
 func defaultMounts() []string {
@@ -258,18 +344,19 @@ func defaultMounts() []string {
 	return args
 }
 
+var passthroughEnvKeys = []string{
+	"OPENAI_API_KEY",
+	"OPENAI_BASE_URL",
+	"OPENAI_ORG_ID",
+	"OPENAI_PROJECT",
+	"GITHUB_TOKEN",
+	"GH_TOKEN",
+	"ANTHROPIC_API_KEY",
+}
+
 func passthroughEnvs() []string {
-	keys := []string{
-		"OPENAI_API_KEY",
-		"OPENAI_BASE_URL",
-		"OPENAI_ORG_ID",
-		"OPENAI_PROJECT",
-		"GITHUB_TOKEN",
-		"GH_TOKEN",
-		"ANTHROPIC_API_KEY",
-	}
-	args := make([]string, 0, len(keys)*2)
-	for _, key := range keys {
+	args := make([]string, 0, len(passthroughEnvKeys)*2)
+	for _, key := range passthroughEnvKeys {
 		value, ok := os.LookupEnv(key)
 		if !ok || value == "" {
 			continue
@@ -285,19 +372,3 @@ func expandWorkspaceVariables(value, worktree string) string {
 	result = strings.ReplaceAll(result, "${localWorkspaceFolderBasename}", filepath.Base(worktree))
 	return result
 }
-
-func runningContainers() map[string]bool {
-	result := map[string]bool{}
-	out, err := commandOutputFn("", "docker", "ps", "--format", "{{.Names}}")
-	if err != nil {
-		return result
-	}
-	for _, line := range strings.Split(out, "\n") {
-		name := strings.TrimSpace(line)
-		if name == "" {
-			continue
-		}
-		result[name] = true
-	}
-	return result
-}
@@ -81,7 +81,7 @@ func TestParseDevcontainerBuild(t *testing.T) {
 
 func TestResolveRuntimeSpecMissingDevcontainer(t *testing.T) {
 	worktree := t.TempDir()
-	spec, err := resolveRuntimeSpec(worktree, "", "", false)
+	spec, err := resolveRuntimeSpec(worktree, "", "", false, nil)
 	if err != nil {
 		t.Fatalf("resolveRuntimeSpec returned error: %v", err)
 	}
@@ -92,7 +92,7 @@ func TestResolveRuntimeSpecMissingDevcontainer(t *testing.T) {
 
 func TestResolveRuntimeSpecMissingDevcontainerStrict(t *testing.T) {
 	worktree := t.TempDir()
-	_, err := resolveRuntimeSpec(worktree, "", "missing.json", true)
+	_, err := resolveRuntimeSpec(worktree, "", "missing.json", true, nil)
 	if err == nil || !strings.Contains(err.Error(), "devcontainer config not found") {
 		t.Fatalf("expected strict missing error, got %v", err)
 	}
@@ -118,7 +118,7 @@ func TestResolveRuntimeSpecExplicitImageStillAppliesDevcontainerFields(t *testin
 		t.Fatalf("write devcontainer: %v", err)
 	}
 
-	spec, err := resolveRuntimeSpec(worktree, "explicit-image:latest", "", true)
+	spec, err := resolveRuntimeSpec(worktree, "explicit-image:latest", "", true, nil)
 	if err != nil {
 		t.Fatalf("resolveRuntimeSpec returned error: %v", err)
 	}
@@ -153,7 +153,7 @@ func TestResolveRuntimeSpecUsesConfigImage(t *testing.T) {
 		t.Fatalf("write devcontainer: %v", err)
 	}
 
-	spec, err := resolveRuntimeSpec(worktree, "", "", true)
+	spec, err := resolveRuntimeSpec(worktree, "", "", true, nil)
 	if err != nil {
 		t.Fatalf("resolveRuntimeSpec returned error: %v", err)
 	}
@@ -192,7 +192,7 @@ func TestResolveRuntimeSpecBuildsImage(t *testing.T) {
 		return nil
 	}
 
-	spec, err := resolveRuntimeSpec(worktree, "", "", true)
+	spec, err := resolveRuntimeSpec(worktree, "", "", true, map[string]string{labelVersion: "1.2.3"})
 	if err != nil {
 		t.Fatalf("resolveRuntimeSpec returned error: %v", err)
 	}
@@ -208,6 +208,9 @@ func TestResolveRuntimeSpecBuildsImage(t *testing.T) {
 	if !runtimeHasPair(gotArgs, "-f", dockerfilePath) {
 		t.Fatalf("docker args missing dockerfile path: %+v", gotArgs)
 	}
+	if !runtimeHasPair(gotArgs, "--label", "vibe.version=1.2.3") || spec.Labels[labelVersion] != "1.2.3" {
+		t.Fatalf("image and container should carry labels: %+v %+v", gotArgs, spec.Labels)
+	}
 	if !runtimeHasPair(gotArgs, "--build-arg", "A=1") || !runtimeHasPair(gotArgs, "--build-arg", "B=2") {
 		t.Fatalf("docker args missing build args: %+v", gotArgs)
 	}
@@ -218,7 +221,7 @@ func TestResolveRuntimeSpecBuildsImage(t *testing.T) {
 
 func TestBuildDevcontainerImageValidation(t *testing.T) {
 	dcPath := filepath.Join(t.TempDir(), "devcontainer.json")
-	if _, err := buildDevcontainerImage(dcPath, devcontainerBuild{Dockerfile: "missing", Context: "."}); err == nil || !strings.Contains(err.Error(), "dockerfile not found") {
+	if _, err := buildDevcontainerImage(dcPath, devcontainerBuild{Dockerfile: "missing", Context: "."}, nil); err == nil || !strings.Contains(err.Error(), "dockerfile not found") {
 		t.Fatalf("expected missing dockerfile error, got %v", err)
 	}
 
@@ -232,7 +235,7 @@ func TestBuildDevcontainerImageValidation(t *testing.T) {
 	if err := os.WriteFile(ctxFile, []byte("x"), 0o644); err != nil {
 		t.Fatalf("write context file: %v", err)
 	}
-	if _, err := buildDevcontainerImage(dcPath, devcontainerBuild{Dockerfile: "Dockerfile", Context: "ctx.txt"}); err == nil || !strings.Contains(err.Error(), "context is not a directory") {
+	if _, err := buildDevcontainerImage(dcPath, devcontainerBuild{Dockerfile: "Dockerfile", Context: "ctx.txt"}, nil); err == nil || !strings.Contains(err.Error(), "context is not a directory") {
 		t.Fatalf("expected invalid context error, got %v", err)
 	}
 }
@@ -361,6 +364,7 @@ func TestRunOpenCodeContainerBuildsDockerArgs(t *testing.T) {
 		Mounts:          []string{"type=bind,source=${localWorkspaceFolder},target=/src"},
 		WorkspaceMount:  "type=bind,source=${localWorkspaceFolder},target=/workspace",
 		WorkspaceFolder: "/workspace/${localWorkspaceFolderBasename}",
+		Labels:          map[string]string{labelSandbox: "feat", labelRepo: "/repo"},
 	}
 
 	var gotName string
@@ -389,6 +393,9 @@ func TestRunOpenCodeContainerBuildsDockerArgs(t *testing.T) {
 	if !runtimeHasPair(gotArgs, "--mount", "type=bind,source="+meta.Worktree+",target=/src") {
 		t.Fatalf("missing extra mount: %+v", gotArgs)
 	}
+	if !runtimeHasSequence(gotArgs, []string{"--label", "vibe.repo=/repo", "--label", "vibe.sandbox=feat"}) {
+		t.Fatalf("missing sorted labels: %+v", gotArgs)
+	}
 	if !runtimeHasPair(gotArgs, "--user", "1000:1000") {
 		t.Fatalf("missing remote user: %+v", gotArgs)
 	}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	secretAllowMarker    = "vibe:allow-secret"
+	minEnvSecretLength   = 8
+	minEntropyLength     = 24
+	minEntropyBitsPerChr = 4.3
+)
+
+type secretRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+var secretRules = []secretRule{
+	{Name: "private key", Pattern: regexp.MustCompile(`-----BEGIN ((RSA|EC|DSA|OPENSSH|PGP) )?PRIVATE KEY( BLOCK)?-----`)},
+	{Name: "AWS access key", Pattern: regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{Name: "GitHub token", Pattern: regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{Name: "GitLab token", Pattern: regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`)},
+	{Name: "Anthropic API key", Pattern: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`)},
+	{Name: "OpenAI API key", Pattern: regexp.MustCompile(`\bsk-(proj-)?[A-Za-z0-9_-]{20,}`)},
+	{Name: "Slack token", Pattern: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{Name: "Google API key", Pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+}
+
+var (
+	entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/_-]{24,}={0,2}`)
+	hunkHeader       = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+)
+
+// Lockfiles are full of checksums that look like high-entropy secrets, so
+// only the check for passed-through env values runs on them.
+var secretScanSkippedFiles = []string{"go.sum", "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "Cargo.lock", "poetry.lock", "*.lock"}
+
+type secretFinding struct {
+	File  string
+	Line  int
+	Rule  string
+	Match string
+}
+
+func (f secretFinding) String() string {
+	return fmt.Sprintf("%s:%d: %s (%s)", f.File, f.Line, f.Rule, redactSecret(f.Match))
+}
+
+func redactSecret(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", 8)
+}
+
+func shannonEntropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	var entropy float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func looksRandom(s string) bool {
+	var upper, lower, digit bool
+	for _, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+	}
+	return upper && lower && digit && shannonEntropy(s) >= minEntropyBitsPerChr
+}
+
+type secretScanner struct {
+	envValues map[string]string
+	paths     []*regexp.Regexp
+	patterns  []*regexp.Regexp
+}
+
+func newSecretScanner(cfg *vibeConfig) (*secretScanner, error) {
+	s := &secretScanner{envValues: map[string]string{}}
+	for _, key := range passthroughEnvKeys {
+		if value := os.Getenv(key); len(value) >= minEnvSecretLength {
+			s.envValues[value] = key
+		}
+	}
+	if cfg == nil {
+		return s, nil
+	}
+	compile := func(exprs []string) ([]*regexp.Regexp, error) {
+		var out []*regexp.Regexp
+		for _, expr := range exprs {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid secrets allowlist pattern %q: %w", expr, err)
+			}
+			out = append(out, re)
+		}
+		return out, nil
+	}
+	var err error
+	if s.paths, err = compile(cfg.Secrets.Allowlist.Paths); err != nil {
+		return nil, err
+	}
+	if s.patterns, err = compile(cfg.Secrets.Allowlist.Patterns); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *secretScanner) skipFile(file string) bool {
+	for _, pattern := range secretScanSkippedFiles {
+		if ok, _ := path.Match(pattern, path.Base(file)); ok {
+			return true
+		}
+	}
+	for _, re := range s.paths {
+		if re.MatchString(file) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *secretScanner) allowed(match string) bool {
+	for _, re := range s.patterns {
+		if re.MatchString(match) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanLine reports secrets on an added line. The values of passed-through
+// env vars are always reported; skipped files, allowlists and the allow
+// marker only silence the pattern and entropy heuristics.
+func (s *secretScanner) scanLine(file string, line int, text string, heuristics bool) []secretFinding {
+	var findings []secretFinding
+	seen := map[string]bool{}
+	for value, key := range s.envValues {
+		if strings.Contains(text, value) {
+			seen[value] = true
+			findings = append(findings, secretFinding{File: file, Line: line, Rule: "value of $" + key, Match: value})
+		}
+	}
+	if !heuristics || strings.Contains(text, secretAllowMarker) {
+		return findings
+	}
+	add := func(rule, match string) {
+		if seen[match] || s.allowed(match) {
+			return
+		}
+		seen[match] = true
+		findings = append(findings, secretFinding{File: file, Line: line, Rule: rule, Match: match})
+	}
+	for _, rule := range secretRules {
+		for _, match := range rule.Pattern.FindAllString(text, -1) {
+			add(rule.Name, match)
+		}
+	}
+	for _, match := range entropyCandidate.FindAllString(text, -1) {
+		if len(match) >= minEntropyLength && looksRandom(match) && !overlapsFinding(findings, match) {
+			add("high-entropy string", match)
+		}
+	}
+	return findings
+}
+
+func overlapsFinding(findings []secretFinding, match string) bool {
+	for _, f := range findings {
+		if strings.Contains(match, f.Match) || strings.Contains(f.Match, match) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *secretScanner) scanDiff(diff string) []secretFinding {
+	var (
+		findings   []secretFinding
+		file       string
+		line       int
+		header     bool
+		heuristics bool
+	)
+	for _, text := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(text, "diff --git "):
+			header = true
+			file = ""
+		case header && strings.HasPrefix(text, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(text, "+++ "), "b/")
+			if file == "/dev/null" {
+				file = ""
+			}
+			heuristics = !s.skipFile(file)
+		case strings.HasPrefix(text, "@@"):
+			header = false
+			if m := hunkHeader.FindStringSubmatch(text); m != nil {
+				line, _ = strconv.Atoi(m[1])
+			}
+		case header:
+		case strings.HasPrefix(text, "+"):
+			if file != "" {
+				findings = append(findings, s.scanLine(file, line, text[1:], heuristics)...)
+			}
+			line++
+		case strings.HasPrefix(text, " "):
+			line++
+		}
+	}
+	return findings
+}
+
+func (m *manager) scanSandboxSecrets(meta *sandboxMeta, base string) ([]secretFinding, error) {
+	scanner, err := newSecretScanner(m.config)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := gitOutputFn(meta.Worktree, "diff", "--no-color", "--no-ext-diff", "--unified=0", base+"..."+meta.Branch)
+	if err != nil {
+		return nil, fmt.Errorf("diff against %s: %w", base, err)
+	}
+	return scanner.scanDiff(diff), nil
+}
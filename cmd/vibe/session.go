@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	sessionMountPath   = "/vibe/session"
+	sessionTestResults = "test-results.txt"
+)
+
+func (m *manager) sessionDir(name string) string {
+	return filepath.Join(m.sandboxRoot, "sessions", name)
+}
+
+func (m *manager) attachSession(meta *sandboxMeta, runtime *runtimeSpec) error {
+	dir := m.sessionDir(meta.Name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create session dir: %w", err)
+	}
+	runtime.Mounts = append(runtime.Mounts, fmt.Sprintf("type=bind,source=%s,target=%s", dir, sessionMountPath))
+	if runtime.ContainerEnv == nil {
+		runtime.ContainerEnv = map[string]string{}
+	}
+	runtime.ContainerEnv["VIBE_SANDBOX"] = meta.Name
+	runtime.ContainerEnv["VIBE_SESSION_DIR"] = sessionMountPath
+	runtime.ContainerEnv["VIBE_TEST_RESULTS"] = sessionMountPath + "/" + sessionTestResults
+	if meta.Prompt != "" {
+		runtime.ContainerEnv["VIBE_PROMPT"] = meta.Prompt
+	}
+	return m.applyCommitPolicy(meta, runtime)
+}
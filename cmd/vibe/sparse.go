@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+func setupSparseCheckout(worktree string, paths []string) error {
+	args := append([]string{"sparse-checkout", "set", "--cone"}, paths...)
+	if err := runCommandFn(worktree, os.Stdout, os.Stderr, "git", args...); err != nil {
+		return fmt.Errorf("configure sparse checkout: %w", err)
+	}
+	if err := runCommandFn(worktree, os.Stdout, os.Stderr, "git", "checkout"); err != nil {
+		return fmt.Errorf("populate sparse worktree: %w", err)
+	}
+	return nil
+}
+
+func resolveSparsePaths(cfg *vibeConfig, paths []string, preset string) ([]string, error) {
+	var combined []string
+	if preset != "" {
+		presetPaths, ok := cfg.sparsePreset(preset)
+		if !ok {
+			return nil, fmt.Errorf("unknown sparse preset %q", preset)
+		}
+		combined = append(combined, presetPaths...)
+	}
+	combined = append(combined, paths...)
+
+	seen := map[string]bool{}
+	result := make([]string, 0, len(combined))
+	for _, p := range combined {
+		clean := path.Clean(strings.TrimSpace(strings.ReplaceAll(p, "\\", "/")))
+		if clean == "." || clean == "" {
+			continue
+		}
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("sparse path must be relative to the repository: %s", p)
+		}
+		if seen[clean] {
+			continue
+		}
+		seen[clean] = true
+		result = append(result, clean)
+	}
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveSparsePaths(t *testing.T) {
+	cfg := &vibeConfig{Sparse: sparseConfig{Presets: map[string][]string{"web": {"apps/web/", "libs/ui"}}}}
+
+	got, err := resolveSparsePaths(cfg, []string{"./libs/ui", "tools"}, "web")
+	if err != nil {
+		t.Fatalf("resolveSparsePaths returned error: %v", err)
+	}
+	want := []string{"apps/web", "libs/ui", "tools"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("resolveSparsePaths = %v, want %v", got, want)
+	}
+
+	if _, err := resolveSparsePaths(cfg, nil, "missing"); err == nil || !strings.Contains(err.Error(), "unknown sparse preset") {
+		t.Fatalf("expected unknown preset error, got %v", err)
+	}
+	if _, err := resolveSparsePaths(nil, []string{"../outside"}, ""); err == nil {
+		t.Fatal("expected error for path outside repository")
+	}
+	if got, err := resolveSparsePaths(nil, nil, ""); err != nil || len(got) != 0 {
+		t.Fatalf("empty input = %v, %v; want none", got, err)
+	}
+}
+
+func TestCreateSandboxSparse(t *testing.T) {
+	m := newGitManager(t)
+	for _, dir := range []string{"apps/web", "apps/api"} {
+		if err := os.MkdirAll(filepath.Join(m.repoRoot, dir), 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+		commitFile(t, m.repoRoot, filepath.Join(dir, "main.go"), "package main\n", "add "+dir)
+	}
+
+	meta, err := m.createSandbox("sparse", "main", "", sandboxOptions{Sparse: []string{"apps/web"}})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	if !reflect.DeepEqual(meta.Sparse, []string{"apps/web"}) {
+		t.Fatalf("meta.Sparse = %v", meta.Sparse)
+	}
+	if _, err := os.Stat(filepath.Join(meta.Worktree, "apps/web/main.go")); err != nil {
+		t.Fatalf("sparse path should be checked out: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(meta.Worktree, "apps/api/main.go")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("path outside sparse cone should be absent, stat err=%v", err)
+	}
+
+	commitFile(t, meta.Worktree, "apps/web/feature.go", "package main\n", "feature")
+	files, err := gitOutput(m.repoRoot, "ls-tree", "-r", "--name-only", meta.Branch)
+	if err != nil || !strings.Contains(files, "apps/api/main.go") || !strings.Contains(files, "apps/web/feature.go") {
+		t.Fatalf("sparse commit should keep full tree, files=%q err=%v", files, err)
+	}
+	if _, err := os.Stat(filepath.Join(m.repoRoot, "apps/api/main.go")); err != nil {
+		t.Fatalf("main worktree must stay fully checked out: %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+func (m *manager) resolveStackBase(parentName, base string) (string, error) {
+	if base != "" {
+		return "", errors.New("--on cannot be used with --base")
+	}
+	parent, err := m.loadSandbox(normalizeName(parentName))
+	if err != nil {
+		return "", fmt.Errorf("resolve parent sandbox: %w", err)
+	}
+	return parent.Branch, nil
+}
+
+func (m *manager) stackAncestors(meta *sandboxMeta) ([]*sandboxMeta, error) {
+	var chain []*sandboxMeta
+	seen := map[string]bool{meta.Name: true}
+	for name := meta.Parent; name != ""; {
+		if seen[name] {
+			return nil, fmt.Errorf("sandbox stack of %q has a cycle at %q", meta.Name, name)
+		}
+		seen[name] = true
+		parent, err := m.loadSandbox(name)
+		if err != nil {
+			return nil, err
+		}
+		chain = append([]*sandboxMeta{parent}, chain...)
+		name = parent.Parent
+	}
+	return chain, nil
+}
+
+func (m *manager) createStackPRs(meta *sandboxMeta, opts prOptions) error {
+	ancestors, err := m.stackAncestors(meta)
+	if err != nil {
+		return err
+	}
+	for _, parent := range ancestors {
+		if parent.PRNumber != 0 {
+			continue
+		}
+		parentOpts := prOptions{name: parent.Name, draft: opts.draft, remote: opts.remote, pushRemote: opts.pushRemote, skipChecks: opts.skipChecks}
+		if err := m.createPR(parent, parentOpts); err != nil {
+			return fmt.Errorf("open pr for parent %s: %w", parent.Name, err)
+		}
+	}
+	return m.createPR(meta, opts)
+}
+
+func (m *manager) stackChildren(name string) ([]sandboxMeta, error) {
+	metas, err := m.listSandboxes()
+	if err != nil {
+		return nil, err
+	}
+	var children []sandboxMeta
+	for _, meta := range metas {
+		if meta.Parent == name {
+			children = append(children, meta)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+	return children, nil
+}
+
+// reparentChildren moves the children of a destroyed sandbox onto its own
+// base. ParentHead is kept so the next sync can replay only their commits.
+func (m *manager) reparentChildren(meta *sandboxMeta) error {
+	children, err := m.stackChildren(meta.Name)
+	if err != nil {
+		return err
+	}
+	for i := range children {
+		child, err := m.updateSandbox(children[i].Name, func(child *sandboxMeta) {
+			child.Parent = meta.Parent
+			child.BaseRef = meta.BaseRef
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("restacked %s onto %s; run `vibe sync --name %s` to rebase\n", child.Name, child.BaseRef, child.Name)
+	}
+	return nil
+}
+
+// syncSandbox rebases a sandbox onto the current head of its parent. The
+// sandbox lock is held throughout, so no other command sees the worktree in
+// the middle of the rebase.
+func (m *manager) syncSandbox(meta *sandboxMeta) (bool, error) {
+	unlock, err := m.lockSandbox(meta.Name)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	if !m.sandboxExists(meta.Name) {
+		return false, fmt.Errorf("sandbox %q was removed", meta.Name)
+	}
+	current, err := m.loadSandbox(meta.Name)
+	if err != nil {
+		return false, err
+	}
+	*meta = *current
+	if meta.ParentHead == "" {
+		return false, nil
+	}
+	target := meta.BaseRef
+	if meta.Parent != "" {
+		parent, err := m.loadSandbox(meta.Parent)
+		if err != nil {
+			return false, err
+		}
+		target = parent.Branch
+	}
+	head, err := gitOutputFn(m.repoRoot, "rev-parse", target)
+	if err != nil {
+		return false, fmt.Errorf("resolve %s: %w", target, err)
+	}
+	if head == meta.ParentHead {
+		return false, nil
+	}
+	if _, err := os.Stat(meta.Worktree); err != nil {
+		return false, fmt.Errorf("worktree missing: %s", meta.Worktree)
+	}
+
+	if err := runCommandFn(meta.Worktree, os.Stdout, os.Stderr, "git", "rebase", "--onto", head, meta.ParentHead, meta.Branch); err != nil {
+		_ = runCommandFn(meta.Worktree, io.Discard, io.Discard, "git", "rebase", "--abort")
+		return false, fmt.Errorf("rebase %s onto %s: %w", meta.Branch, target, err)
+	}
+	meta.ParentHead = head
+	if meta.Parent == "" {
+		meta.ParentHead = ""
+	}
+	if err := m.saveSandbox(meta); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+func (m *manager) syncStacks(name string) (int, error) {
+	metas, err := m.listSandboxes()
+	if err != nil {
+		return 0, err
+	}
+	depth := map[string]int{}
+	byName := map[string]sandboxMeta{}
+	for _, meta := range metas {
+		byName[meta.Name] = meta
+	}
+	var depthOf func(name string, seen int) int
+	depthOf = func(name string, seen int) int {
+		if d, ok := depth[name]; ok {
+			return d
+		}
+		meta, ok := byName[name]
+		if !ok || meta.Parent == "" || seen > len(byName) {
+			return 0
+		}
+		d := depthOf(meta.Parent, seen+1) + 1
+		depth[name] = d
+		return d
+	}
+	sort.Slice(metas, func(i, j int) bool {
+		di, dj := depthOf(metas[i].Name, 0), depthOf(metas[j].Name, 0)
+		if di != dj {
+			return di < dj
+		}
+		return metas[i].Name < metas[j].Name
+	})
+
+	selected := map[string]bool{}
+	if name != "" {
+		if _, ok := byName[name]; !ok {
+			return 0, fmt.Errorf("sandbox %q not found", name)
+		}
+		selected[name] = true
+	}
+
+	var (
+		count    int
+		failures []string
+	)
+	for i := range metas {
+		meta := metas[i]
+		if name != "" && !selected[meta.Name] && !selected[meta.Parent] {
+			continue
+		}
+		selected[meta.Name] = true
+		rebased, err := m.syncSandbox(&meta)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", meta.Name, err))
+			continue
+		}
+		if rebased {
+			count++
+			fmt.Printf("rebased %s onto %s\n", meta.Name, meta.BaseRef)
+		}
+	}
+	if len(failures) > 0 {
+		return count, fmt.Errorf("failed to sync some sandboxes:\n%s", strings.Join(failures, "\n"))
+	}
+	return count, nil
+}
+
+func printSandboxTree(w io.Writer, metas []sandboxMeta) {
+	byName := map[string]bool{}
+	children := map[string][]sandboxMeta{}
+	for _, meta := range metas {
+		byName[meta.Name] = true
+	}
+	var roots []sandboxMeta
+	for _, meta := range metas {
+		if meta.Parent != "" && byName[meta.Parent] {
+			children[meta.Parent] = append(children[meta.Parent], meta)
+			continue
+		}
+		roots = append(roots, meta)
+	}
+
+	var walk func(meta sandboxMeta, prefix, branch string, depth int)
+	walk = func(meta sandboxMeta, prefix, branch string, depth int) {
+		line := fmt.Sprintf("%s (%s)", meta.Name, meta.Branch)
+		if depth == 0 {
+			line = fmt.Sprintf("%s [%s]", line, meta.BaseRef)
+		}
+		if meta.PRNumber != 0 {
+			line = fmt.Sprintf("%s #%d", line, meta.PRNumber)
+		}
+		fmt.Fprintln(w, prefix+branch+line)
+		if depth > len(metas) {
+			return
+		}
+		kids := children[meta.Name]
+		next := prefix
+		switch branch {
+		case "├─ ":
+			next += "│  "
+		case "└─ ":
+			next += "   "
+		}
+		for i, kid := range kids {
+			b := "├─ "
+			if i == len(kids)-1 {
+				b = "└─ "
+			}
+			walk(kid, next, b, depth+1)
+		}
+	}
+	for _, root := range roots {
+		walk(root, "", "", 0)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStackedSandboxSync(t *testing.T) {
+	m, parent := newGitSandbox(t, "step-1")
+	commitFile(t, parent.Worktree, "a.txt", "a\n", "step 1")
+
+	child, err := m.createSandbox("step-2", parent.Branch, "", sandboxOptions{Parent: parent.Name})
+	if err != nil {
+		t.Fatalf("createSandbox child: %v", err)
+	}
+	if child.Parent != "step-1" || child.BaseRef != parent.Branch || child.ParentHead == "" {
+		t.Fatalf("child meta = %+v", child)
+	}
+	commitFile(t, child.Worktree, "b.txt", "b\n", "step 2")
+
+	if n, err := m.syncStacks(""); err != nil || n != 0 {
+		t.Fatalf("sync without parent changes = %d, %v", n, err)
+	}
+
+	commitFile(t, parent.Worktree, "a.txt", "a2\n", "step 1 review fix")
+	if n, err := m.syncStacks("step-1"); err != nil || n != 1 {
+		t.Fatalf("syncStacks = %d, %v; want 1, nil", n, err)
+	}
+	if b, err := os.ReadFile(filepath.Join(child.Worktree, "a.txt")); err != nil || string(b) != "a2\n" {
+		t.Fatalf("child missing parent fix: %q, %v", b, err)
+	}
+	log, err := gitOutput(m.repoRoot, "log", "--format=%s", parent.Branch+".."+child.Branch)
+	if err != nil || log != "step 2" {
+		t.Fatalf("child commits after sync = %q, %v", log, err)
+	}
+
+	if err := m.destroySandbox(parent, true, true); err != nil {
+		t.Fatalf("destroy parent: %v", err)
+	}
+	restacked, err := m.loadSandbox("step-2")
+	if err != nil {
+		t.Fatalf("loadSandbox child: %v", err)
+	}
+	if restacked.Parent != "" || restacked.BaseRef != "main" || restacked.ParentHead == "" {
+		t.Fatalf("child not restacked onto main: %+v", restacked)
+	}
+	if n, err := m.syncStacks(""); err != nil || n != 1 {
+		t.Fatalf("sync onto main = %d, %v", n, err)
+	}
+	log, err = gitOutput(m.repoRoot, "log", "--format=%s", "main.."+child.Branch)
+	if err != nil || log != "step 2" {
+		t.Fatalf("child commits after restack = %q, %v", log, err)
+	}
+}
+
+func TestSyncSandboxHoldsSandboxLock(t *testing.T) {
+	origRun := runCommandFn
+	t.Cleanup(func() { runCommandFn = origRun })
+
+	m, parent := newGitSandbox(t, "base")
+	child, err := m.createSandbox("top", parent.Branch, "", sandboxOptions{Parent: parent.Name})
+	if err != nil {
+		t.Fatalf("createSandbox child: %v", err)
+	}
+	commitFile(t, parent.Worktree, "a.txt", "a\n", "parent moves on")
+
+	updated := make(chan struct{})
+	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error {
+		if len(args) > 0 && args[0] == "rebase" {
+			go func() {
+				_, _ = m.updateSandbox("top", func(*sandboxMeta) {})
+				close(updated)
+			}()
+			select {
+			case <-updated:
+				t.Error("metadata was updated in the middle of the rebase")
+			case <-time.After(200 * time.Millisecond):
+			}
+		}
+		return origRun(dir, stdout, stderr, name, args...)
+	}
+
+	if rebased, err := m.syncSandbox(child); err != nil || !rebased {
+		t.Fatalf("syncSandbox = %v, %v", rebased, err)
+	}
+	<-updated
+}
+
+func TestStackAncestors(t *testing.T) {
+	m := newTestManager(t)
+	for _, meta := range []*sandboxMeta{
+		{Name: "a", Branch: "codex/a", BaseRef: "main"},
+		{Name: "b", Branch: "codex/b", BaseRef: "codex/a", Parent: "a"},
+		{Name: "c", Branch: "codex/c", BaseRef: "codex/b", Parent: "b"},
+	} {
+		if err := m.saveSandbox(meta); err != nil {
+			t.Fatalf("saveSandbox: %v", err)
+		}
+	}
+	c, _ := m.loadSandbox("c")
+	chain, err := m.stackAncestors(c)
+	if err != nil || len(chain) != 2 || chain[0].Name != "a" || chain[1].Name != "b" {
+		t.Fatalf("stackAncestors = %+v, %v", chain, err)
+	}
+
+	metas, _ := m.listSandboxes()
+	var buf bytes.Buffer
+	printSandboxTree(&buf, metas)
+	want := "a (codex/a) [main]\n└─ b (codex/b)\n   └─ c (codex/c)\n"
+	if buf.String() != want {
+		t.Fatalf("tree =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	a, _ := m.loadSandbox("a")
+	a.Parent = "c"
+	if err := m.saveSandbox(a); err != nil {
+		t.Fatalf("saveSandbox: %v", err)
+	}
+	if _, err := m.stackAncestors(c); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type containerInfo struct {
+	State    string `json:"state"`
+	ExitCode int    `json:"exit_code"`
+	Image    string `json:"image"`
+}
+
+type healthProblem struct {
+	Problem string `json:"problem"`
+	Fix     string `json:"fix"`
+}
+
+type sandboxHealth struct {
+	sandboxStatus
+	BranchExists       bool            `json:"branch_exists"`
+	BaseExists         bool            `json:"base_exists"`
+	WorktreeRegistered bool            `json:"worktree_registered"`
+	Uncommitted        int             `json:"uncommitted"`
+	ContainerInfo      *containerInfo  `json:"container_info,omitempty"`
+	Problems           []healthProblem `json:"problems"`
+}
+
+func (m *manager) inspectSandboxContainer(meta *sandboxMeta) *containerInfo {
+	for _, c := range m.repoContainers(meta.Name) {
+		if c.Name == meta.Container {
+			return inspectContainer(c.Name)
+		}
+	}
+	return nil
+}
+
+func inspectContainer(name string) *containerInfo {
+	out, err := commandOutputFn("", "docker", "inspect", "--format", "{{.State.Status}}\t{{.State.ExitCode}}\t{{.Config.Image}}", name)
+	if err != nil {
+		return nil
+	}
+	fields := strings.Split(strings.TrimSpace(out), "\t")
+	if len(fields) != 3 {
+		return nil
+	}
+	code, _ := strconv.Atoi(fields[1])
+	return &containerInfo{State: fields[0], ExitCode: code, Image: fields[2]}
+}
+
+func registeredWorktrees(repoRoot string) map[string]bool {
+	result := map[string]bool{}
+	out, err := gitOutputFn(repoRoot, "worktree", "list", "--porcelain")
+	if err != nil {
+		return result
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if path, ok := strings.CutPrefix(line, "worktree "); ok {
+			result[canonicalPath(path)] = true
+		}
+	}
+	return result
+}
+
+func canonicalPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
+
+func (m *manager) refExists(ref string) bool {
+	_, err := gitOutputFn(m.repoRoot, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	return err == nil
+}
+
+func (m *manager) sandboxHealth(meta sandboxMeta) sandboxHealth {
+	info := m.inspectSandboxContainer(&meta)
+	states := map[string]string{}
+	if info != nil {
+		states[meta.Container] = info.State
+	}
+	h := sandboxHealth{
+		sandboxStatus:      m.sandboxStatus(meta, states, true),
+		BranchExists:       m.refExists("refs/heads/" + meta.Branch),
+		BaseExists:         meta.BaseRef != "" && m.refExists(meta.BaseRef),
+		WorktreeRegistered: registeredWorktrees(m.repoRoot)[canonicalPath(meta.Worktree)],
+		ContainerInfo:      info,
+		Problems:           []healthProblem{},
+	}
+	if h.WorktreeExists {
+		if out, err := gitOutputFn(meta.Worktree, "status", "--porcelain"); err == nil && out != "" {
+			h.Uncommitted = len(strings.Split(out, "\n"))
+		}
+	}
+
+	problem := func(text, fix string) {
+		h.Problems = append(h.Problems, healthProblem{Problem: text, Fix: fix})
+	}
+	switch {
+	case !h.WorktreeExists && h.WorktreeRegistered:
+		problem("worktree directory is missing but git still tracks it",
+			fmt.Sprintf("run `git worktree prune` and then `vibe done --name %s --force`", meta.Name))
+	case !h.WorktreeExists:
+		problem("worktree directory is missing",
+			fmt.Sprintf("run `vibe done --name %s --force` to drop the sandbox metadata", meta.Name))
+	case !h.WorktreeRegistered:
+		problem("worktree exists but is not listed by `git worktree list`",
+			fmt.Sprintf("run `git worktree repair %s`", meta.Worktree))
+	}
+	if !h.BranchExists {
+		problem(fmt.Sprintf("branch %s does not exist", meta.Branch),
+			fmt.Sprintf("recreate it with `git branch %s %s`, or run `vibe done --name %s --force`", meta.Branch, meta.BaseRef, meta.Name))
+	}
+	if !h.BaseExists {
+		problem(fmt.Sprintf("base ref %q cannot be resolved", meta.BaseRef),
+			"fetch the base branch, or compare against another ref with `git log <ref>..HEAD`")
+	}
+	if meta.Parent != "" {
+		if _, err := m.loadSandbox(meta.Parent); err != nil {
+			problem(fmt.Sprintf("parent sandbox %s no longer exists", meta.Parent),
+				fmt.Sprintf("rebase onto the base with `git -C %s rebase --onto %s %s`", meta.Worktree, meta.BaseRef, meta.ParentHead))
+		}
+	}
+	if info != nil && info.State != containerRunning {
+		problem(fmt.Sprintf("container %s is left over in state %q (exit code %d)", meta.Container, info.State, info.ExitCode),
+			fmt.Sprintf("remove it with `docker rm %s`", meta.Container))
+	}
+	if info == nil && meta.ExitCode != nil && *meta.ExitCode != 0 {
+		problem(fmt.Sprintf("last run exited with code %d", *meta.ExitCode),
+			fmt.Sprintf("restart the agent with `vibe run --name %s`", meta.Name))
+	}
+	return h
+}
+
+func writeHealth(w io.Writer, h sandboxHealth, output string) error {
+	switch output {
+	case outputTable:
+	case outputJSON:
+		b, err := json.MarshalIndent(h, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	default:
+		return fmt.Errorf("unknown output format %q (want json)", output)
+	}
+
+	yesNo := func(ok bool) string {
+		if ok {
+			return "yes"
+		}
+		return "no"
+	}
+	tw := tabwriter.NewWriter(w, 4, 2, 2, ' ', 0)
+	row := func(key, value string) { fmt.Fprintf(tw, "%s:\t%s\n", key, value) }
+	row("name", h.Name)
+	row("created", h.CreatedAt)
+	row("branch", fmt.Sprintf("%s (exists: %s)", h.Branch, yesNo(h.BranchExists)))
+	row("base", fmt.Sprintf("%s (exists: %s)", h.BaseRef, yesNo(h.BaseExists)))
+	if h.Parent != "" {
+		row("parent", h.Parent)
+	}
+	row("worktree", fmt.Sprintf("%s (on disk: %s, registered: %s)", h.Worktree, yesNo(h.WorktreeExists), yesNo(h.WorktreeRegistered)))
+	if h.WorktreeExists {
+		row("uncommitted", strconv.Itoa(h.Uncommitted))
+		row("ahead/behind", fmt.Sprintf("%d/%d", h.Ahead, h.Behind))
+		row("disk", humanBytes(h.DiskUsage))
+	}
+	if h.ContainerInfo != nil {
+		row("container", fmt.Sprintf("%s (%s, exit code %d, image %s)", h.Container, h.ContainerInfo.State, h.ContainerInfo.ExitCode, h.ContainerInfo.Image))
+	} else {
+		row("container", h.Container+" (not found)")
+	}
+	if h.Runtime != nil {
+		row("image", h.Runtime.Image)
+		if len(h.Runtime.RunArgs) > 0 {
+			row("run args", strings.Join(h.Runtime.RunArgs, " "))
+		}
+		if h.Runtime.RemoteUser != "" {
+			row("user", h.Runtime.RemoteUser)
+		}
+		for _, mount := range h.Runtime.Mounts {
+			row("mount", mount)
+		}
+	}
+	if h.Command != "" {
+		row("command", h.Command)
+	}
+	if h.LastRunAt != "" {
+		last := h.LastRunAt
+		if h.ExitCode != nil {
+			last += fmt.Sprintf(" (exit code %d)", *h.ExitCode)
+		} else {
+			last += " (no exit recorded)"
+		}
+		row("last run", last)
+	}
+	if h.ExpiresAt != "" {
+		row("expires", fmt.Sprintf("%s (ttl %s)", h.ExpiresAt, h.TTL))
+	}
+	if h.PRURL != "" {
+		row("pr", h.PRURL)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(h.Problems) == 0 {
+		_, err := fmt.Fprintln(w, "\nno problems found")
+		return err
+	}
+	fmt.Fprintf(w, "\n%d problem(s):\n", len(h.Problems))
+	for _, p := range h.Problems {
+		fmt.Fprintf(w, "  - %s\n    fix: %s\n", p.Problem, p.Fix)
+	}
+	return nil
+}
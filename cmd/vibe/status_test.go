@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSandboxHealth(t *testing.T) {
+	origOutput := commandOutputFn
+	t.Cleanup(func() { commandOutputFn = origOutput })
+	var m *manager
+	commandOutputFn = func(dir, name string, args ...string) (string, error) {
+		if name != "docker" {
+			return "", errors.New("unexpected command")
+		}
+		broken := containerName(m.repoRoot, "broken")
+		switch args[0] {
+		case "ps":
+			if runtimeHasPair(args, "--filter", "label=vibe.sandbox=broken") {
+				return broken + "\tbroken\texited\n", nil
+			}
+			return "", nil
+		case "inspect":
+			if args[len(args)-1] == broken {
+				return "exited\t137\topencode-sandbox:latest\n", nil
+			}
+		}
+		return "", errors.New("no such container")
+	}
+
+	m, healthy := newGitSandbox(t, "healthy")
+	commitFile(t, healthy.Worktree, "a.txt", "a\n", "work")
+	if err := os.WriteFile(healthy.Worktree+"/scratch.txt", []byte("x\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	h := m.sandboxHealth(*healthy)
+	if len(h.Problems) != 0 {
+		t.Fatalf("unexpected problems: %+v", h.Problems)
+	}
+	if !h.BranchExists || !h.BaseExists || !h.WorktreeRegistered || h.Uncommitted != 1 || h.Ahead != 1 {
+		t.Fatalf("unexpected health %+v", h)
+	}
+
+	broken, err := m.createSandbox("broken", "main", "", sandboxOptions{})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	if _, err := commandOutput(m.repoRoot, "git", "worktree", "remove", "--force", broken.Worktree); err != nil {
+		t.Fatalf("remove worktree: %v", err)
+	}
+	if _, err := commandOutput(m.repoRoot, "git", "branch", "-D", broken.Branch); err != nil {
+		t.Fatalf("delete branch: %v", err)
+	}
+	h = m.sandboxHealth(*broken)
+	var problems []string
+	for _, p := range h.Problems {
+		if p.Fix == "" {
+			t.Fatalf("problem without fix: %+v", p)
+		}
+		problems = append(problems, p.Problem)
+	}
+	got := strings.Join(problems, "\n")
+	for _, want := range []string{"worktree directory is missing", "branch " + broken.Branch + " does not exist", `state "exited" (exit code 137)`} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("problems missing %q:\n%s", want, got)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeHealth(&buf, h, outputTable); err != nil {
+		t.Fatalf("writeHealth: %v", err)
+	}
+	for _, want := range []string{"on disk: no", "image opencode-sandbox:latest", "3 problem(s)", "fix: remove it with `docker rm " + broken.Container + "`"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestLaunchSandboxRecordsRuntimeAndExitCode(t *testing.T) {
+	origInteractive := interactiveCommandFn
+	t.Cleanup(func() { interactiveCommandFn = origInteractive })
+	m, meta := newGitSandbox(t, "launch")
+
+	interactiveCommandFn = func(name string, args ...string) error {
+		return exec.Command("sh", "-c", "exit 3").Run()
+	}
+	runtime := &runtimeSpec{Image: "custom:1", RunArgs: []string{"--cpus=2"}}
+	if err := m.launchSandbox(meta, runtime, "opencode", 0); err == nil {
+		t.Fatal("expected run error")
+	}
+	saved, err := m.loadSandbox("launch")
+	if err != nil {
+		t.Fatalf("loadSandbox: %v", err)
+	}
+	if saved.Runtime == nil || saved.Runtime.Image != "custom:1" || saved.Runtime.RunArgs[0] != "--cpus=2" {
+		t.Fatalf("runtime not recorded: %+v", saved.Runtime)
+	}
+	if saved.Command != "opencode" || saved.LastRunAt == "" || saved.ExitCode == nil || *saved.ExitCode != 3 {
+		t.Fatalf("unexpected run record %+v", saved)
+	}
+
+	h := m.sandboxHealth(*saved)
+	if len(h.Problems) != 1 || !strings.Contains(h.Problems[0].Problem, "exited with code 3") {
+		t.Fatalf("unexpected problems %+v", h.Problems)
+	}
+}
+
+func TestLaunchSandboxKeepsEnvValuesOutOfMetadata(t *testing.T) {
+	origInteractive := interactiveCommandFn
+	t.Cleanup(func() { interactiveCommandFn = origInteractive })
+	interactiveCommandFn = func(name string, args ...string) error { return nil }
+	m, meta := newGitSandbox(t, "secret")
+
+	runtime := &runtimeSpec{
+		Image: "custom:1",
+		ContainerEnv: map[string]string{
+			"VIBE_PROMPT":        "prompt-value",
+			"GIT_CONFIG_VALUE_0": "key::ssh-ed25519 AAAAsigning-key-value",
+		},
+		RunArgs: []string{"-e", "TOKEN=run-arg-value", "--env=OTHER=inline-value", "--cpus=2"},
+	}
+	if err := m.launchSandbox(meta, runtime, "opencode", 0); err != nil {
+		t.Fatalf("launchSandbox: %v", err)
+	}
+	raw, err := os.ReadFile(m.metaPath("secret"))
+	if err != nil {
+		t.Fatalf("read metadata: %v", err)
+	}
+	for _, value := range []string{"prompt-value", "signing-key-value", "run-arg-value", "inline-value"} {
+		if strings.Contains(string(raw), value) {
+			t.Fatalf("metadata leaked %q:\n%s", value, raw)
+		}
+	}
+	for _, name := range []string{"VIBE_PROMPT", "GIT_CONFIG_VALUE_0", "TOKEN", "OTHER", "--cpus=2"} {
+		if !strings.Contains(string(raw), name) {
+			t.Fatalf("metadata should keep %q:\n%s", name, raw)
+		}
+	}
+	if runtime.ContainerEnv["VIBE_PROMPT"] != "prompt-value" {
+		t.Fatal("redaction must not change the runtime passed to docker")
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	storeJSON   = "json"
+	storeSQLite = "sqlite"
+)
+
+// storedMeta is one encoded metadata record. The manager owns the encoding
+// and schema upgrades; stores only persist the bytes.
+type storedMeta struct {
+	name   string
+	source string
+	data   []byte
+}
+
+type sandboxStore interface {
+	// get returns os.ErrNotExist when the sandbox is unknown.
+	get(name string) ([]byte, error)
+	put(name string, data []byte) error
+	remove(name string) error
+	list() ([]storedMeta, error)
+}
+
+// historyStore is implemented by stores that keep destroyed sandboxes.
+type historyStore interface {
+	// archive moves a sandbox record into the history.
+	archive(entry historyEntry, data []byte) error
+	history(name string) ([]historyEntry, error)
+}
+
+type historyEntry struct {
+	Name        string `json:"name"`
+	Branch      string `json:"branch"`
+	BaseRef     string `json:"base_ref"`
+	CreatedAt   string `json:"created_at"`
+	DestroyedAt string `json:"destroyed_at"`
+	Forced      bool   `json:"forced"`
+	LastRunAt   string `json:"last_run_at,omitempty"`
+	ExitCode    *int   `json:"exit_code,omitempty"`
+	PRURL       string `json:"pr_url,omitempty"`
+}
+
+func openStore(sandboxRoot, metaDir string, cfg *vibeConfig) (sandboxStore, error) {
+	backend, err := cfg.sandboxStore()
+	if err != nil {
+		return nil, err
+	}
+	dbPath := filepath.Join(sandboxRoot, sqliteStoreFile)
+	// Moving sandboxes between backends rewrites the whole store, so it runs
+	// under the exclusive store lock, taken only when there is work to do.
+	lock := func() (*fileLock, error) { return (&manager{sandboxRoot: sandboxRoot}).lockStore(true) }
+	if backend == storeSQLite {
+		return openSQLiteStore(dbPath, metaDir, lock)
+	}
+	if err := exportSQLiteStore(dbPath, metaDir, lock); err != nil {
+		return nil, err
+	}
+	return jsonStore{dir: metaDir}, nil
+}
+
+// stateStore falls back to the JSON directory for managers built without
+// openManager.
+func (m *manager) stateStore() sandboxStore {
+	if m.store == nil {
+		return jsonStore{dir: m.metaDir}
+	}
+	return m.store
+}
+
+func (m *manager) sandboxExists(name string) bool {
+	_, err := m.stateStore().get(name)
+	return err == nil
+}
+
+// forgetSandbox drops the metadata of a destroyed sandbox, moving it into the
+// history when the store keeps one.
+func (m *manager) forgetSandbox(meta *sandboxMeta, forced bool) error {
+	store := m.stateStore()
+	hs, ok := store.(historyStore)
+	if !ok {
+		return store.remove(meta.Name)
+	}
+	data, err := store.get(meta.Name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return hs.archive(historyEntry{
+		Name:        meta.Name,
+		Branch:      meta.Branch,
+		BaseRef:     meta.BaseRef,
+		CreatedAt:   meta.CreatedAt,
+		DestroyedAt: time.Now().UTC().Format(time.RFC3339),
+		Forced:      forced,
+		LastRunAt:   meta.LastRunAt,
+		ExitCode:    meta.ExitCode,
+		PRURL:       meta.PRURL,
+	}, data)
+}
+
+func (m *manager) history(name string) ([]historyEntry, error) {
+	hs, ok := m.stateStore().(historyStore)
+	if !ok {
+		return nil, fmt.Errorf("sandbox history needs the sqlite store; set \"sandbox\": {\"store\": %q} in %s", storeSQLite, filepath.Join(configDir, configFile))
+	}
+	return hs.history(name)
+}
+
+func writeHistory(w io.Writer, entries []historyEntry, output string) error {
+	switch output {
+	case outputTable:
+	case outputJSON:
+		if entries == nil {
+			entries = []historyEntry{}
+		}
+		b, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	default:
+		return fmt.Errorf("unknown output format %q (want json)", output)
+	}
+
+	tw := tabwriter.NewWriter(w, 4, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tBRANCH\tCREATED\tDESTROYED\tFORCED\tEXIT\tPR")
+	for _, e := range entries {
+		exit := "-"
+		if e.ExitCode != nil {
+			exit = strconv.Itoa(*e.ExitCode)
+		}
+		pr := e.PRURL
+		if pr == "" {
+			pr = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\t%s\t%s\n", e.Name, e.Branch, e.CreatedAt, e.DestroyedAt, e.Forced, exit, pr)
+	}
+	return tw.Flush()
+}
+
+// jsonStore keeps one <name>.json file per sandbox.
+type jsonStore struct {
+	dir string
+}
+
+func (s jsonStore) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
+
+func (s jsonStore) get(name string) ([]byte, error) {
+	return os.ReadFile(s.path(name))
+}
+
+func (s jsonStore) put(name string, data []byte) error {
+	return writeFileAtomic(s.path(name), data)
+}
+
+func (s jsonStore) remove(name string) error {
+	if err := os.Remove(s.path(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s jsonStore) list() ([]storedMeta, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read metadata dir: %w", err)
+	}
+	records := make([]storedMeta, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, storedMeta{name: strings.TrimSuffix(entry.Name(), ".json"), source: path, data: b})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].name < records[j].name })
+	return records, nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteStoreFile = "state.db"
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS sandboxes (
+	name       TEXT PRIMARY KEY,
+	data       TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS history (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	name         TEXT NOT NULL,
+	branch       TEXT NOT NULL,
+	base_ref     TEXT NOT NULL,
+	created_at   TEXT NOT NULL,
+	destroyed_at TEXT NOT NULL,
+	forced       INTEGER NOT NULL,
+	last_run_at  TEXT NOT NULL,
+	exit_code    INTEGER,
+	pr_url       TEXT NOT NULL,
+	data         TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS history_name ON history (name, destroyed_at);
+`
+
+type sqliteStore struct {
+	db   *sql.DB
+	path string
+}
+
+// openSQLiteStore opens the database and imports any JSON metadata left in
+// metaDir under lock, so switching backends keeps existing sandboxes.
+func openSQLiteStore(path, metaDir string, lock func() (*fileLock, error)) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("open state db: %w", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init state db %s: %w", path, err)
+	}
+	s := &sqliteStore{db: db, path: path}
+	if err := s.importJSON(metaDir, lock); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// importJSON moves JSON metadata into the database. A file whose sandbox the
+// database already holds is left in place rather than lost.
+func (s *sqliteStore) importJSON(metaDir string, lock func() (*fileLock, error)) error {
+	js := jsonStore{dir: metaDir}
+	if records, err := js.list(); err != nil || len(records) == 0 {
+		return err
+	}
+	l, err := lock()
+	if err != nil {
+		return err
+	}
+	defer l.unlock()
+	records, err := js.list()
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		res, err := s.db.Exec(`INSERT OR IGNORE INTO sandboxes (name, data, updated_at) VALUES (?, ?, ?)`,
+			r.name, string(r.data), time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("import %s: %w", r.name, err)
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			fmt.Fprintf(os.Stderr, "warning: keep %s: %s already holds sandbox %s\n", r.source, s.path, r.name)
+			continue
+		}
+		if err := os.Remove(r.source); err != nil {
+			return fmt.Errorf("import %s: %w", r.name, err)
+		}
+	}
+	return nil
+}
+
+// exportSQLiteStore moves the sandboxes of a state db back into JSON files
+// when the store is switched back to json. The history stays in the db.
+func exportSQLiteStore(path, metaDir string, lock func() (*fileLock, error)) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(10000)")
+	if err != nil {
+		return fmt.Errorf("open state db: %w", err)
+	}
+	defer db.Close()
+	s := &sqliteStore{db: db, path: path}
+	records, err := s.list()
+	if err != nil {
+		return fmt.Errorf("read state db %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	l, err := lock()
+	if err != nil {
+		return err
+	}
+	defer l.unlock()
+	if records, err = s.list(); err != nil {
+		return fmt.Errorf("read state db %s: %w", path, err)
+	}
+	js := jsonStore{dir: metaDir}
+	for _, r := range records {
+		if _, err := os.Stat(js.path(r.name)); err == nil {
+			fmt.Fprintf(os.Stderr, "warning: keep sandbox %s in %s: %s already exists\n", r.name, path, js.path(r.name))
+			continue
+		}
+		if err := js.put(r.name, r.data); err != nil {
+			return fmt.Errorf("export %s: %w", r.name, err)
+		}
+		if err := s.remove(r.name); err != nil {
+			return fmt.Errorf("export %s: %w", r.name, err)
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) get(name string) ([]byte, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM sandboxes WHERE name = ?`, name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(data), nil
+}
+
+func (s *sqliteStore) put(name string, data []byte) error {
+	_, err := s.db.Exec(`INSERT INTO sandboxes (name, data, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`,
+		name, string(data), time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func (s *sqliteStore) remove(name string) error {
+	_, err := s.db.Exec(`DELETE FROM sandboxes WHERE name = ?`, name)
+	return err
+}
+
+func (s *sqliteStore) list() ([]storedMeta, error) {
+	rows, err := s.db.Query(`SELECT name, data FROM sandboxes ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []storedMeta
+	for rows.Next() {
+		var (
+			name string
+			data string
+		)
+		if err := rows.Scan(&name, &data); err != nil {
+			return nil, err
+		}
+		records = append(records, storedMeta{name: name, source: s.path + ":" + name, data: []byte(data)})
+	}
+	return records, rows.Err()
+}
+
+func (s *sqliteStore) archive(entry historyEntry, data []byte) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var exitCode any
+	if entry.ExitCode != nil {
+		exitCode = *entry.ExitCode
+	}
+	_, err = tx.Exec(`INSERT INTO history
+		(name, branch, base_ref, created_at, destroyed_at, forced, last_run_at, exit_code, pr_url, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.Name, entry.Branch, entry.BaseRef, entry.CreatedAt, entry.DestroyedAt, entry.Forced,
+		entry.LastRunAt, exitCode, entry.PRURL, string(data))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM sandboxes WHERE name = ?`, entry.Name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) history(name string) ([]historyEntry, error) {
+	query := `SELECT name, branch, base_ref, created_at, destroyed_at, forced, last_run_at, exit_code, pr_url
+		FROM history`
+	var args []any
+	if name != "" {
+		query += ` WHERE name = ?`
+		args = append(args, name)
+	}
+	rows, err := s.db.Query(query+` ORDER BY destroyed_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []historyEntry
+	for rows.Next() {
+		var (
+			e        historyEntry
+			exitCode sql.NullInt64
+		)
+		if err := rows.Scan(&e.Name, &e.Branch, &e.BaseRef, &e.CreatedAt, &e.DestroyedAt, &e.Forced, &e.LastRunAt, &exitCode, &e.PRURL); err != nil {
+			return nil, err
+		}
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			e.ExitCode = &code
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
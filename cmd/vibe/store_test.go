@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStoreImportsJSONAndKeepsHistory(t *testing.T) {
+	m, old := newGitSandbox(t, "old")
+	m.config = &vibeConfig{Sandbox: sandboxConfig{Store: storeSQLite}}
+	store, err := openStore(m.sandboxRoot, m.metaDir, m.config)
+	if err != nil {
+		t.Fatalf("openStore: %v", err)
+	}
+	m.store = store
+	if _, err := os.Stat(m.metaPath("old")); !os.IsNotExist(err) {
+		t.Fatalf("json metadata should be imported and removed, stat err=%v", err)
+	}
+	if _, err := m.loadSandbox("old"); err != nil {
+		t.Fatalf("loadSandbox after import: %v", err)
+	}
+	if _, err := m.createSandbox("new", "main", "", sandboxOptions{}); err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.saveSandbox(&sandboxMeta{Name: fmt.Sprintf("bulk-%d", i)}); err != nil {
+				t.Errorf("saveSandbox: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	metas, err := m.listSandboxes()
+	if err != nil || len(metas) != 12 {
+		t.Fatalf("listSandboxes = %d, %v; want 12", len(metas), err)
+	}
+
+	code := 3
+	old.ExitCode = &code
+	if err := m.saveSandbox(old); err != nil {
+		t.Fatalf("saveSandbox: %v", err)
+	}
+	if err := m.destroySandbox(old, true, true); err != nil {
+		t.Fatalf("destroySandbox: %v", err)
+	}
+	if m.sandboxExists("old") {
+		t.Fatal("destroyed sandbox still in the store")
+	}
+	entries, err := m.history("old")
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(entries) != 1 || !entries[0].Forced || entries[0].Branch != old.Branch || entries[0].ExitCode == nil || *entries[0].ExitCode != 3 {
+		t.Fatalf("unexpected history %+v", entries)
+	}
+	var buf bytes.Buffer
+	if err := writeHistory(&buf, entries, outputTable); err != nil {
+		t.Fatalf("writeHistory: %v", err)
+	}
+	if !strings.Contains(buf.String(), "old") || !strings.Contains(buf.String(), "true") {
+		t.Fatalf("history table:\n%s", buf.String())
+	}
+}
+
+func TestHistoryNeedsSQLiteStore(t *testing.T) {
+	m := newTestManager(t)
+	if _, err := m.history(""); err == nil || !strings.Contains(err.Error(), "sqlite") {
+		t.Fatalf("expected sqlite store error, got %v", err)
+	}
+	if _, err := openStore(m.sandboxRoot, m.metaDir, &vibeConfig{Sandbox: sandboxConfig{Store: "bolt"}}); err == nil {
+		t.Fatal("expected invalid store error")
+	}
+}
+
+func TestSwitchingStoresKeepsMetadata(t *testing.T) {
+	m, _ := newGitSandbox(t, "kept")
+	sqliteCfg := &vibeConfig{Sandbox: sandboxConfig{Store: storeSQLite}}
+	store, err := openStore(m.sandboxRoot, m.metaDir, sqliteCfg)
+	if err != nil {
+		t.Fatalf("openStore: %v", err)
+	}
+	store.(*sqliteStore).db.Close()
+
+	conflict := []byte(`{"schema_version": 1, "name": "kept", "branch": "other"}`)
+	if err := os.WriteFile(m.metaPath("kept"), conflict, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	store, err = openStore(m.sandboxRoot, m.metaDir, sqliteCfg)
+	if err != nil {
+		t.Fatalf("openStore: %v", err)
+	}
+	store.(*sqliteStore).db.Close()
+	if b, err := os.ReadFile(m.metaPath("kept")); err != nil || !bytes.Equal(b, conflict) {
+		t.Fatalf("json file not imported must be kept: %s, %v", b, err)
+	}
+	if err := os.Remove(m.metaPath("kept")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	m.store, err = openStore(m.sandboxRoot, m.metaDir, &vibeConfig{})
+	if err != nil {
+		t.Fatalf("openStore json: %v", err)
+	}
+	meta, err := m.loadSandbox("kept")
+	if err != nil || meta.Branch != "opencode/kept" {
+		t.Fatalf("metadata lost switching back to json: %+v, %v", meta, err)
+	}
+	store, err = openStore(m.sandboxRoot, m.metaDir, sqliteCfg)
+	if err != nil {
+		t.Fatalf("openStore: %v", err)
+	}
+	defer store.(*sqliteStore).db.Close()
+	if records, err := store.list(); err != nil || len(records) != 1 || records[0].name != "kept" {
+		t.Fatalf("records after switching back = %+v, %v", records, err)
+	}
+}
+
+func TestStoreSwitchTakesStoreLock(t *testing.T) {
+	m, _ := newGitSandbox(t, "locked")
+	lock, err := m.lockStore(true)
+	if err != nil {
+		t.Fatalf("lockStore: %v", err)
+	}
+
+	type result struct {
+		store sandboxStore
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		store, err := openStore(m.sandboxRoot, m.metaDir, &vibeConfig{Sandbox: sandboxConfig{Store: storeSQLite}})
+		done <- result{store, err}
+	}()
+	select {
+	case <-done:
+		t.Fatal("import ran while the store was locked")
+	case <-time.After(200 * time.Millisecond):
+	}
+	lock.unlock()
+
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("openStore: %v", res.err)
+	}
+	defer res.store.(*sqliteStore).db.Close()
+	if records, err := res.store.list(); err != nil || len(records) != 1 {
+		t.Fatalf("records after import = %+v, %v", records, err)
+	}
+}
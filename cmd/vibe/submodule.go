@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	errSubmoduleInit = errors.New("submodule initialization failed")
+	errLFSMissing    = errors.New("repository uses Git LFS but git-lfs is not installed")
+	errLFSCheckout   = errors.New("git lfs checkout failed")
+)
+
+type submodule struct {
+	Name string
+	Path string
+}
+
+func listSubmodules(worktree string) ([]submodule, error) {
+	if _, err := os.Stat(filepath.Join(worktree, ".gitmodules")); err != nil {
+		return nil, nil
+	}
+	out, err := gitOutputFn(worktree, "config", "-f", ".gitmodules", "--get-regexp", `^submodule\..*\.path$`)
+	if err != nil {
+		return nil, fmt.Errorf("read .gitmodules: %w", err)
+	}
+	var modules []submodule
+	for _, line := range splitLines(out) {
+		key, path, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "submodule."), ".path")
+		modules = append(modules, submodule{Name: name, Path: path})
+	}
+	return modules, nil
+}
+
+func (m *manager) initSubmodules(worktree string) error {
+	modules, err := listSubmodules(worktree)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSubmoduleInit, err)
+	}
+	if len(modules) == 0 {
+		return nil
+	}
+
+	commonDir, err := gitOutputFn(m.repoRoot, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSubmoduleInit, err)
+	}
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(m.repoRoot, commonDir)
+	}
+
+	for _, mod := range modules {
+		if _, err := os.Stat(filepath.Join(worktree, mod.Path)); err != nil {
+			continue
+		}
+		args := []string{"submodule", "update", "--init", "--recursive"}
+		if ref := filepath.Join(commonDir, "modules", mod.Name); isDir(ref) {
+			args = append(args, "--reference", ref)
+		}
+		args = append(args, "--", mod.Path)
+		if err := runCommandFn(worktree, os.Stdout, os.Stderr, "git", args...); err != nil {
+			return fmt.Errorf("%w for %s: %v; check the submodule URL and your access to it, or run `git submodule update --init` in the main checkout first", errSubmoduleInit, mod.Path, err)
+		}
+	}
+	return nil
+}
+
+func usesLFS(worktree string) bool {
+	out, err := gitOutputFn(worktree, "ls-files", "--", ".gitattributes", ":(glob)**/.gitattributes")
+	if err != nil {
+		return false
+	}
+	for _, rel := range splitLines(out) {
+		b, err := os.ReadFile(filepath.Join(worktree, rel))
+		if err != nil {
+			continue
+		}
+		if strings.Contains(string(b), "filter=lfs") {
+			return true
+		}
+	}
+	return false
+}
+
+func checkoutLFS(worktree string) error {
+	if !usesLFS(worktree) {
+		return nil
+	}
+	if _, err := commandOutputFn(worktree, "git", "lfs", "version"); err != nil {
+		return fmt.Errorf("%w; install it from https://git-lfs.com and run `git lfs install`", errLFSMissing)
+	}
+	// Objects already fetched by the main checkout are shared through the
+	// common git dir, so only go to the remote when some are still missing.
+	if err := runCommandFn(worktree, os.Stdout, os.Stderr, "git", "lfs", "checkout"); err != nil {
+		return fmt.Errorf("%w: %v", errLFSCheckout, err)
+	}
+	out, err := commandOutputFn(worktree, "git", "lfs", "ls-files")
+	if err != nil {
+		return fmt.Errorf("%w: %v", errLFSCheckout, err)
+	}
+	if !lfsObjectsMissing(out) {
+		return nil
+	}
+	if err := runCommandFn(worktree, os.Stdout, os.Stderr, "git", "lfs", "pull"); err != nil {
+		return fmt.Errorf("%w: %v; check LFS remote access and quota", errLFSCheckout, err)
+	}
+	return nil
+}
+
+// lfsObjectsMissing reports whether `git lfs ls-files` lists any file that is
+// still a pointer, marked "-" instead of "*".
+func lfsObjectsMissing(lsFiles string) bool {
+	for _, line := range splitLines(lsFiles) {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[1] == "-" {
+			return true
+		}
+	}
+	return false
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateSandboxInitializesSubmodules(t *testing.T) {
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+
+	m := newGitManager(t)
+	lib := t.TempDir()
+	if _, err := commandOutput("", "git", "init", "-q", "-b", "main", lib); err != nil {
+		t.Fatalf("git init lib: %v", err)
+	}
+	commitFile(t, lib, "lib.txt", "lib\n", "lib init")
+
+	if _, err := commandOutput(m.repoRoot, "git", "submodule", "add", "-q", lib, "vendor/lib"); err != nil {
+		t.Fatalf("git submodule add: %v", err)
+	}
+	if _, err := commandOutput(m.repoRoot, "git", "commit", "-q", "-m", "add submodule"); err != nil {
+		t.Fatalf("git commit: %v", err)
+	}
+
+	meta, err := m.createSandbox("sub", "main", "", sandboxOptions{})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(meta.Worktree, "vendor/lib/lib.txt")); err != nil {
+		t.Fatalf("submodule should be checked out: %v", err)
+	}
+	alternates, err := gitOutput(filepath.Join(meta.Worktree, "vendor/lib"), "rev-parse", "--git-path", "objects/info/alternates")
+	if err != nil {
+		t.Fatalf("rev-parse alternates: %v", err)
+	}
+	if !filepath.IsAbs(alternates) {
+		alternates = filepath.Join(meta.Worktree, "vendor/lib", alternates)
+	}
+	if b, err := os.ReadFile(alternates); err != nil || !strings.Contains(string(b), filepath.Join("modules", "vendor/lib")) {
+		t.Fatalf("submodule should reuse main repo objects, alternates=%q err=%v", b, err)
+	}
+}
+
+func TestCreateSandboxSubmoduleFailureRollsBack(t *testing.T) {
+	m := newGitManager(t)
+	if err := os.WriteFile(filepath.Join(m.repoRoot, ".gitmodules"), []byte("[submodule \"gone\"]\n\tpath = gone\n\turl = /nonexistent/repo\n"), 0o644); err != nil {
+		t.Fatalf("write .gitmodules: %v", err)
+	}
+	head, _ := gitOutput(m.repoRoot, "rev-parse", "HEAD")
+	if _, err := commandOutput(m.repoRoot, "git", "update-index", "--add", "--cacheinfo", "160000,"+head+",gone"); err != nil {
+		t.Fatalf("add gitlink: %v", err)
+	}
+	if _, err := commandOutput(m.repoRoot, "git", "add", ".gitmodules"); err != nil {
+		t.Fatalf("git add: %v", err)
+	}
+	if _, err := commandOutput(m.repoRoot, "git", "commit", "-q", "-m", "broken submodule"); err != nil {
+		t.Fatalf("git commit: %v", err)
+	}
+
+	_, err := m.createSandbox("broken", "main", "", sandboxOptions{})
+	if !errors.Is(err, errSubmoduleInit) {
+		t.Fatalf("expected errSubmoduleInit, got %v", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(m.sandboxRoot, "broken")); !errors.Is(statErr, os.ErrNotExist) {
+		t.Fatalf("worktree should be rolled back, stat err=%v", statErr)
+	}
+	if m.branchExists(defaultBranchPrefix + "/broken") {
+		t.Fatal("branch should be rolled back")
+	}
+}
+
+func TestCreateSandboxLFSMissing(t *testing.T) {
+	origOut := commandOutputFn
+	t.Cleanup(func() { commandOutputFn = origOut })
+
+	m := newGitManager(t)
+	commitFile(t, m.repoRoot, ".gitattributes", "*.bin filter=lfs diff=lfs merge=lfs -text\n", "track bin with lfs")
+
+	commandOutputFn = func(dir, name string, args ...string) (string, error) {
+		if name == "git" && len(args) > 0 && args[0] == "lfs" {
+			return "", errors.New("git: 'lfs' is not a git command")
+		}
+		return origOut(dir, name, args...)
+	}
+
+	_, err := m.createSandbox("lfs", "main", "", sandboxOptions{})
+	if !errors.Is(err, errLFSMissing) {
+		t.Fatalf("expected errLFSMissing, got %v", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(m.sandboxRoot, "lfs")); !errors.Is(statErr, os.ErrNotExist) {
+		t.Fatalf("worktree should be rolled back, stat err=%v", statErr)
+	}
+}
+
+func TestCheckoutLFSFetchesOnlyMissingObjects(t *testing.T) {
+	origOut, origRun := commandOutputFn, runCommandFn
+	t.Cleanup(func() { commandOutputFn, runCommandFn = origOut, origRun })
+
+	m := newGitManager(t)
+	commitFile(t, m.repoRoot, ".gitattributes", "*.bin filter=lfs diff=lfs merge=lfs -text\n", "track bin with lfs")
+
+	lsFiles := "4d7a214614 * a.bin\n"
+	commandOutputFn = func(dir, name string, args ...string) (string, error) {
+		if name == "git" && len(args) > 1 && args[0] == "lfs" {
+			if args[1] == "ls-files" {
+				return lsFiles, nil
+			}
+			return "git-lfs/3.4.0", nil
+		}
+		return origOut(dir, name, args...)
+	}
+	var calls []string
+	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error {
+		calls = append(calls, strings.Join(args, " "))
+		return nil
+	}
+
+	if err := checkoutLFS(m.repoRoot); err != nil {
+		t.Fatalf("checkoutLFS: %v", err)
+	}
+	if strings.Join(calls, ",") != "lfs checkout" {
+		t.Fatalf("present objects should only be checked out, got %q", calls)
+	}
+
+	calls = nil
+	lsFiles = "4d7a214614 * a.bin\n9c1185a5c5 - b.bin\n"
+	if err := checkoutLFS(m.repoRoot); err != nil {
+		t.Fatalf("checkoutLFS: %v", err)
+	}
+	if strings.Join(calls, ",") != "lfs checkout,lfs pull" {
+		t.Fatalf("missing objects should be pulled, got %q", calls)
+	}
+}
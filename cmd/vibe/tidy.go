@@ -0,0 +1,368 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	tidySquash = "squash"
+	tidyGroup  = "group"
+)
+
+var wipSubject = regexp.MustCompile(`(?i)^(wip\b|tmp\b|temp\b|fixup!|squash!|amend!|checkpoint\b|\.*$)`)
+
+type tidyCommit struct {
+	Hash        string
+	Subject     string
+	AuthorName  string
+	AuthorEmail string
+	AuthorDate  string
+	Trailers    []string
+	Files       []string
+}
+
+type tidyGroupPlan struct {
+	Commits []tidyCommit
+	Files   []string
+	Message string
+}
+
+type tidyResult struct {
+	Checkpoint *checkpoint
+	Groups     []tidyGroupPlan
+	Before     int
+	Head       string
+}
+
+func listTidyCommits(worktree, base, branch string) ([]tidyCommit, error) {
+	out, err := gitOutputFn(worktree, "log", "--reverse", "--format=%H%x1f%s%x1f%an%x1f%ae%x1f%aI%x1f%(trailers:only,unfold)%x1e", base+".."+branch)
+	if err != nil {
+		return nil, fmt.Errorf("list commits: %w", err)
+	}
+	var commits []tidyCommit
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.SplitN(strings.TrimSpace(record), "\x1f", 6)
+		if len(fields) < 5 {
+			continue
+		}
+		c := tidyCommit{Hash: fields[0], Subject: fields[1], AuthorName: fields[2], AuthorEmail: fields[3], AuthorDate: fields[4]}
+		if len(fields) == 6 {
+			c.Trailers = splitLines(fields[5])
+		}
+		files, err := gitOutputFn(worktree, "diff-tree", "--no-commit-id", "--name-only", "-r", "--no-renames", "--root", c.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("list files of %s: %w", c.Hash, err)
+		}
+		c.Files = splitLines(files)
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+func groupCommitsByFiles(commits []tidyCommit) []tidyGroupPlan {
+	parent := make([]int, len(commits))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	owner := map[string]int{}
+	for i, c := range commits {
+		for _, f := range c.Files {
+			if j, ok := owner[f]; ok {
+				a, b := find(i), find(j)
+				if a < b {
+					a, b = b, a
+				}
+				parent[a] = b
+			} else {
+				owner[f] = i
+			}
+		}
+	}
+
+	index := map[int]int{}
+	var groups []tidyGroupPlan
+	for i, c := range commits {
+		root := find(i)
+		g, ok := index[root]
+		if !ok {
+			g = len(groups)
+			index[root] = g
+			groups = append(groups, tidyGroupPlan{})
+		}
+		groups[g].Commits = append(groups[g].Commits, c)
+	}
+	for i := range groups {
+		groups[i].Files = unionFiles(groups[i].Commits)
+	}
+	return groups
+}
+
+func unionFiles(commits []tidyCommit) []string {
+	seen := map[string]bool{}
+	var files []string
+	for _, c := range commits {
+		for _, f := range c.Files {
+			if !seen[f] {
+				seen[f] = true
+				files = append(files, f)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func commonDir(files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	dir := path.Dir(files[0])
+	for _, f := range files[1:] {
+		for dir != "." && f != dir && !strings.HasPrefix(f, dir+"/") {
+			dir = path.Dir(dir)
+		}
+	}
+	return dir
+}
+
+func tidyMessage(commits []tidyCommit, files []string, fallback string) string {
+	var meaningful []string
+	seen := map[string]bool{}
+	for _, c := range commits {
+		if wipSubject.MatchString(c.Subject) || seen[c.Subject] {
+			continue
+		}
+		seen[c.Subject] = true
+		meaningful = append(meaningful, c.Subject)
+	}
+
+	subject := fallback
+	switch {
+	case len(meaningful) == 1:
+		subject = meaningful[0]
+	case len(files) == 1:
+		subject = "Update " + files[0]
+	case fallback == "":
+		if dir := commonDir(files); dir != "." && dir != "" {
+			subject = "Update " + dir
+		} else {
+			subject = fmt.Sprintf("Update %d files", len(files))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(subject)
+	if len(commits) > 1 {
+		b.WriteString("\n\n")
+		for _, c := range commits {
+			fmt.Fprintf(&b, "- %s\n", c.Subject)
+		}
+	}
+	if trailers := groupTrailers(commits); len(trailers) > 0 {
+		b.WriteString("\n")
+		if len(commits) == 1 {
+			b.WriteString("\n")
+		}
+		b.WriteString(strings.Join(trailers, "\n"))
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
+
+// groupTrailers merges the trailers of commits and credits every author other
+// than the first, whose name the tidied commit keeps, as a co-author.
+func groupTrailers(commits []tidyCommit) []string {
+	var trailers []string
+	seen := map[string]bool{}
+	add := func(trailer string) {
+		if !seen[trailer] {
+			seen[trailer] = true
+			trailers = append(trailers, trailer)
+		}
+	}
+	for _, c := range commits {
+		for _, t := range c.Trailers {
+			add(t)
+		}
+	}
+	for _, c := range commits[min(1, len(commits)):] {
+		if !strings.EqualFold(c.AuthorEmail, commits[0].AuthorEmail) {
+			add(fmt.Sprintf("Co-authored-by: %s <%s>", c.AuthorName, c.AuthorEmail))
+		}
+	}
+	return trailers
+}
+
+func (m *manager) planTidy(meta *sandboxMeta, strategy, message string) (string, []tidyGroupPlan, int, error) {
+	if meta.BaseRef == "" {
+		return "", nil, 0, fmt.Errorf("sandbox %q has no base ref recorded", meta.Name)
+	}
+	mergeBase, err := gitOutputFn(meta.Worktree, "merge-base", meta.BaseRef, meta.Branch)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("find merge base with %s: %w", meta.BaseRef, err)
+	}
+	commits, err := listTidyCommits(meta.Worktree, mergeBase, meta.Branch)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	var groups []tidyGroupPlan
+	switch strategy {
+	case tidySquash:
+		groups = []tidyGroupPlan{{Commits: commits, Files: unionFiles(commits)}}
+	case tidyGroup:
+		groups = groupCommitsByFiles(commits)
+	default:
+		return "", nil, 0, fmt.Errorf("unknown tidy strategy %q (want squash or group)", strategy)
+	}
+	if len(commits) < 2 || len(groups) == len(commits) {
+		return mergeBase, nil, len(commits), nil
+	}
+
+	for i := range groups {
+		switch {
+		case message != "" && len(groups) == 1:
+			msg := strings.TrimSpace(message)
+			if trailers := groupTrailers(groups[i].Commits); len(trailers) > 0 {
+				msg += "\n\n" + strings.Join(trailers, "\n")
+			}
+			groups[i].Message = msg + "\n"
+		case len(groups) == 1:
+			groups[i].Message = tidyMessage(groups[i].Commits, groups[i].Files, strings.ReplaceAll(meta.Name, "-", " "))
+		default:
+			groups[i].Message = tidyMessage(groups[i].Commits, groups[i].Files, "")
+		}
+	}
+	return mergeBase, groups, len(commits), nil
+}
+
+func (m *manager) tidySandbox(meta *sandboxMeta, strategy, message string, dryRun bool) (*tidyResult, error) {
+	status, err := gitOutputFn(meta.Worktree, "status", "--porcelain")
+	if err != nil {
+		return nil, fmt.Errorf("check worktree status: %w", err)
+	}
+	if status != "" {
+		return nil, errors.New("worktree has uncommitted changes; commit or discard them before tidying")
+	}
+
+	mergeBase, groups, before, err := m.planTidy(meta, strategy, message)
+	if err != nil {
+		return nil, err
+	}
+	result := &tidyResult{Groups: groups, Before: before}
+	if len(groups) == 0 || dryRun {
+		return result, nil
+	}
+
+	cp, err := m.createCheckpoint(meta, "before tidy")
+	if err != nil && !errors.Is(err, errNoChanges) {
+		return nil, fmt.Errorf("checkpoint before tidy: %w", err)
+	}
+	if cp == nil {
+		checkpoints, err := m.listCheckpoints(meta)
+		if err == nil && len(checkpoints) > 0 {
+			cp = &checkpoints[len(checkpoints)-1]
+		}
+	}
+	result.Checkpoint = cp
+
+	head, err := m.rewriteGroups(meta, mergeBase, groups)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gitOutputFn(meta.Worktree, "reset", "-q", "--soft", head); err != nil {
+		return nil, fmt.Errorf("move %s to tidied history: %w", meta.Branch, err)
+	}
+	result.Head = head
+	return result, nil
+}
+
+func (m *manager) rewriteGroups(meta *sandboxMeta, mergeBase string, groups []tidyGroupPlan) (string, error) {
+	tmpIndex, cleanup, err := tempIndexEnv()
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	commitTree := []string{"commit-tree"}
+	if key := m.commitsConfig().SigningKey; key != "" {
+		key, err := resolveSigningKey(key)
+		if err != nil {
+			return "", err
+		}
+		commitTree = []string{"-c", "gpg.format=ssh", "-c", "user.signingkey=" + key, "commit-tree", "-S"}
+	}
+	if _, err := gitOutputEnvFn(meta.Worktree, tmpIndex, "read-tree", mergeBase); err != nil {
+		return "", fmt.Errorf("read merge base tree: %w", err)
+	}
+	prev := mergeBase
+	for _, g := range groups {
+		for _, f := range g.Files {
+			entry, err := gitOutputFn(meta.Worktree, "ls-tree", meta.Branch, "--", f)
+			if err != nil {
+				return "", fmt.Errorf("inspect %s: %w", f, err)
+			}
+			if entry == "" {
+				if _, err := gitOutputEnvFn(meta.Worktree, tmpIndex, "update-index", "--force-remove", "--", f); err != nil {
+					return "", fmt.Errorf("stage removal of %s: %w", f, err)
+				}
+				continue
+			}
+			info, _, _ := strings.Cut(entry, "\t")
+			parts := strings.Fields(info)
+			if len(parts) != 3 {
+				return "", fmt.Errorf("unexpected ls-tree output %q", entry)
+			}
+			cacheinfo := parts[0] + "," + parts[2] + "," + f
+			if _, err := gitOutputEnvFn(meta.Worktree, tmpIndex, "update-index", "--add", "--cacheinfo", cacheinfo); err != nil {
+				return "", fmt.Errorf("stage %s: %w", f, err)
+			}
+		}
+		tree, err := gitOutputEnvFn(meta.Worktree, tmpIndex, "write-tree")
+		if err != nil {
+			return "", fmt.Errorf("write tree: %w", err)
+		}
+		args := append(append([]string{}, commitTree...), tree, "-p", prev, "-m", g.Message)
+		commit, err := gitOutputEnvFn(meta.Worktree, m.tidyIdentity(g.Commits), args...)
+		if err != nil {
+			return "", fmt.Errorf("commit tidied group: %w", err)
+		}
+		prev = commit
+	}
+
+	finalTree, _ := gitOutputFn(meta.Worktree, "rev-parse", prev+"^{tree}")
+	headTree, _ := gitOutputFn(meta.Worktree, "rev-parse", meta.Branch+"^{tree}")
+	if finalTree != headTree {
+		return "", errors.New("tidied history does not reproduce the branch tree; aborting")
+	}
+	return prev, nil
+}
+
+// tidyIdentity keeps the first author of a group with the group's latest
+// author date, and applies the configured committer identity.
+func (m *manager) tidyIdentity(commits []tidyCommit) []string {
+	author := commits[0]
+	env := []string{"GIT_AUTHOR_NAME=" + author.AuthorName, "GIT_AUTHOR_EMAIL=" + author.AuthorEmail}
+	if date := commits[len(commits)-1].AuthorDate; date != "" {
+		env = append(env, "GIT_AUTHOR_DATE="+date)
+	}
+	cfg := m.commitsConfig()
+	if cfg.CommitterName != "" {
+		env = append(env, "GIT_COMMITTER_NAME="+cfg.CommitterName)
+	}
+	if cfg.CommitterEmail != "" {
+		env = append(env, "GIT_COMMITTER_EMAIL="+cfg.CommitterEmail)
+	}
+	return env
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTidySquash(t *testing.T) {
+	m, meta := newGitSandbox(t, "tidy")
+	mainHead, _ := gitOutput(m.repoRoot, "rev-parse", "main")
+	commitFile(t, meta.Worktree, "login.go", "package login\n", "Add login")
+	commitFile(t, meta.Worktree, "login.go", "package login\n\nfunc Login() {}\n", "wip")
+	commitFile(t, meta.Worktree, "login_test.go", "package login\n", "WIP tests")
+	headTree, _ := gitOutput(meta.Worktree, "rev-parse", "HEAD^{tree}")
+
+	result, err := m.tidySandbox(meta, tidySquash, "", false)
+	if err != nil {
+		t.Fatalf("tidySandbox: %v", err)
+	}
+	if result.Before != 3 || len(result.Groups) != 1 || result.Checkpoint == nil {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	log, _ := gitOutput(meta.Worktree, "log", "--format=%s", "main..HEAD")
+	if log != "Add login" {
+		t.Fatalf("log after squash = %q", log)
+	}
+	body, _ := gitOutput(meta.Worktree, "log", "-1", "--format=%b")
+	if !strings.Contains(body, "- wip") || !strings.Contains(body, "- WIP tests") {
+		t.Fatalf("squash body missing original subjects:\n%s", body)
+	}
+	if tree, _ := gitOutput(meta.Worktree, "rev-parse", "HEAD^{tree}"); tree != headTree {
+		t.Fatalf("tree changed: %s != %s", tree, headTree)
+	}
+	if after, _ := gitOutput(m.repoRoot, "rev-parse", "main"); after != mainHead {
+		t.Fatal("base branch was rewritten")
+	}
+	if status, _ := gitOutput(meta.Worktree, "status", "--porcelain"); status != "" {
+		t.Fatalf("worktree dirty after tidy: %q", status)
+	}
+
+	if _, err := m.restoreCheckpoint(meta, result.Checkpoint.Number); err != nil {
+		t.Fatalf("restoreCheckpoint: %v", err)
+	}
+	if count, _ := gitOutput(meta.Worktree, "rev-list", "--count", "main..HEAD"); count != "3" {
+		t.Fatalf("restored history has %s commits, want 3", count)
+	}
+}
+
+func TestTidyGroupByFiles(t *testing.T) {
+	m, meta := newGitSandbox(t, "group")
+	commitFile(t, meta.Worktree, "a.txt", "a\n", "Add a")
+	commitFile(t, meta.Worktree, "b.txt", "b\n", "Add b")
+	commitFile(t, meta.Worktree, "a.txt", "a2\n", "fixup! Add a")
+
+	dry, err := m.tidySandbox(meta, tidyGroup, "", true)
+	if err != nil || len(dry.Groups) != 2 {
+		t.Fatalf("dry run = %+v, %v", dry, err)
+	}
+	if count, _ := gitOutput(meta.Worktree, "rev-list", "--count", "main..HEAD"); count != "3" {
+		t.Fatal("dry run rewrote history")
+	}
+
+	if _, err := m.tidySandbox(meta, tidyGroup, "", false); err != nil {
+		t.Fatalf("tidySandbox: %v", err)
+	}
+	log, _ := gitOutput(meta.Worktree, "log", "--reverse", "--format=%s", "main..HEAD")
+	if log != "Add a\nAdd b" {
+		t.Fatalf("grouped log = %q", log)
+	}
+	if b, _ := os.ReadFile(filepath.Join(meta.Worktree, "a.txt")); string(b) != "a2\n" {
+		t.Fatalf("a.txt = %q", b)
+	}
+	if files, _ := gitOutput(meta.Worktree, "show", "--name-only", "--format=", "HEAD~1"); files != "a.txt" {
+		t.Fatalf("first group files = %q", files)
+	}
+}
+
+func TestTidyRefusesDirtyWorktree(t *testing.T) {
+	m, meta := newGitSandbox(t, "dirty")
+	commitFile(t, meta.Worktree, "a.txt", "a\n", "one")
+	commitFile(t, meta.Worktree, "a.txt", "b\n", "two")
+	if err := os.WriteFile(filepath.Join(meta.Worktree, "a.txt"), []byte("c\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := m.tidySandbox(meta, tidySquash, "", false); err == nil || !strings.Contains(err.Error(), "uncommitted changes") {
+		t.Fatalf("expected dirty worktree error, got %v", err)
+	}
+}
+
+func TestTidyMessage(t *testing.T) {
+	commits := []tidyCommit{
+		{Subject: "wip", Trailers: []string{"Sandbox: x"}},
+		{Subject: "fixup! wip", Trailers: []string{"Sandbox: x"}},
+	}
+	got := tidyMessage(commits, []string{"pkg/a/x.go", "pkg/a/y.go"}, "")
+	want := "Update pkg/a\n\n- wip\n- fixup! wip\n\nSandbox: x\n"
+	if got != want {
+		t.Fatalf("tidyMessage = %q, want %q", got, want)
+	}
+}
+
+func TestTidyKeepsCommitPolicy(t *testing.T) {
+	origEnv := gitOutputEnvFn
+	t.Cleanup(func() { gitOutputEnvFn = origEnv })
+	var commitTree []string
+	gitOutputEnvFn = func(dir string, env []string, args ...string) (string, error) {
+		if i := slices.Index(args, "commit-tree"); i >= 0 {
+			commitTree = args
+			args = slices.DeleteFunc(slices.Clone(args[i:]), func(a string) bool { return a == "-S" })
+		}
+		return origEnv(dir, env, args...)
+	}
+
+	m, meta := newGitSandbox(t, "policy")
+	m.config = &vibeConfig{Commits: commitsConfig{
+		CommitterName:  "Bot",
+		CommitterEmail: "bot@example.com",
+		SigningKey:     "key::ssh-ed25519 AAAAtest",
+	}}
+	commitFile(t, meta.Worktree, "a.txt", "a\n", "Add a")
+	t.Setenv("GIT_AUTHOR_NAME", "Other")
+	t.Setenv("GIT_AUTHOR_EMAIL", "other@example.com")
+	t.Setenv("GIT_AUTHOR_DATE", "2024-05-06T07:08:09+00:00")
+	commitFile(t, meta.Worktree, "a.txt", "b\n", "wip")
+
+	if _, err := m.tidySandbox(meta, tidySquash, "", false); err != nil {
+		t.Fatalf("tidySandbox: %v", err)
+	}
+	if !slices.Contains(commitTree, "-S") || !slices.Contains(commitTree, "user.signingkey=key::ssh-ed25519 AAAAtest") {
+		t.Fatalf("tidied commit not signed: %v", commitTree)
+	}
+	got, _ := gitOutput(meta.Worktree, "log", "-1", "--format=%ae|%ce|%aI|%(trailers:only,unfold)")
+	want := "test@example.com|bot@example.com|2024-05-06T07:08:09+00:00|Co-authored-by: Other <other@example.com>"
+	if got != want {
+		t.Fatalf("tidied commit = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const trashRefPrefix = "refs/vibe/trash/"
+
+type trashEntry struct {
+	ID          string      `json:"id"`
+	Sandbox     sandboxMeta `json:"sandbox"`
+	Ref         string      `json:"ref"`
+	HasSnapshot bool        `json:"has_snapshot"`
+	Unpushed    int         `json:"unpushed"`
+	Changed     []string    `json:"changed,omitempty"`
+	Untracked   []string    `json:"untracked,omitempty"`
+	Tarball     string      `json:"tarball,omitempty"`
+	BranchKept  bool        `json:"branch_kept,omitempty"`
+	CreatedAt   string      `json:"created_at"`
+}
+
+type unsavedWork struct {
+	Unpushed  int
+	Changed   []string
+	Untracked []string
+}
+
+func (w unsavedWork) empty() bool {
+	return w.Unpushed == 0 && len(w.Changed) == 0 && len(w.Untracked) == 0
+}
+
+func (m *manager) trashDir() string {
+	return filepath.Join(m.sandboxRoot, "trash")
+}
+
+func (m *manager) branchExists(branch string) bool {
+	_, err := gitOutputFn(m.repoRoot, "rev-parse", "--verify", "-q", "refs/heads/"+branch)
+	return err == nil
+}
+
+func (m *manager) detectUnsavedWork(meta *sandboxMeta) (unsavedWork, error) {
+	var work unsavedWork
+	if meta.Branch != "" && m.branchExists(meta.Branch) {
+		args := []string{"rev-list", "--count", meta.Branch, "--not", "--remotes"}
+		if meta.BaseRef != "" {
+			args = append(args, meta.BaseRef)
+		}
+		out, err := gitOutputFn(m.repoRoot, args...)
+		if err != nil {
+			return work, fmt.Errorf("count unpushed commits: %w", err)
+		}
+		work.Unpushed, _ = strconv.Atoi(out)
+	}
+
+	if _, err := os.Stat(meta.Worktree); err != nil {
+		return work, nil
+	}
+	changed, err := gitOutputFn(meta.Worktree, "-c", "core.quotePath=false", "diff", "--name-only", "HEAD")
+	if err != nil {
+		return work, fmt.Errorf("list changed files: %w", err)
+	}
+	work.Changed = splitLines(changed)
+	untracked, err := gitOutputFn(meta.Worktree, "-c", "core.quotePath=false", "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return work, fmt.Errorf("list untracked files: %w", err)
+	}
+	work.Untracked = splitLines(untracked)
+	return work, nil
+}
+
+func (m *manager) archiveSandbox(meta *sandboxMeta, work unsavedWork) (*trashEntry, error) {
+	id := meta.Name + "-" + time.Now().Format("20060102-150405")
+	prev, err := m.archivedTrashEntry(meta)
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil {
+		id = prev.ID
+	}
+	entry := &trashEntry{
+		ID:        id,
+		Sandbox:   *meta,
+		Ref:       trashRefPrefix + id,
+		Unpushed:  work.Unpushed,
+		Changed:   work.Changed,
+		Untracked: work.Untracked,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	if err := os.MkdirAll(m.trashDir(), 0o755); err != nil {
+		return nil, fmt.Errorf("create trash dir: %w", err)
+	}
+
+	var commit string
+	if _, err := os.Stat(meta.Worktree); err == nil {
+		snapshot, _, _, err := snapshotWorktree(meta.Worktree, "trash: "+meta.Name, false)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot worktree: %w", err)
+		}
+		commit = snapshot
+		entry.HasSnapshot = true
+	} else {
+		head, err := gitOutputFn(m.repoRoot, "rev-parse", "refs/heads/"+meta.Branch)
+		if err != nil {
+			return nil, fmt.Errorf("resolve branch: %w", err)
+		}
+		commit = head
+	}
+	if _, err := gitOutputFn(m.repoRoot, "update-ref", entry.Ref, commit); err != nil {
+		return nil, fmt.Errorf("update trash ref: %w", err)
+	}
+
+	// A reused entry still points at its ref and tarball, so only a fresh
+	// entry cleans them up on failure.
+	discard := func() {
+		if prev != nil {
+			return
+		}
+		_, _ = gitOutputFn(m.repoRoot, "update-ref", "-d", entry.Ref)
+		if entry.Tarball != "" {
+			_ = os.Remove(entry.Tarball)
+		}
+	}
+	if len(work.Untracked) > 0 {
+		entry.Tarball = filepath.Join(m.trashDir(), id+".tar.gz")
+		if err := writeTarball(entry.Tarball, meta.Worktree, work.Untracked); err != nil {
+			discard()
+			return nil, fmt.Errorf("archive untracked files: %w", err)
+		}
+	} else if prev != nil && prev.Tarball != "" {
+		_ = os.Remove(prev.Tarball)
+	}
+
+	if err := m.saveTrashEntry(entry); err != nil {
+		discard()
+		return nil, err
+	}
+	return entry, nil
+}
+
+// archivedTrashEntry returns the entry an earlier, interrupted destroy of
+// this same sandbox left behind, so that retrying updates it instead of
+// adding a duplicate.
+func (m *manager) archivedTrashEntry(meta *sandboxMeta) (*trashEntry, error) {
+	if meta.CreatedAt == "" {
+		return nil, nil
+	}
+	entries, err := m.listTrash()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if e := entries[i]; e.Sandbox.Name == meta.Name && e.Sandbox.CreatedAt == meta.CreatedAt {
+			return &e, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *manager) saveTrashEntry(entry *trashEntry) error {
+	b, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(m.trashDir(), entry.ID+".json")
+	if err := writeFileAtomic(path, b); err != nil {
+		return fmt.Errorf("write trash entry: %w", err)
+	}
+	return nil
+}
+
+func (m *manager) listTrash() ([]trashEntry, error) {
+	entries, err := os.ReadDir(m.trashDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read trash dir: %w", err)
+	}
+	var result []trashEntry
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(m.trashDir(), e.Name())
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var entry trashEntry
+		if err := json.Unmarshal(b, &entry); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", path, err)
+		}
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt < result[j].CreatedAt })
+	return result, nil
+}
+
+func (m *manager) loadTrashEntry(id string) (*trashEntry, error) {
+	entries, err := m.listTrash()
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].ID == id {
+			return &entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("trash entry %q not found", id)
+}
+
+func (m *manager) removeTrashEntry(entry *trashEntry) error {
+	if _, err := gitOutputFn(m.repoRoot, "update-ref", "-d", entry.Ref); err != nil {
+		return fmt.Errorf("delete trash ref: %w", err)
+	}
+	if entry.Tarball != "" {
+		if err := os.Remove(entry.Tarball); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove tarball: %w", err)
+		}
+	}
+	if err := os.Remove(filepath.Join(m.trashDir(), entry.ID+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove trash entry: %w", err)
+	}
+	return nil
+}
+
+func (m *manager) restoreTrash(entry *trashEntry, name string) (*sandboxMeta, error) {
+	if name == "" {
+		name = entry.Sandbox.Name
+	}
+	if !validName(name) {
+		return nil, fmt.Errorf("invalid sandbox name %q", name)
+	}
+	unlock, err := m.lockSandbox(name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if m.sandboxExists(name) {
+		return nil, fmt.Errorf("sandbox %q already exists; pass --name to restore under another name", name)
+	}
+	worktree := filepath.Join(m.sandboxRoot, name)
+	if _, err := os.Stat(worktree); err == nil {
+		return nil, fmt.Errorf("worktree path already exists: %s", worktree)
+	}
+
+	start := entry.Ref
+	if entry.HasSnapshot {
+		start += "^"
+	}
+	branch := entry.Sandbox.Branch
+	if name != entry.Sandbox.Name || branch == "" {
+		prefix, _, ok := strings.Cut(entry.Sandbox.Branch, "/")
+		if !ok {
+			prefix = defaultBranchPrefix
+		}
+		branch = prefix + "/" + name
+	}
+	reattach := false
+	if m.branchExists(branch) {
+		reattach, err = m.canReattach(branch, start)
+		if err != nil {
+			return nil, err
+		}
+		if !reattach {
+			branch = m.freshBranch(branch)
+		}
+	}
+
+	addArgs := []string{"add", "-b", branch, worktree, start}
+	rollback := func() { m.removeWorktreeAndBranch(worktree, branch) }
+	if reattach {
+		addArgs = []string{"add", worktree, branch}
+		rollback = func() { _ = m.gitWorktree(io.Discard, io.Discard, "remove", worktree, "--force") }
+	}
+	if err := m.gitWorktree(os.Stdout, os.Stderr, addArgs...); err != nil {
+		return nil, fmt.Errorf("create worktree: %w", err)
+	}
+
+	if entry.HasSnapshot {
+		if _, err := gitOutputFn(worktree, "restore", "--source="+entry.Ref, "--worktree", "--", "."); err != nil {
+			rollback()
+			return nil, fmt.Errorf("restore changes: %w", err)
+		}
+	}
+	if entry.Tarball != "" {
+		if err := extractTarball(entry.Tarball, worktree); err != nil {
+			rollback()
+			return nil, fmt.Errorf("restore untracked files: %w", err)
+		}
+	}
+
+	meta := entry.Sandbox
+	meta.Name = name
+	meta.Branch = branch
+	meta.Worktree = worktree
+	meta.Container = containerName(m.repoRoot, name)
+	if err := m.saveSandbox(&meta); err != nil {
+		rollback()
+		return nil, err
+	}
+	m.recordEvent(sandboxEvent{Type: eventCreate, Sandbox: name, Branch: branch, BaseRef: meta.BaseRef})
+	if err := m.removeTrashEntry(entry); err != nil {
+		return &meta, err
+	}
+	return &meta, nil
+}
+
+// canReattach reports whether a restore can check out branch as it is: the
+// branch kept by destroy must still be where it was archived, and no other
+// sandbox or worktree may use it.
+func (m *manager) canReattach(branch, start string) (bool, error) {
+	tip, err := gitOutputFn(m.repoRoot, "rev-parse", "refs/heads/"+branch)
+	if err != nil {
+		return false, fmt.Errorf("resolve branch: %w", err)
+	}
+	want, err := gitOutputFn(m.repoRoot, "rev-parse", start)
+	if err != nil {
+		return false, fmt.Errorf("resolve trash entry: %w", err)
+	}
+	if tip != want {
+		return false, nil
+	}
+	metas, err := m.listSandboxes()
+	if err != nil {
+		return false, err
+	}
+	for _, meta := range metas {
+		if meta.Branch == branch {
+			return false, nil
+		}
+	}
+	worktrees, err := listWorktrees(m.repoRoot)
+	if err != nil {
+		return false, err
+	}
+	for _, wt := range worktrees {
+		if wt.Branch == branch {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// freshBranch returns branch, or branch with the first free numeric suffix.
+func (m *manager) freshBranch(branch string) string {
+	candidate := branch
+	for i := 2; m.branchExists(candidate); i++ {
+		candidate = fmt.Sprintf("%s-%d", branch, i)
+	}
+	return candidate
+}
+
+func (m *manager) purgeTrash(all bool) (int, error) {
+	retention, err := m.config.trashRetention()
+	if err != nil {
+		return 0, err
+	}
+	entries, err := m.listTrash()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-retention)
+	count := 0
+	for i := range entries {
+		if !all {
+			created, err := time.Parse(time.RFC3339, entries[i].CreatedAt)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: keep %s: unknown creation time %q; use --all to purge it\n", entries[i].ID, entries[i].CreatedAt)
+				continue
+			}
+			if created.After(cutoff) {
+				continue
+			}
+		}
+		if err := m.removeTrashEntry(&entries[i]); err != nil {
+			return count, fmt.Errorf("purge %s: %w", entries[i].ID, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+func writeTarball(path, root string, files []string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(path)
+		}
+	}()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, rel := range files {
+		if err := addTarFile(tw, root, rel); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addTarFile(tw *tar.Writer, root, rel string) error {
+	full := filepath.Join(root, rel)
+	info, err := os.Lstat(full)
+	if err != nil {
+		return err
+	}
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(full); err != nil {
+			return err
+		}
+	}
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = filepath.ToSlash(rel)
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	src, err := os.Open(full)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = io.Copy(tw, src)
+	return err
+}
+
+func extractTarball(path, root string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(root, filepath.FromSlash(hdr.Name))
+		if !strings.HasPrefix(target, filepath.Clean(root)+string(filepath.Separator)) {
+			return fmt.Errorf("unsafe path in archive: %s", hdr.Name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode)&os.ModePerm)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func splitLines(out string) []string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
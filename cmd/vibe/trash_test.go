@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDestroySandboxArchivesUnsavedWork(t *testing.T) {
+	m, meta := newGitSandbox(t, "tr")
+	commitFile(t, meta.Worktree, "committed.txt", "c\n", "unpushed work")
+	if err := os.WriteFile(filepath.Join(meta.Worktree, "README.md"), []byte("dirty\n"), 0o644); err != nil {
+		t.Fatalf("modify README: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(meta.Worktree, "notes"), 0o755); err != nil {
+		t.Fatalf("mkdir notes: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(meta.Worktree, "notes", "todo.txt"), []byte("todo\n"), 0o644); err != nil {
+		t.Fatalf("write untracked: %v", err)
+	}
+
+	if err := m.destroySandbox(meta, true, true); err != nil {
+		t.Fatalf("destroySandbox: %v", err)
+	}
+	if m.branchExists(meta.Branch) {
+		t.Fatal("branch should be deleted")
+	}
+
+	entries, err := m.listTrash()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("listTrash = %+v (err=%v), want one entry", entries, err)
+	}
+	entry := entries[0]
+	if entry.Unpushed != 1 || len(entry.Changed) != 1 || len(entry.Untracked) != 1 || entry.Tarball == "" {
+		t.Fatalf("unexpected trash entry: %+v", entry)
+	}
+
+	restored, err := m.restoreTrash(&entry, "")
+	if err != nil {
+		t.Fatalf("restoreTrash: %v", err)
+	}
+	if restored.Branch != meta.Branch {
+		t.Fatalf("restored branch = %q, want %q", restored.Branch, meta.Branch)
+	}
+	for file, want := range map[string]string{"committed.txt": "c\n", "README.md": "dirty\n", "notes/todo.txt": "todo\n"} {
+		b, err := os.ReadFile(filepath.Join(restored.Worktree, file))
+		if err != nil || string(b) != want {
+			t.Fatalf("%s = %q (err=%v), want %q", file, b, err, want)
+		}
+	}
+	if entries, _ := m.listTrash(); len(entries) != 0 {
+		t.Fatalf("trash entry should be removed after restore: %+v", entries)
+	}
+	if got := journalTypes(t, m, "tr"); got != "create destroy create" {
+		t.Fatalf("journal after restore = %q", got)
+	}
+}
+
+func TestDestroySandboxRetryReusesTrashEntry(t *testing.T) {
+	origRun := runCommandFn
+	t.Cleanup(func() { runCommandFn = origRun })
+
+	m, meta := newGitSandbox(t, "retry")
+	commitFile(t, meta.Worktree, "work.txt", "w\n", "unpushed work")
+	runCommandFn = func(dir string, stdout, stderr io.Writer, name string, args ...string) error {
+		if name == "git" && len(args) > 1 && args[0] == "worktree" && args[1] == "remove" {
+			return errors.New("worktree is busy")
+		}
+		return origRun(dir, stdout, stderr, name, args...)
+	}
+	if err := m.destroySandbox(meta, true, true); err == nil {
+		t.Fatal("destroySandbox should fail when the worktree cannot be removed")
+	}
+	entries, err := m.listTrash()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("listTrash = %+v (err=%v), want one entry", entries, err)
+	}
+	// Date the entry back so a retry within the same second cannot reuse
+	// its ID by accident.
+	first := entries[0]
+	if err := os.Remove(filepath.Join(m.trashDir(), first.ID+".json")); err != nil {
+		t.Fatalf("remove entry: %v", err)
+	}
+	first.ID = "retry-20200101-000000"
+	if err := m.saveTrashEntry(&first); err != nil {
+		t.Fatalf("saveTrashEntry: %v", err)
+	}
+
+	runCommandFn = origRun
+	if err := m.destroySandbox(meta, true, true); err != nil {
+		t.Fatalf("destroySandbox retry: %v", err)
+	}
+	entries, err = m.listTrash()
+	if err != nil || len(entries) != 1 || entries[0].ID != first.ID {
+		t.Fatalf("retry should update entry %s, got %+v (err=%v)", first.ID, entries, err)
+	}
+}
+
+func TestRestoreTrashWithKeptBranch(t *testing.T) {
+	m, meta := newGitSandbox(t, "kept")
+	if err := os.WriteFile(filepath.Join(meta.Worktree, "README.md"), []byte("dirty\n"), 0o644); err != nil {
+		t.Fatalf("modify README: %v", err)
+	}
+	if err := m.destroySandbox(meta, true, false); err != nil {
+		t.Fatalf("destroySandbox: %v", err)
+	}
+	entries, err := m.listTrash()
+	if err != nil || len(entries) != 1 || !entries[0].BranchKept {
+		t.Fatalf("listTrash = %+v (err=%v), want one entry with the branch kept", entries, err)
+	}
+
+	restored, err := m.restoreTrash(&entries[0], "")
+	if err != nil {
+		t.Fatalf("restoreTrash: %v", err)
+	}
+	if restored.Branch != meta.Branch {
+		t.Fatalf("restored branch = %q, want the kept branch %q", restored.Branch, meta.Branch)
+	}
+	if b, err := os.ReadFile(filepath.Join(restored.Worktree, "README.md")); err != nil || string(b) != "dirty\n" {
+		t.Fatalf("README.md = %q (err=%v), want the archived change", b, err)
+	}
+
+	// Once the kept branch moves on, the restore gets a branch of its own.
+	if err := os.WriteFile(filepath.Join(restored.Worktree, "README.md"), []byte("dirty again\n"), 0o644); err != nil {
+		t.Fatalf("modify README: %v", err)
+	}
+	if err := m.destroySandbox(restored, true, false); err != nil {
+		t.Fatalf("destroySandbox: %v", err)
+	}
+	commitFile(t, m.repoRoot, "main.txt", "main\n", "main moves on")
+	if _, err := gitOutput(m.repoRoot, "branch", "-f", meta.Branch, "main"); err != nil {
+		t.Fatalf("move branch: %v", err)
+	}
+	entries, _ = m.listTrash()
+	if len(entries) != 1 {
+		t.Fatalf("listTrash = %+v, want one entry", entries)
+	}
+	restored, err = m.restoreTrash(&entries[0], "")
+	if err != nil {
+		t.Fatalf("restoreTrash: %v", err)
+	}
+	if restored.Branch != meta.Branch+"-2" {
+		t.Fatalf("restored branch = %q, want a fresh branch", restored.Branch)
+	}
+}
+
+func TestDestroySandboxSkipsArchiveWhenClean(t *testing.T) {
+	m, meta := newGitSandbox(t, "clean")
+	if err := m.destroySandbox(meta, true, true); err != nil {
+		t.Fatalf("destroySandbox: %v", err)
+	}
+	if entries, _ := m.listTrash(); len(entries) != 0 {
+		t.Fatalf("clean sandbox should not be archived: %+v", entries)
+	}
+}
+
+func TestPurgeTrashHonorsRetention(t *testing.T) {
+	m, meta := newGitSandbox(t, "pg")
+	commitFile(t, meta.Worktree, "x.txt", "x\n", "work")
+	work, err := m.detectUnsavedWork(meta)
+	if err != nil {
+		t.Fatalf("detectUnsavedWork: %v", err)
+	}
+	entry, err := m.archiveSandbox(meta, work)
+	if err != nil {
+		t.Fatalf("archiveSandbox: %v", err)
+	}
+
+	m.config = &vibeConfig{Trash: trashConfig{Retention: "1d"}}
+	if count, err := m.purgeTrash(false); err != nil || count != 0 {
+		t.Fatalf("purge fresh entry: count=%d err=%v", count, err)
+	}
+
+	entry.CreatedAt = "yesterday"
+	if err := m.saveTrashEntry(entry); err != nil {
+		t.Fatalf("saveTrashEntry: %v", err)
+	}
+	if count, err := m.purgeTrash(false); err != nil || count != 0 {
+		t.Fatalf("purge entry with unknown age: count=%d err=%v", count, err)
+	}
+
+	entry.CreatedAt = time.Now().Add(-48 * time.Hour).Format(time.RFC3339)
+	if err := m.saveTrashEntry(entry); err != nil {
+		t.Fatalf("saveTrashEntry: %v", err)
+	}
+	if count, err := m.purgeTrash(false); err != nil || count != 1 {
+		t.Fatalf("purge expired entry: count=%d err=%v", count, err)
+	}
+	if out, _ := gitOutput(m.repoRoot, "for-each-ref", trashRefPrefix); strings.TrimSpace(out) != "" {
+		t.Fatalf("trash ref should be deleted, got %q", out)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"7d":  7 * 24 * time.Hour,
+		"72h": 72 * time.Hour,
+		"90m": 90 * time.Minute,
+	}
+	for in, want := range cases {
+		got, err := parseDuration(in)
+		if err != nil || got != want {
+			t.Fatalf("parseDuration(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseDuration("xd"); err == nil {
+		t.Fatal("expected error for invalid day duration")
+	}
+}
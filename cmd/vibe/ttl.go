@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const expiryWarningWindow = 24 * time.Hour
+
+// lastActivity is the latest of the sandbox creation, its last container run,
+// the last commit on its branch and the last edit to an uncommitted file.
+func (m *manager) lastActivity(meta *sandboxMeta) time.Time {
+	var latest time.Time
+	for _, ts := range []string{meta.CreatedAt, meta.LastRunAt, meta.LastActive} {
+		if t, err := time.Parse(time.RFC3339, ts); err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+	if meta.Branch != "" {
+		if out, err := gitOutputFn(m.repoRoot, "log", "-1", "--format=%cI", "refs/heads/"+meta.Branch, "--"); err == nil {
+			if t, err := time.Parse(time.RFC3339, out); err == nil && t.After(latest) {
+				latest = t
+			}
+		}
+	}
+	if t := lastWorktreeEdit(meta.Worktree); t.After(latest) {
+		latest = t
+	}
+	return latest
+}
+
+// lastWorktreeEdit returns the latest modification time of the files git
+// reports as changed or untracked in worktree.
+func lastWorktreeEdit(worktree string) time.Time {
+	var latest time.Time
+	if worktree == "" {
+		return latest
+	}
+	out, err := gitOutputFn(worktree, "--no-optional-locks", "status", "--porcelain=v2", "-z", "--untracked-files=all")
+	if err != nil {
+		return latest
+	}
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		var path string
+		switch fields := strings.Fields(entries[i]); {
+		case len(fields) == 0:
+			continue
+		case fields[0] == "1":
+			path = porcelainPath(entries[i], 8)
+		case fields[0] == "2":
+			path = porcelainPath(entries[i], 9)
+			i++
+		case fields[0] == "u":
+			path = porcelainPath(entries[i], 10)
+		case fields[0] == "?":
+			path = porcelainPath(entries[i], 1)
+		}
+		if path == "" {
+			continue
+		}
+		if info, err := os.Lstat(filepath.Join(worktree, path)); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// porcelainPath returns what follows the first n space-separated fields of entry.
+func porcelainPath(entry string, n int) string {
+	parts := strings.SplitN(entry, " ", n+1)
+	if len(parts) <= n {
+		return ""
+	}
+	return parts[n]
+}
+
+// expiry returns when an idle sandbox expires: its TTL after the last
+// activity, but never before the recorded expiry. ok is false without a TTL.
+func (m *manager) expiry(meta *sandboxMeta) (time.Time, bool) {
+	if meta.TTL == "" {
+		return time.Time{}, false
+	}
+	ttl, err := parseDuration(meta.TTL)
+	if err != nil {
+		return time.Time{}, false
+	}
+	expires := m.lastActivity(meta).Add(ttl)
+	if recorded, err := time.Parse(time.RFC3339, meta.ExpiresAt); err == nil && recorded.After(expires) {
+		expires = recorded
+	}
+	return expires, true
+}
+
+// touchSandbox records container activity and pushes the expiry out by the TTL.
+func touchSandbox(meta *sandboxMeta) {
+	now := time.Now()
+	meta.LastActive = now.UTC().Format(time.RFC3339)
+	if meta.TTL == "" {
+		return
+	}
+	if ttl, err := parseDuration(meta.TTL); err == nil {
+		meta.ExpiresAt = now.Add(ttl).UTC().Format(time.RFC3339)
+	}
+}
+
+func (m *manager) expiredSandboxes(now time.Time) ([]sandboxMeta, error) {
+	metas, err := m.listSandboxes()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(metas, func(i, j int) bool { return metas[i].Name < metas[j].Name })
+	states := m.containerStates()
+	var expired []sandboxMeta
+	for _, meta := range metas {
+		if states[meta.Container] == containerRunning {
+			continue
+		}
+		if expires, ok := m.expiry(&meta); ok && !expires.After(now) {
+			expired = append(expired, meta)
+		}
+	}
+	return expired, nil
+}
+
+// collectGarbage removes expired sandboxes. Unsaved work goes to the trash,
+// as with `vibe done --force`.
+func (m *manager) collectGarbage(dryRun bool) (int, error) {
+	expired, err := m.expiredSandboxes(time.Now())
+	if err != nil {
+		return 0, err
+	}
+	var (
+		count    int
+		failures []string
+	)
+	for i := range expired {
+		meta := &expired[i]
+		if dryRun {
+			fmt.Printf("would reap %s (ttl %s, idle since %s)\n", meta.Name, meta.TTL, m.lastActivity(meta).Format(time.RFC3339))
+			continue
+		}
+		reaped, err := m.reapSandbox(meta.Name, time.Now())
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", meta.Name, err))
+			continue
+		}
+		if !reaped {
+			continue
+		}
+		count++
+		fmt.Printf("reaped expired sandbox %s\n", meta.Name)
+	}
+	if len(failures) > 0 {
+		return count, fmt.Errorf("failed to reap some sandboxes:\n%s", strings.Join(failures, "\n"))
+	}
+	return count, nil
+}
+
+// reapSandbox force-destroys name if it is still expired and has no running
+// container once its lock is held: `vibe go` may have started it or pushed
+// its expiry out since expiredSandboxes looked.
+func (m *manager) reapSandbox(name string, now time.Time) (bool, error) {
+	unlock, err := m.lockSandbox(name)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	if !m.sandboxExists(name) {
+		return false, nil
+	}
+	meta, err := m.loadSandbox(name)
+	if err != nil {
+		return false, err
+	}
+	if expires, ok := m.expiry(meta); !ok || expires.After(now) {
+		return false, nil
+	}
+	for _, c := range m.repoContainers(name) {
+		if c.State == containerRunning {
+			return false, nil
+		}
+	}
+	return true, m.destroyLocked(meta, true, true)
+}
+
+func writeExpiryWarnings(w io.Writer, statuses []sandboxStatus, now time.Time) {
+	for _, s := range statuses {
+		if s.ExpiresAt == "" || s.ContainerState == containerRunning {
+			continue
+		}
+		expires, err := time.Parse(time.RFC3339, s.ExpiresAt)
+		if err != nil {
+			continue
+		}
+		left := expires.Sub(now)
+		switch {
+		case left <= 0:
+			fmt.Fprintf(w, "warning: sandbox %s expired %s ago; `vibe gc` will reap it\n", s.displayName(), formatAge(-left))
+		case left < expiryWarningWindow:
+			fmt.Fprintf(w, "warning: sandbox %s expires in %s\n", s.displayName(), formatAge(left))
+		}
+	}
+}
+
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	}
+}
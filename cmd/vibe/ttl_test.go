@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateSandboxTTL(t *testing.T) {
+	m := newGitManager(t)
+	meta, err := m.createSandbox("short", "main", "", sandboxOptions{TTL: "2h"})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	expires, err := time.Parse(time.RFC3339, meta.ExpiresAt)
+	if err != nil || meta.TTL != "2h" || expires.Before(time.Now().Add(time.Hour)) {
+		t.Fatalf("unexpected ttl %q expires %q", meta.TTL, meta.ExpiresAt)
+	}
+
+	m.config = &vibeConfig{Sandbox: sandboxConfig{TTL: "7d"}}
+	meta, err = m.createSandbox("default", "main", "", sandboxOptions{})
+	if err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+	if meta.TTL != "7d" {
+		t.Fatalf("ttl = %q, want config default", meta.TTL)
+	}
+
+	if _, err := m.createSandbox("bad", "main", "", sandboxOptions{TTL: "soon"}); err == nil || !strings.Contains(err.Error(), "invalid ttl") {
+		t.Fatalf("expected invalid ttl error, got %v", err)
+	}
+	if _, err := os.Stat(m.metaPath("bad")); !os.IsNotExist(err) {
+		t.Fatal("invalid ttl must not create the sandbox")
+	}
+}
+
+func TestExpiredSandboxesTracksIdleTime(t *testing.T) {
+	origOutput := commandOutputFn
+	t.Cleanup(func() { commandOutputFn = origOutput })
+	var m *manager
+	commandOutputFn = func(dir, name string, args ...string) (string, error) {
+		if name == "docker" && args[0] == "ps" {
+			return containerName(m.repoRoot, "busy") + "\tbusy\trunning\n", nil
+		}
+		return "", errors.New("unexpected command")
+	}
+
+	m, idle := newGitSandbox(t, "idle")
+	for name, ttl := range map[string]string{"busy": "2h", "keep": ""} {
+		if _, err := m.createSandbox(name, "main", "", sandboxOptions{TTL: ttl}); err != nil {
+			t.Fatalf("createSandbox: %v", err)
+		}
+	}
+	idle.TTL = "2h"
+	idle.ExpiresAt = ""
+	if err := m.saveSandbox(idle); err != nil {
+		t.Fatalf("saveSandbox: %v", err)
+	}
+
+	now := time.Now()
+	expired, err := m.expiredSandboxes(now.Add(3 * time.Hour))
+	if err != nil {
+		t.Fatalf("expiredSandboxes: %v", err)
+	}
+	if len(expired) != 1 || expired[0].Name != "idle" {
+		t.Fatalf("expired = %+v, want only idle", expired)
+	}
+
+	t.Setenv("GIT_COMMITTER_DATE", now.Add(150*time.Minute).Format(time.RFC3339))
+	commitFile(t, idle.Worktree, "late.txt", "x\n", "late work")
+	expired, err = m.expiredSandboxes(now.Add(3 * time.Hour))
+	if err != nil {
+		t.Fatalf("expiredSandboxes: %v", err)
+	}
+	if len(expired) != 0 {
+		t.Fatalf("a recent commit should keep the sandbox alive, got %+v", expired)
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	t.Setenv("GIT_COMMITTER_DATE", "2020-01-01T00:00:00Z")
+	m, meta := newGitSandbox(t, "stale")
+	meta.TTL = "1h"
+	meta.CreatedAt = "2020-01-01T00:00:00Z"
+	meta.ExpiresAt = "2020-01-01T01:00:00Z"
+	if err := m.saveSandbox(meta); err != nil {
+		t.Fatalf("saveSandbox: %v", err)
+	}
+	if _, err := m.createSandbox("fresh", "main", "", sandboxOptions{TTL: "1h"}); err != nil {
+		t.Fatalf("createSandbox: %v", err)
+	}
+
+	if count, err := m.collectGarbage(true); err != nil || count != 0 {
+		t.Fatalf("dry run = %d, %v", count, err)
+	}
+	if _, err := m.loadSandbox("stale"); err != nil {
+		t.Fatalf("dry run removed the sandbox: %v", err)
+	}
+	count, err := m.collectGarbage(false)
+	if err != nil || count != 1 {
+		t.Fatalf("collectGarbage = %d, %v", count, err)
+	}
+	if _, err := m.loadSandbox("stale"); err == nil {
+		t.Fatal("expired sandbox should be reaped")
+	}
+	if _, err := m.loadSandbox("fresh"); err != nil {
+		t.Fatalf("fresh sandbox should survive: %v", err)
+	}
+}
+
+func TestReapSandboxRechecksUnderLock(t *testing.T) {
+	origOut := commandOutputFn
+	t.Cleanup(func() { commandOutputFn = origOut })
+
+	t.Setenv("GIT_COMMITTER_DATE", "2020-01-01T00:00:00Z")
+	m, meta := newGitSandbox(t, "revived")
+	meta.TTL = "1h"
+	meta.CreatedAt = "2020-01-01T00:00:00Z"
+	meta.ExpiresAt = "2020-01-01T01:00:00Z"
+	if err := m.saveSandbox(meta); err != nil {
+		t.Fatalf("saveSandbox: %v", err)
+	}
+	expired, err := m.expiredSandboxes(time.Now())
+	if err != nil || len(expired) != 1 {
+		t.Fatalf("expiredSandboxes = %+v, %v", expired, err)
+	}
+
+	running := true
+	commandOutputFn = func(dir, name string, args ...string) (string, error) {
+		if name == "docker" && len(args) > 0 && args[0] == "ps" {
+			if running {
+				return meta.Container + "\trevived\trunning\n", nil
+			}
+			return "", nil
+		}
+		return origOut(dir, name, args...)
+	}
+	if reaped, err := m.reapSandbox("revived", time.Now()); err != nil || reaped {
+		t.Fatalf("running sandbox reaped = %v, %v", reaped, err)
+	}
+
+	running = false
+	if _, err := m.updateSandbox("revived", touchSandbox); err != nil {
+		t.Fatalf("updateSandbox: %v", err)
+	}
+	if reaped, err := m.reapSandbox("revived", time.Now()); err != nil || reaped {
+		t.Fatalf("sandbox with extended TTL reaped = %v, %v", reaped, err)
+	}
+	if _, err := m.loadSandbox("revived"); err != nil {
+		t.Fatalf("sandbox should survive: %v", err)
+	}
+}
+
+func TestWriteExpiryWarnings(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+	statuses := []sandboxStatus{
+		{sandboxMeta: sandboxMeta{Name: "soon", ExpiresAt: at(2 * time.Hour)}},
+		{sandboxMeta: sandboxMeta{Name: "gone", ExpiresAt: at(-3 * 24 * time.Hour)}},
+		{sandboxMeta: sandboxMeta{Name: "later", ExpiresAt: at(48 * time.Hour)}},
+		{sandboxMeta: sandboxMeta{Name: "running", ExpiresAt: at(-time.Hour)}, ContainerState: containerRunning},
+	}
+	var buf bytes.Buffer
+	writeExpiryWarnings(&buf, statuses, now)
+	want := "warning: sandbox soon expires in 2h\nwarning: sandbox gone expired 3d ago; `vibe gc` will reap it\n"
+	if buf.String() != want {
+		t.Fatalf("warnings =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestLastActivityCountsUncommittedEdits(t *testing.T) {
+	m, meta := newGitSandbox(t, "editing")
+	meta.TTL = "2h"
+	meta.ExpiresAt = ""
+	now := time.Now()
+
+	before := m.lastActivity(meta)
+	if before.After(now.Add(time.Minute)) {
+		t.Fatalf("clean worktree activity = %v", before)
+	}
+	late := now.Add(150 * time.Minute)
+	for _, file := range []string{"README.md", "new dir/notes.txt"} {
+		path := filepath.Join(meta.Worktree, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("edit\n"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := os.Chtimes(filepath.Join(meta.Worktree, "new dir/notes.txt"), late, late); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if got := m.lastActivity(meta); !got.Equal(late) {
+		t.Fatalf("lastActivity = %v, want the untracked file's mtime %v", got, late)
+	}
+	if expires, _ := m.expiry(meta); !expires.After(now.Add(3 * time.Hour)) {
+		t.Fatalf("recent edits should push the expiry out, got %v", expires)
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 const (
 	defaultSandboxDir   = ".opencode-sandboxes"
@@ -14,15 +17,38 @@ type manager struct {
 	repoRoot    string
 	sandboxRoot string
 	metaDir     string
+	config      *vibeConfig
+	store       sandboxStore
 }
 
 type sandboxMeta struct {
-	Name      string `json:"name"`
-	Branch    string `json:"branch"`
-	BaseRef   string `json:"base_ref"`
-	Worktree  string `json:"worktree"`
-	Container string `json:"container"`
-	CreatedAt string `json:"created_at"`
+	SchemaVersion int          `json:"schema_version"`
+	Name          string       `json:"name"`
+	Branch        string       `json:"branch"`
+	BaseRef       string       `json:"base_ref"`
+	Worktree      string       `json:"worktree"`
+	Container     string       `json:"container"`
+	CreatedAt     string       `json:"created_at"`
+	Sparse        []string     `json:"sparse,omitempty"`
+	Prompt        string       `json:"prompt,omitempty"`
+	PRNumber      int          `json:"pr_number,omitempty"`
+	PRURL         string       `json:"pr_url,omitempty"`
+	Parent        string       `json:"parent,omitempty"`
+	ParentHead    string       `json:"parent_head,omitempty"`
+	Runtime       *runtimeSpec `json:"runtime,omitempty"`
+	Command       string       `json:"command,omitempty"`
+	LastRunAt     string       `json:"last_run_at,omitempty"`
+	ExitCode      *int         `json:"exit_code,omitempty"`
+	TTL           string       `json:"ttl,omitempty"`
+	ExpiresAt     string       `json:"expires_at,omitempty"`
+	LastActive    string       `json:"last_active,omitempty"`
+}
+
+type sandboxOptions struct {
+	Sparse []string
+	Prompt string
+	Parent string
+	TTL    string
 }
 
 type rootOptions struct {
@@ -36,6 +62,12 @@ type goOptions struct {
 	image        string
 	command      string
 	devcontainer string
+	checkpoint   time.Duration
+	sparse       []string
+	sparsePreset string
+	prompt       string
+	on           string
+	ttl          string
 }
 
 type doneOptions struct {
@@ -48,20 +80,94 @@ type doneOptions struct {
 	title        string
 	body         string
 	draft        bool
+	remote       string
+	pushRemote   string
+	edit         bool
+	merged       bool
+	global       bool
+	skipChecks   bool
+	filter       listFilter
 }
 
 type prOptions struct {
-	name  string
-	base  string
-	title string
-	body  string
-	draft bool
+	name       string
+	base       string
+	title      string
+	body       string
+	draft      bool
+	remote     string
+	pushRemote string
+	edit       bool
+	stack      bool
+	skipChecks bool
+}
+
+type prStatusOptions struct {
+	remote string
+}
+
+type exportOptions struct {
+	name   string
+	format string
+	output string
+}
+
+type importOptions struct {
+	name         string
+	meta         string
+	branchPrefix string
+}
+
+type trashRestoreOptions struct {
+	name string
+}
+
+type trashPurgeOptions struct {
+	all bool
 }
 
 type createOptions struct {
 	name         string
 	base         string
 	branchPrefix string
+	sparse       []string
+	sparsePreset string
+	on           string
+	ttl          string
+}
+
+type listOptions struct {
+	tree   bool
+	global bool
+	output string
+	filter listFilter
+}
+
+type statusOptions struct {
+	name   string
+	output string
+}
+
+type historyOptions struct {
+	name   string
+	output string
+}
+
+type eventsOptions struct {
+	name   string
+	follow bool
+	output string
+}
+
+type syncOptions struct {
+	name string
+}
+
+type tidyOptions struct {
+	name     string
+	strategy string
+	message  string
+	dryRun   bool
 }
 
 type runOptions struct {
@@ -69,6 +175,16 @@ type runOptions struct {
 	image        string
 	command      string
 	devcontainer string
+	checkpoint   time.Duration
+}
+
+type checkpointsOptions struct {
+	name string
+}
+
+type restoreOptions struct {
+	name string
+	to   int
 }
 
 type destroyOptions struct {
@@ -79,13 +195,14 @@ type destroyOptions struct {
 }
 
 type runtimeSpec struct {
-	Image           string
-	RunArgs         []string
-	ContainerEnv    map[string]string
-	RemoteUser      string
-	Mounts          []string
-	WorkspaceMount  string
-	WorkspaceFolder string
+	Image           string            `json:"image"`
+	RunArgs         []string          `json:"run_args,omitempty"`
+	ContainerEnv    map[string]string `json:"container_env,omitempty"`
+	RemoteUser      string            `json:"remote_user,omitempty"`
+	Mounts          []string          `json:"mounts,omitempty"`
+	WorkspaceMount  string            `json:"workspace_mount,omitempty"`
+	WorkspaceFolder string            `json:"workspace_folder,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
 }
 
 type devcontainerConfig struct {
@@ -106,3 +223,16 @@ type devcontainerBuild struct {
 	Context    string            `json:"context"`
 	Args       map[string]string `json:"args"`
 }
+
+type gcOptions struct {
+	dryRun bool
+	global bool
+}
+
+type pruneOptions struct {
+	apply bool
+}
+
+type migrateOptions struct {
+	dryRun bool
+}